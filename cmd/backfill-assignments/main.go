@@ -0,0 +1,37 @@
+// backfill-assignments 为分派功能上线前发布的任务生成分派记录，升级后执行一次即可；
+// 已处理的任务会被标记，重复执行不会重复分派。
+package main
+
+import (
+	"log"
+
+	"unihub/internal/config"
+	"unihub/internal/db"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/internal/service"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	gormDB, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("connect db: %v", err)
+	}
+	if err := model.AutoMigrate(gormDB); err != nil {
+		log.Fatalf("auto migrate: %v", err)
+	}
+
+	userRepo := repo.NewUserRepository(gormDB)
+	templateSvc := service.NewTemplateService(repo.NewTemplateRepository(gormDB), userRepo, repo.NewPreferenceRepository(gormDB))
+	taskSvc := service.NewTaskService(repo.NewTaskRepository(gormDB), repo.NewOrgRepository(gormDB), userRepo,
+		repo.NewNotificationRepository(gormDB), templateSvc, cfg)
+	if err := taskSvc.BackfillAssignments(); err != nil {
+		log.Fatalf("backfill task assignments: %v", err)
+	}
+	log.Println("task assignments backfilled")
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -11,6 +13,8 @@ type Config struct {
 	Server struct {
 		Port string `mapstructure:"port"`
 		Mode string `mapstructure:"mode"`
+		// PublicURL 对外访问地址，用于生成日历订阅等绝对链接，例如 https://unihub.example.edu
+		PublicURL string `mapstructure:"public_url"`
 	} `mapstructure:"server"`
 	Database struct {
 		Driver   string `mapstructure:"driver"`
@@ -29,6 +33,73 @@ type Config struct {
 		Secret          string `mapstructure:"secret"`
 		ExpirationHours int    `mapstructure:"expiration_hours"`
 	} `mapstructure:"jwt"`
+	ExitPass struct {
+		// SigningKey 出门凭证签名密钥材料，必须配置且不应与 JWT 密钥相同
+		SigningKey string `mapstructure:"signing_key"`
+		// Grace 凭证在请假开始前/结束后额外有效的时长
+		Grace time.Duration `mapstructure:"grace"`
+	} `mapstructure:"exit_pass"`
+	Leave struct {
+		// Escalation 逾期未返校的逐级提醒，按 After 升序执行；为空时不启用
+		Escalation []EscalationStep `mapstructure:"escalation"`
+		// EscalationWindow 只处理结束时间在该时长以内的请假，避免历史数据被集中提醒；0 表示不限制
+		EscalationWindow time.Duration `mapstructure:"escalation_window"`
+	} `mapstructure:"leave"`
+	Notification struct {
+		Outbox struct {
+			// Interval 后台投递任务的轮询间隔
+			Interval time.Duration `mapstructure:"interval"`
+			// MaxAttempts 超过该次数仍失败则进入死信状态
+			MaxAttempts int           `mapstructure:"max_attempts"`
+			BaseBackoff time.Duration `mapstructure:"base_backoff"`
+			MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+		} `mapstructure:"outbox"`
+		Ack struct {
+			// RemindEvery 未确认的接收人每隔该时长提醒一次，最多 MaxReminders 次
+			RemindEvery  time.Duration `mapstructure:"remind_every"`
+			MaxReminders int           `mapstructure:"max_reminders"`
+		} `mapstructure:"ack"`
+		// RecallWindow 发布后多长时间内发送者可以撤回通知
+		RecallWindow time.Duration `mapstructure:"recall_window"`
+	} `mapstructure:"notification"`
+	Task struct {
+		// LateJoiner 学生在任务发布后才加入发布对象时的处理：none 不分派；open 分派仍可提交的任务；
+		// extend 同 open，且截止时间至少顺延到加入后 LateJoinerGrace
+		LateJoiner      string        `mapstructure:"late_joiner"`
+		LateJoinerGrace time.Duration `mapstructure:"late_joiner_grace"`
+		// RemindInterval 同一任务两次催交之间的最短间隔
+		RemindInterval time.Duration `mapstructure:"remind_interval"`
+	} `mapstructure:"task"`
+	Email struct {
+		// Enabled 为 false 时不发送通知邮件
+		Enabled  bool   `mapstructure:"enabled"`
+		Host     string `mapstructure:"host"`
+		Port     int    `mapstructure:"port"`
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+		From     string `mapstructure:"from"`
+	} `mapstructure:"email"`
+	Push struct {
+		// BatchSize 单次请求的令牌数 (FCM) 或并发请求数 (APNs)
+		BatchSize int           `mapstructure:"batch_size"`
+		Timeout   time.Duration `mapstructure:"timeout"`
+		FCM       struct {
+			Endpoint  string `mapstructure:"endpoint"`
+			ServerKey string `mapstructure:"server_key"`
+		} `mapstructure:"fcm"`
+		APNs struct {
+			Endpoint  string `mapstructure:"endpoint"`
+			Topic     string `mapstructure:"topic"`
+			AuthToken string `mapstructure:"auth_token"`
+		} `mapstructure:"apns"`
+	} `mapstructure:"push"`
+}
+
+// EscalationStep 逾期提醒的一级：请假结束 After 之后通知 Notify 中的对象
+// (student, counselor, admin, emergency_contact)，admin 的通知中附带学生的紧急联系人。
+type EscalationStep struct {
+	After  time.Duration `mapstructure:"after"`
+	Notify []string      `mapstructure:"notify"`
 }
 
 // Load loads configuration from CONFIG_PATH env or defaults to configs/config.yaml.
@@ -42,6 +113,19 @@ func Load() (*Config, error) {
 		v.SetConfigFile(envPath)
 	}
 
+	// 后台任务的间隔为 0 会导致 ticker panic，旧配置文件缺少这些项时使用默认值
+	v.SetDefault("notification.outbox.interval", "10s")
+	v.SetDefault("notification.outbox.max_attempts", 6)
+	v.SetDefault("notification.outbox.base_backoff", "30s")
+	v.SetDefault("notification.outbox.max_backoff", "1h")
+	v.SetDefault("notification.ack.remind_every", "24h")
+	v.SetDefault("notification.ack.max_reminders", 3)
+	v.SetDefault("notification.recall_window", "24h")
+	v.SetDefault("task.late_joiner", "open")
+	v.SetDefault("task.late_joiner_grace", "72h")
+	v.SetDefault("task.remind_interval", "1h")
+	v.SetDefault("push.timeout", "10s")
+
 	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
@@ -50,6 +134,9 @@ func Load() (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	if cfg.ExitPass.SigningKey == "" {
+		return nil, errors.New("exit_pass.signing_key is required")
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"unihub/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarHandler struct {
+	Service service.CalendarService
+}
+
+func NewCalendarHandler(s service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{Service: s}
+}
+
+// GetFeed 获取我的日历订阅地址
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	url, err := h.Service.GetFeedURL(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取订阅地址失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// RegenerateFeed 重新生成订阅地址，旧地址失效
+func (h *CalendarHandler) RegenerateFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	url, err := h.Service.RegenerateFeed(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成订阅地址失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "订阅地址已重新生成", "url": url})
+}
+
+// RevokeFeed 撤销订阅地址
+func (h *CalendarHandler) RevokeFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	if err := h.Service.RevokeFeed(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销订阅地址失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "订阅地址已撤销"})
+}
+
+// Feed 日历客户端拉取 .ics，通过地址中的令牌鉴权
+func (h *CalendarHandler) Feed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("file"), ".ics")
+
+	body, err := h.Service.RenderFeed(token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "订阅地址无效" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(body))
+}
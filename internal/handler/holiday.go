@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"unihub/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HolidayHandler struct {
+	Service service.HolidayService
+}
+
+func NewHolidayHandler(s service.HolidayService) *HolidayHandler {
+	return &HolidayHandler{Service: s}
+}
+
+type CreateHolidayCampaignRequest struct {
+	Title         string    `json:"title" binding:"required"`
+	StartTime     time.Time `json:"start_time" binding:"required"`
+	EndTime       time.Time `json:"end_time" binding:"required"`
+	ReturnDate    time.Time `json:"return_date" binding:"required"`
+	DepartmentIDs []uint    `json:"department_ids"`
+}
+
+type HolidayRegisterRequest struct {
+	Destination   string    `json:"destination" binding:"required,max=255"`
+	Transport     string    `json:"transport" binding:"required,max=50"`
+	DepartureTime time.Time `json:"departure_time" binding:"required"`
+	ReturnTime    time.Time `json:"return_time" binding:"required"`
+	Remark        string    `json:"remark" binding:"max=255"`
+}
+
+// CreateCampaign 辅导员/管理员发起假期离校登记
+func (h *HolidayHandler) CreateCampaign(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roleID := c.GetUint("roleID")
+
+	var req CreateHolidayCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	campaign, err := h.Service.CreateCampaign(service.CreateHolidayCampaignRequest{
+		CreatorID:     userID,
+		RoleID:        roleID,
+		Title:         req.Title,
+		StartTime:     req.StartTime,
+		EndTime:       req.EndTime,
+		ReturnDate:    req.ReturnDate,
+		DepartmentIDs: req.DepartmentIDs,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "无权限发起离校登记" || err.Error() == "只能向自己管理的部门发起登记" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "离校登记发布成功", "id": campaign.ID})
+}
+
+// ListMyCreatedCampaigns 查看我发起的离校登记
+func (h *HolidayHandler) ListMyCreatedCampaigns(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	campaigns, err := h.Service.ListMyCreatedCampaigns(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取离校登记失败"})
+		return
+	}
+	c.JSON(http.StatusOK, campaigns)
+}
+
+// GetCampaignStats 查看离校登记完成情况
+func (h *HolidayHandler) GetCampaignStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的登记活动ID"})
+		return
+	}
+
+	stats, err := h.Service.GetCampaignStats(userID, uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权查看该登记活动" {
+			status = http.StatusForbidden
+		} else if err.Error() == "登记活动不存在" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ListMyCampaigns 学生查看需要填写的离校登记
+func (h *HolidayHandler) ListMyCampaigns(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	campaigns, err := h.Service.ListMyCampaigns(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取离校登记失败"})
+		return
+	}
+	c.JSON(http.StatusOK, campaigns)
+}
+
+// Register 学生填写/修改离校登记
+func (h *HolidayHandler) Register(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的登记活动ID"})
+		return
+	}
+
+	var req HolidayRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.Register(service.HolidayRegisterRequest{
+		StudentID:     userID,
+		CampaignID:    uint(id),
+		Destination:   req.Destination,
+		Transport:     req.Transport,
+		DepartureTime: req.DepartureTime,
+		ReturnTime:    req.ReturnTime,
+		Remark:        req.Remark,
+	}); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "登记活动不存在" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "登记成功"})
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 	"unihub/internal/service"
 
@@ -9,27 +10,90 @@ import (
 )
 
 type LeaveHandler struct {
-	leaveService service.LeaveService
-	dingService  service.DingService
+	leaveService      service.LeaveService
+	dingService       service.DingService
+	escalationService service.EscalationService
+	passService       service.ExitPassService
 }
 
-func NewLeaveHandler(s service.LeaveService, d service.DingService) *LeaveHandler {
+func NewLeaveHandler(s service.LeaveService, d service.DingService, e service.EscalationService, p service.ExitPassService) *LeaveHandler {
 	return &LeaveHandler{
-		leaveService: s,
-		dingService:  d,
+		leaveService:      s,
+		dingService:       d,
+		escalationService: e,
+		passService:       p,
 	}
 }
 
 type ApplyLeaveRequest struct {
-	Type      string    `json:"type" binding:"required"`
-	StartTime time.Time `json:"start_time" binding:"required"`
-	EndTime   time.Time `json:"end_time" binding:"required"`
-	Reason    string    `json:"reason" binding:"required"`
+	Type              string    `json:"type" binding:"required"`
+	StartTime         time.Time `json:"start_time" binding:"required"`
+	EndTime           time.Time `json:"end_time" binding:"required"`
+	Reason            string    `json:"reason" binding:"required"`
+	Attachments       []string  `json:"attachments"`
+	ShareWithTeachers bool      `json:"share_with_teachers"` // 是否允许任课教师查看原因和附件
+}
+
+type CancelLeaveRequest struct {
+	LeaveID uint `json:"leave_id" binding:"required"`
+}
+
+type VerifyPassRequest struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+type ShareConsentRequest struct {
+	LeaveID uint `json:"leave_id" binding:"required"`
+	Share   bool `json:"share"`
+}
+
+// ClassLeavesQuery 任课教师查看请假的查询参数
+type ClassLeavesQuery struct {
+	Date    string `form:"date"` // YYYY-MM-DD，默认今天
+	ClassID uint   `form:"class_id"`
 }
 
 type AuditLeaveRequest struct {
 	Status  string `json:"status" binding:"required,oneof=approved rejected"`
 	LeaveID uint   `json:"leave_id" binding:"required"`
+	Comment string `json:"comment" binding:"max=255"`
+}
+
+type BatchAuditLeaveRequest struct {
+	Status   string `json:"status" binding:"required,oneof=approved rejected"`
+	LeaveIDs []uint `json:"leave_ids" binding:"required,min=1,max=500"`
+	Comment  string `json:"comment" binding:"max=255"`
+}
+
+// PendingLeaveQuery 待审批队列查询参数
+type PendingLeaveQuery struct {
+	Type         string     `form:"type"`
+	DepartmentID uint       `form:"department_id"`
+	From         *time.Time `form:"from"`
+	To           *time.Time `form:"to"`
+	MinDays      float64    `form:"min_days" binding:"gte=0"`
+	MaxDays      float64    `form:"max_days" binding:"gte=0"`
+	SortBy       string     `form:"sort_by" binding:"omitempty,oneof=created_at start_time end_time duration"`
+	Order        string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	Page         int        `form:"page,default=1" binding:"min=1"`
+	PageSize     int        `form:"page_size,default=20" binding:"min=1,max=200"`
+}
+
+// LeaveReportQuery 请假报表查询参数
+type LeaveReportQuery struct {
+	DepartmentID uint       `form:"department_id"`
+	From         *time.Time `form:"from"`
+	To           *time.Time `form:"to"`
+	Period       string     `form:"period,default=month" binding:"oneof=week month"`
+	TopN         int        `form:"top_n,default=10" binding:"min=1,max=100"`
+}
+
+type CreateDelegationRequest struct {
+	DelegateID   uint      `json:"delegate_id" binding:"required"`
+	DepartmentID uint      `json:"department_id"` // 不传或为 0 表示委托全部部门
+	StartTime    time.Time `json:"start_time" binding:"required"`
+	EndTime      time.Time `json:"end_time" binding:"required"`
+	Reason       string    `json:"reason"`
 }
 
 // Apply 申请请假
@@ -42,11 +106,13 @@ func (h *LeaveHandler) Apply(c *gin.Context) {
 	}
 
 	serviceReq := service.ApplyLeaveRequest{
-		StudentID: userID,
-		Type:      req.Type,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		Reason:    req.Reason,
+		StudentID:         userID,
+		Type:              req.Type,
+		StartTime:         req.StartTime,
+		EndTime:           req.EndTime,
+		Reason:            req.Reason,
+		Attachments:       req.Attachments,
+		ShareWithTeachers: req.ShareWithTeachers,
 	}
 
 	leave, err := h.leaveService.Apply(serviceReq)
@@ -74,14 +140,17 @@ func (h *LeaveHandler) Audit(c *gin.Context) {
 		RoleID:    roleID,
 		LeaveID:   req.LeaveID,
 		Status:    req.Status,
+		Comment:   req.Comment,
 	}
 
 	if err := h.leaveService.Audit(serviceReq, h.dingService); err != nil {
 		status := http.StatusInternalServerError
-		if err.Error() == "无权限审批" || err.Error() == "无权审批该学生请假" {
+		if err.Error() == "无权限审批" || err.Error() == "无权审批该学生请假" || err.Error() == "学生未加入部门" {
 			status = http.StatusForbidden
 		} else if err.Error() == "请假记录不存在" {
 			status = http.StatusNotFound
+		} else if err.Error() == "该请假已审批" {
+			status = http.StatusConflict
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
 		return
@@ -90,18 +159,79 @@ func (h *LeaveHandler) Audit(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "审批成功"})
 }
 
-// ListPendingLeaves 辅导员查看待审批请假
+// BatchAudit 批量审批请假，返回每条请假的处理结果
+func (h *LeaveHandler) BatchAudit(c *gin.Context) {
+	auditorID := c.GetUint("userID")
+	roleID := c.GetUint("roleID")
+
+	var req BatchAuditLeaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := h.leaveService.BatchAudit(service.BatchAuditLeaveRequest{
+		AuditorID: auditorID,
+		RoleID:    roleID,
+		LeaveIDs:  req.LeaveIDs,
+		Status:    req.Status,
+		Comment:   req.Comment,
+	}, h.dingService)
+
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+// ListPendingLeaves 辅导员查看待审批请假 (支持筛选、排序、分页)
 func (h *LeaveHandler) ListPendingLeaves(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roleID := c.GetUint("roleID")
 
-	leaves, err := h.leaveService.ListPendingLeaves(userID, roleID)
+	var q PendingLeaveQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	leaves, total, err := h.leaveService.ListPendingLeaves(userID, roleID, service.PendingLeaveQuery{
+		Type:         q.Type,
+		DepartmentID: q.DepartmentID,
+		From:         q.From,
+		To:           q.To,
+		MinDays:      q.MinDays,
+		MaxDays:      q.MaxDays,
+		SortBy:       q.SortBy,
+		Order:        q.Order,
+		Page:         q.Page,
+		PageSize:     q.PageSize,
+	})
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch err.Error() {
+		case "无权限查看待审批请假", "无权限查看该部门请假":
+			status = http.StatusForbidden
+		case "最短天数不能大于最长天数":
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, leaves)
+	c.JSON(http.StatusOK, gin.H{
+		"items":     leaves,
+		"total":     total,
+		"page":      q.Page,
+		"page_size": q.PageSize,
+	})
 }
 
 // MyLeaves 学生查看自己的请假
@@ -117,6 +247,58 @@ func (h *LeaveHandler) MyLeaves(c *gin.Context) {
 	c.JSON(http.StatusOK, leaves)
 }
 
+// UpdateShareConsent 学生设置是否向任课教师公开请假原因和附件
+func (h *LeaveHandler) UpdateShareConsent(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req ShareConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.leaveService.UpdateShareConsent(userID, req.LeaveID, req.Share); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "请假记录不存在" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功"})
+}
+
+// ListClassLeaves 任课教师查看班级学生在指定日期的已批准请假
+func (h *LeaveHandler) ListClassLeaves(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var q ClassLeavesQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	date := time.Now()
+	if q.Date != "" {
+		d, err := time.ParseInLocation("2006-01-02", q.Date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 YYYY-MM-DD"})
+			return
+		}
+		date = d
+	}
+
+	leaves, err := h.leaveService.ListClassLeaves(userID, date, q.ClassID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权查看该班级" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, leaves)
+}
+
 func (h *LeaveHandler) LeaveData(context *gin.Context) {
 	userId := context.GetUint("userID")
 
@@ -139,3 +321,207 @@ func (h *LeaveHandler) LeaveBackInfo(context *gin.Context) {
 	}
 	context.JSON(http.StatusOK, data)
 }
+
+// CreateDelegation 辅导员将请假审批权委托给其他教职工
+func (h *LeaveHandler) CreateDelegation(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roleID := c.GetUint("roleID")
+
+	var req CreateDelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	delegation, err := h.leaveService.CreateDelegation(service.CreateDelegationRequest{
+		DelegatorID:  userID,
+		RoleID:       roleID,
+		DelegateID:   req.DelegateID,
+		DepartmentID: req.DepartmentID,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		Reason:       req.Reason,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "无权限委托审批" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "委托成功", "id": delegation.ID})
+}
+
+// ListMyDelegations 查看我发出的和收到的审批委托
+func (h *LeaveHandler) ListMyDelegations(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	delegations, err := h.leaveService.ListMyDelegations(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询委托失败"})
+		return
+	}
+	c.JSON(http.StatusOK, delegations)
+}
+
+// RevokeDelegation 提前撤销审批委托
+func (h *LeaveHandler) RevokeDelegation(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的委托ID"})
+		return
+	}
+
+	if err := h.leaveService.RevokeDelegation(userID, uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权撤销该委托" {
+			status = http.StatusForbidden
+		} else if err.Error() == "委托记录不存在" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "委托已撤销"})
+}
+
+// ListEscalations 查看请假的逾期提醒记录
+func (h *LeaveHandler) ListEscalations(c *gin.Context) {
+	userID := c.GetUint("userID")
+	leaveID, err := strconv.ParseUint(c.Param("leaveId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请假ID"})
+		return
+	}
+
+	records, err := h.escalationService.ListEscalations(userID, uint(leaveID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权查看该请假" {
+			status = http.StatusForbidden
+		} else if err.Error() == "请假记录不存在" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+func (h *LeaveHandler) bindReportQuery(c *gin.Context) (service.LeaveReportQuery, bool) {
+	var q LeaveReportQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return service.LeaveReportQuery{}, false
+	}
+	return service.LeaveReportQuery{
+		UserID:       c.GetUint("userID"),
+		RoleID:       c.GetUint("roleID"),
+		DepartmentID: q.DepartmentID,
+		From:         q.From,
+		To:           q.To,
+		Period:       q.Period,
+		TopN:         q.TopN,
+	}, true
+}
+
+// LeaveReport 请假汇总报表 (按类型、部门、周/月、审批时效、请假天数排行)
+func (h *LeaveHandler) LeaveReport(c *gin.Context) {
+	q, ok := h.bindReportQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.leaveService.LeaveReport(q)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权限查看请假报表" || err.Error() == "无权限查看该部门请假" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportLeaveReport 导出请假报表 Excel
+func (h *LeaveHandler) ExportLeaveReport(c *gin.Context) {
+	q, ok := h.bindReportQuery(c)
+	if !ok {
+		return
+	}
+
+	filePath, err := h.leaveService.ExportLeaveReport(q)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无权限查看请假报表" || err.Error() == "无权限查看该部门请假" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "导出请假报表成功", "fileRelativePath": filePath})
+}
+
+// Cancel 学生取消请假
+func (h *LeaveHandler) Cancel(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CancelLeaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.leaveService.Cancel(userID, req.LeaveID, h.dingService); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "请假记录不存在" {
+			status = http.StatusNotFound
+		} else if err.Error() == "当前状态不可取消" || err.Error() == "请假已结束，不可取消" {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "请假已取消"})
+}
+
+// GetExitPass 学生获取请假的电子出门凭证 (二维码内容)
+func (h *LeaveHandler) GetExitPass(c *gin.Context) {
+	userID := c.GetUint("userID")
+	leaveID, err := strconv.ParseUint(c.Param("leaveId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请假ID"})
+		return
+	}
+
+	pass, err := h.passService.GetMyPass(userID, uint(leaveID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"leave_id":    pass.LeaveID,
+		"valid_from":  pass.ValidFrom,
+		"valid_until": pass.ValidUntil,
+		"qr_payload":  pass.Payload,
+	})
+}
+
+// ExitPassPublicKey 门禁设备获取验签公钥 (Ed25519, Base64)
+func (h *LeaveHandler) ExitPassPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"algorithm": "Ed25519", "public_key": h.passService.PublicKey()})
+}
+
+// VerifyExitPass 门禁设备在线校验扫描到的出门凭证 (含吊销检查)
+func (h *LeaveHandler) VerifyExitPass(c *gin.Context) {
+	var req VerifyPassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.passService.Verify(req.Payload))
+}
@@ -2,6 +2,9 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
+	"unihub/internal/model"
 	"unihub/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -16,13 +19,95 @@ func NewNotificationHandler(s service.NotificationService) *NotificationHandler
 }
 
 type CreateNotifRequest struct {
-	Title      string `json:"title" binding:"required"`
-	Content    string `json:"content" binding:"required"`
-	TargetType string `json:"target_type" binding:"required,oneof=dept class"` // 目标类型：dept, class
-	TargetID   uint   `json:"target_id" binding:"required"`
+	Title      string `json:"title" binding:"required_without=TemplateKey"`
+	Content    string `json:"content" binding:"required_without=TemplateKey"`
+	TargetType string `json:"target_type" binding:"omitempty,oneof=dept class"` // 单个目标：dept, class
+	TargetID   uint   `json:"target_id" binding:"required_with=TargetType"`
+	// 多个目标，与 target_type 二选一，重复的接收人只收到一次
+	Audience   *AudienceRequest `json:"audience"`
+	RequireAck bool             `json:"require_ack"` // 是否需要学生确认知悉
+	Urgent     bool             `json:"urgent"`      // 紧急通知，忽略免打扰时段 (需 notification:urgent 权限)
+	Draft      bool             `json:"draft"`       // 保存为草稿
+	// 定时发送，可选按 daily/weekly/monthly 重复
+	ScheduledAt     *time.Time `json:"scheduled_at"`
+	Recurrence      string     `json:"recurrence" binding:"omitempty,oneof=daily weekly monthly"`
+	RecurrenceUntil *time.Time `json:"recurrence_until"`
+	// 使用模板生成标题和内容，variables 替换模板中的 {{占位符}}
+	TemplateKey string            `json:"template_key"`
+	Locale      string            `json:"locale"`
+	Variables   map[string]string `json:"variables"`
+	// 内容格式 text/markdown，附件为已上传文件的地址
+	Format      string              `json:"format" binding:"omitempty,oneof=text markdown"`
+	Attachments []AttachmentRequest `json:"attachments" binding:"max=9,dive"`
 }
 
-// Create 发布通知 (辅导员/教师)
+type AudienceRequest struct {
+	DeptIDs    []uint `json:"dept_ids"`
+	ClassIDs   []uint `json:"class_ids"`
+	StudentIDs []uint `json:"student_ids"`
+	RoleIDs    []uint `json:"role_ids"` // 按角色发送 (需 notification:broadcast 权限)
+	School     bool   `json:"school"`   // 全校发送 (需 notification:broadcast 权限)
+}
+
+func (req *AudienceRequest) toTargets() []model.Target {
+	if req == nil {
+		return nil
+	}
+	var targets []model.Target
+	add := func(targetType string, ids []uint) {
+		for _, id := range ids {
+			targets = append(targets, model.Target{Type: targetType, ID: id})
+		}
+	}
+	add("dept", req.DeptIDs)
+	add("class", req.ClassIDs)
+	add("student", req.StudentIDs)
+	add("role", req.RoleIDs)
+	if req.School {
+		targets = append(targets, model.Target{Type: "school"})
+	}
+	return targets
+}
+
+type AttachmentRequest struct {
+	Type string `json:"type" binding:"required,oneof=image file"`
+	Name string `json:"name" binding:"required,max=255"`
+	URL  string `json:"url" binding:"required,url"`
+	Size int64  `json:"size"`
+}
+
+func toAttachments(reqs []AttachmentRequest) []service.NotificationAttachment {
+	attachments := make([]service.NotificationAttachment, len(reqs))
+	for i, a := range reqs {
+		attachments[i] = service.NotificationAttachment(a)
+	}
+	return attachments
+}
+
+func (req CreateNotifRequest) toService(userID, roleID uint) service.CreateNotifRequest {
+	return service.CreateNotifRequest{
+		Title:           req.Title,
+		Content:         req.Content,
+		TargetType:      req.TargetType,
+		TargetID:        req.TargetID,
+		SenderID:        userID,
+		RoleID:          roleID,
+		RequireAck:      req.RequireAck,
+		Urgent:          req.Urgent,
+		Draft:           req.Draft,
+		ScheduledAt:     req.ScheduledAt,
+		Recurrence:      req.Recurrence,
+		RecurrenceUntil: req.RecurrenceUntil,
+		TemplateKey:     req.TemplateKey,
+		Locale:          req.Locale,
+		Variables:       req.Variables,
+		Format:          req.Format,
+		Attachments:     toAttachments(req.Attachments),
+		Audience:        req.Audience.toTargets(),
+	}
+}
+
+// Create 发布通知 (辅导员/教师)，也可保存为草稿或定时发送
 func (h *NotificationHandler) Create(c *gin.Context) {
 	userID := c.GetUint("userID")
 	var req CreateNotifRequest
@@ -31,20 +116,207 @@ func (h *NotificationHandler) Create(c *gin.Context) {
 		return
 	}
 
-	serviceReq := service.CreateNotifRequest{
-		Title:      req.Title,
-		Content:    req.Content,
-		TargetType: req.TargetType,
-		TargetID:   req.TargetID,
-		SenderID:   userID,
+	notif, err := h.Service.Create(req.toService(userID, c.GetUint("roleID")))
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
 	}
 
-	if err := h.Service.Create(serviceReq); err != nil {
+	c.JSON(http.StatusOK, gin.H{"message": notifStatusMessage(notif.Status), "id": notif.ID, "status": notif.Status})
+}
+
+func notifStatusMessage(status string) string {
+	switch status {
+	case "draft":
+		return "草稿已保存"
+	case "scheduled":
+		return "定时通知已保存"
+	}
+	return "通知发布成功"
+}
+
+// ListSchedules 我的草稿和定时通知
+func (h *NotificationHandler) ListSchedules(c *gin.Context) {
+	userID := c.GetUint("userID")
+	notifs, err := h.Service.ListSchedules(userID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, notifs)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "通知发布成功"})
+// UpdateSchedule 修改草稿或定时通知
+func (h *NotificationHandler) UpdateSchedule(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+	var req CreateNotifRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notif, err := h.Service.UpdateSchedule(userID, uint(id), req.toService(userID, c.GetUint("roleID")))
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": notifStatusMessage(notif.Status), "id": notif.ID, "status": notif.Status})
+}
+
+// PublishDraft 提交草稿
+func (h *NotificationHandler) PublishDraft(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	notif, err := h.Service.PublishDraft(userID, uint(id))
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": notifStatusMessage(notif.Status), "id": notif.ID, "status": notif.Status})
+}
+
+// CancelSchedule 取消尚未发送的定时通知或草稿
+func (h *NotificationHandler) CancelSchedule(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	if err := h.Service.CancelSchedule(userID, uint(id)); err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已取消"})
+}
+
+type EditNotifRequest struct {
+	Title       string              `json:"title" binding:"required,max=100"`
+	Content     string              `json:"content" binding:"required"`
+	Format      string              `json:"format" binding:"omitempty,oneof=text markdown"`
+	Attachments []AttachmentRequest `json:"attachments" binding:"max=9,dive"`
+}
+
+// Edit 修改已发布的通知，保留历史版本
+func (h *NotificationHandler) Edit(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+	var req EditNotifRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notif, err := h.Service.Edit(userID, uint(id), service.EditNotifRequest{
+		Title:       req.Title,
+		Content:     req.Content,
+		Format:      req.Format,
+		Attachments: toAttachments(req.Attachments),
+	})
+	if err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "通知已修改", "version": notif.Version})
+}
+
+// ListVersions 通知的历史版本 (发送者)
+func (h *NotificationHandler) ListVersions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	versions, err := h.Service.ListVersions(userID, uint(id))
+	if err != nil {
+		h.respondSenderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// Recall 撤回已发布的通知
+func (h *NotificationHandler) Recall(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	if err := h.Service.Recall(userID, uint(id)); err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "通知已撤回"})
+}
+
+func (h *NotificationHandler) respondScheduleError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "通知不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "没有权限向该目标发送通知", "无权修改该通知", "无权限发送紧急通知":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case "通知已发送或已取消", "只能提交草稿", "周期通知需要设置首次发送时间",
+		"定时发送时间必须晚于当前时间", "重复截止时间不能早于首次发送时间", "模板不存在", "标题和内容不能为空",
+		"内容格式只能是 text 或 markdown", "附件类型只能是 image 或 file", "附件地址无效",
+		"通知未发布或已撤回", "已超过撤回时限", "请指定通知对象", "未知的通知对象类型":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case "通知已被修改或撤回，请刷新后重试":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+type InboxQuery struct {
+	Category string `form:"category" binding:"omitempty,oneof=announcement ding leave task system"`
+	Keyword  string `form:"q"`
+	Cursor   uint   `form:"cursor"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Inbox 收件箱：部门、班级和个人消息，支持分类筛选、搜索和游标分页
+func (h *NotificationHandler) Inbox(c *gin.Context) {
+	userID := c.GetUint("userID")
+	var q InboxQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.Service.Inbox(userID, service.InboxQuery{
+		Category: q.Category,
+		Keyword:  q.Keyword,
+		Cursor:   q.Cursor,
+		Limit:    q.Limit,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "无效的分页游标" {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 // GetMyNotifications 学生查看通知
@@ -59,3 +331,200 @@ func (h *NotificationHandler) GetMyNotifications(c *gin.Context) {
 
 	c.JSON(http.StatusOK, notifs)
 }
+
+// GetDeliveryStatus 发送者查看通知的投递状态及每个接收人的推送结果
+func (h *NotificationHandler) GetDeliveryStatus(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	status, err := h.Service.GetDeliveryStatus(userID, uint(id))
+	if err != nil {
+		h.respondSenderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// MarkRead 标记通知已读
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	if err := h.Service.MarkRead(userID, uint(id)); err != nil {
+		if err.Error() == "通知不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已标记为已读"})
+}
+
+// MarkAllRead 全部标记为已读
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := h.Service.MarkAllRead(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已全部标记为已读"})
+}
+
+// UnreadCount 未读通知数
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	userID := c.GetUint("userID")
+	count, err := h.Service.UnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+// GetReadStats 发送者查看通知的阅读统计
+func (h *NotificationHandler) GetReadStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	stats, err := h.Service.GetReadStats(userID, uint(id))
+	if err != nil {
+		h.respondSenderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// Acknowledge 学生确认已阅读并知悉
+func (h *NotificationHandler) Acknowledge(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	if err := h.Service.Acknowledge(userID, uint(id)); err != nil {
+		switch err.Error() {
+		case "通知不存在":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "该通知无需确认":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已确认知悉"})
+}
+
+// GetAckStats 发送者查看确认情况
+func (h *NotificationHandler) GetAckStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	stats, err := h.Service.GetAckStats(userID, uint(id))
+	if err != nil {
+		h.respondSenderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ExportAckList 导出确认名单 Excel
+func (h *NotificationHandler) ExportAckList(c *gin.Context) {
+	userID := c.GetUint("userID")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	filePath, err := h.Service.ExportAckList(userID, uint(id))
+	if err != nil {
+		h.respondSenderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "导出确认名单成功", "fileRelativePath": filePath})
+}
+
+// respondSenderError 发送者查看通知统计时的错误响应
+func (h *NotificationHandler) respondSenderError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "通知不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "无权查看该通知":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case "该通知无需确认":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetPreferences 各类消息的接收渠道设置
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	prefs, err := h.Service.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+type UpdatePreferencesRequest struct {
+	Categories []struct {
+		Category string `json:"category" binding:"required,oneof=announcement ding leave task system"`
+		Push     bool   `json:"push"`
+		Email    bool   `json:"email"`
+		InApp    bool   `json:"in_app"`
+	} `json:"categories" binding:"dive"`
+	QuietHours struct {
+		Enabled  bool   `json:"enabled"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+		Timezone string `json:"timezone"`
+	} `json:"quiet_hours"`
+	Locale string `json:"locale"`
+}
+
+// UpdatePreferences 设置各类消息的推送/邮件/站内开关及免打扰时段
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs := service.NotificationPreferences{
+		Categories: make([]service.CategoryPreference, len(req.Categories)),
+		QuietHours: service.QuietHours(req.QuietHours),
+		Locale:     req.Locale,
+	}
+	for i, p := range req.Categories {
+		prefs.Categories[i] = service.CategoryPreference{Category: p.Category, Push: p.Push, Email: p.Email, InApp: p.InApp}
+	}
+	if err := h.Service.UpdatePreferences(userID, prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "消息接收偏好已更新"})
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"unihub/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrgUnitHandler struct {
+	Service service.OrgUnitService
+}
+
+func NewOrgUnitHandler(s service.OrgUnitService) *OrgUnitHandler {
+	return &OrgUnitHandler{Service: s}
+}
+
+type CreateOrgUnitRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Type     string `json:"type" binding:"required,oneof=school college grade class"`
+	ParentID *uint  `json:"parent_id"` // 学校节点为空
+}
+
+type RenameOrgUnitRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type MoveOrgUnitRequest struct {
+	ParentID uint `json:"parent_id" binding:"required"`
+}
+
+type AttachOrgUnitRequest struct {
+	OrgUnitID *uint `json:"org_unit_id"` // 为空时解除关联
+}
+
+// GetTree 组织架构树
+func (h *OrgUnitHandler) GetTree(c *gin.Context) {
+	tree, err := h.Service.GetTree(c.GetUint("roleID"))
+	if err != nil {
+		if err.Error() == "无权限查看组织架构" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// Create 新建节点
+func (h *OrgUnitHandler) Create(c *gin.Context) {
+	var req CreateOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	unit, err := h.Service.CreateUnit(c.GetUint("roleID"), service.OrgUnitRequest{
+		Name:     req.Name,
+		Type:     req.Type,
+		ParentID: req.ParentID,
+	})
+	if err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, unit)
+}
+
+// Rename 修改节点名称
+func (h *OrgUnitHandler) Rename(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的节点ID"})
+		return
+	}
+	var req RenameOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Service.RenameUnit(c.GetUint("roleID"), uint(id), req.Name); err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "节点已更新"})
+}
+
+// Move 将节点及其下级移到新的上级节点
+func (h *OrgUnitHandler) Move(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的节点ID"})
+		return
+	}
+	var req MoveOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	unit, err := h.Service.MoveUnit(c.GetUint("roleID"), uint(id), req.ParentID)
+	if err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, unit)
+}
+
+// Delete 删除节点
+func (h *OrgUnitHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的节点ID"})
+		return
+	}
+	if err := h.Service.DeleteUnit(c.GetUint("roleID"), uint(id)); err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "节点已删除"})
+}
+
+// AttachDepartment 设置部门所属的学院或年级节点
+func (h *OrgUnitHandler) AttachDepartment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的部门ID"})
+		return
+	}
+	var req AttachOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Service.AttachDepartment(c.GetUint("roleID"), uint(id), req.OrgUnitID); err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "部门关联已更新"})
+}
+
+// AttachClass 设置班级对应的班级节点
+func (h *OrgUnitHandler) AttachClass(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的班级ID"})
+		return
+	}
+	var req AttachOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Service.AttachClass(c.GetUint("roleID"), uint(id), req.OrgUnitID); err != nil {
+		respondOrgUnitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "班级关联已更新"})
+}
+
+// respondOrgUnitError 无权限返回 403，节点、部门或班级不存在返回 404，并发修改返回 409，其余返回 400
+func respondOrgUnitError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "无权限管理组织架构":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case "节点不存在", "部门不存在", "班级不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "组织架构已变化，请刷新后重试":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
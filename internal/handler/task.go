@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 	"unihub/internal/service"
 
@@ -17,17 +20,35 @@ func NewTaskHandler(s service.TaskService) *TaskHandler {
 }
 
 type CreateTaskRequest struct {
-	Title       string    `json:"title" binding:"required"`
-	Type        string    `json:"type" binding:"required,oneof=sign_in dorm_check"`
-	Description string    `json:"description"`
-	TargetType  string    `json:"target_type" binding:"required,oneof=dept class"`
-	TargetID    uint      `json:"target_id" binding:"required"`
-	Deadline    time.Time `json:"deadline" binding:"required"`
-	Config      any       `json:"config"` // JSON Object
+	Title       string          `json:"title" binding:"required,max=100"`
+	Type        string          `json:"type" binding:"required,oneof=sign_in dorm_check survey file_submission"`
+	Description string          `json:"description" binding:"max=255"`
+	TargetType  string          `json:"target_type" binding:"required,oneof=dept class"`
+	TargetID    uint            `json:"target_id" binding:"required"`
+	Deadline    time.Time       `json:"deadline" binding:"required"`
+	Config      json.RawMessage `json:"config"` // 结构由任务类型决定
+	LatePolicy  string          `json:"late_policy" binding:"omitempty,oneof=reject accept_late cutoff"`
+	LateCutoff  *time.Time      `json:"late_cutoff"` // cutoff 策略的最终截止时间
+}
+
+type UpdateTaskRequest struct {
+	Title       string          `json:"title" binding:"required,max=100"`
+	Description string          `json:"description" binding:"max=255"`
+	Deadline    time.Time       `json:"deadline" binding:"required"`
+	Config      json.RawMessage `json:"config"` // 为空则不修改
+	LatePolicy  string          `json:"late_policy" binding:"omitempty,oneof=reject accept_late cutoff"`
+	LateCutoff  *time.Time      `json:"late_cutoff"`
 }
 
 type SubmitTaskRequest struct {
-	Data any `json:"data" binding:"required"`
+	Data json.RawMessage `json:"data" binding:"required"`
+}
+
+type ReviewSubmissionRequest struct {
+	Action   string   `json:"action" binding:"required,oneof=accept return"`
+	Feedback string   `json:"feedback" binding:"max=1000"`
+	Score    *float64 `json:"score"`
+	Version  int      `json:"version"` // 审阅的提交版本，可选
 }
 
 // CreateTask 发布任务
@@ -48,17 +69,29 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		CreatorID:   userID,
 		Deadline:    req.Deadline,
 		Config:      req.Config,
+		LatePolicy:  req.LatePolicy,
+		LateCutoff:  req.LateCutoff,
 	}
 
 	task, err := h.Service.CreateTask(serviceReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "任务发布成功", "id": task.ID, "uuid": task.UUID})
 }
 
+// ListCreatedTasks 我发布的任务
+func (h *TaskHandler) ListCreatedTasks(c *gin.Context) {
+	tasks, err := h.Service.ListCreatedTasks(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tasks)
+}
+
 // GetMyTasks 学生查看任务列表
 func (h *TaskHandler) GetMyTasks(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -72,6 +105,48 @@ func (h *TaskHandler) GetMyTasks(c *gin.Context) {
 	c.JSON(http.StatusOK, tasks)
 }
 
+// GetTask 任务详情
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	detail, err := h.Service.GetTask(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// UpdateTask 修改任务 (发布者)
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	var req UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.Service.UpdateTask(c.GetUint("userID"), c.Param("uuid"), service.UpdateTaskRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		Deadline:    req.Deadline,
+		Config:      req.Config,
+		LatePolicy:  req.LatePolicy,
+		LateCutoff:  req.LateCutoff,
+	})
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "任务已更新", "uuid": task.UUID})
+}
+
+// DeleteTask 删除任务 (发布者)
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	if err := h.Service.DeleteTask(c.GetUint("userID"), c.Param("uuid")); err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "任务已删除"})
+}
+
 // SubmitTask 学生提交任务
 func (h *TaskHandler) SubmitTask(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -84,9 +159,132 @@ func (h *TaskHandler) SubmitTask(c *gin.Context) {
 	}
 
 	if err := h.Service.SubmitTask(userID, taskUUID, req.Data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondTaskError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "提交成功"})
 }
+
+// GetSurveyResults 问卷汇总结果 (发布者)
+func (h *TaskHandler) GetSurveyResults(c *gin.Context) {
+	results, err := h.Service.GetSurveyResults(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// ExportSurveyResponses 导出问卷回答 (发布者)
+func (h *TaskHandler) ExportSurveyResponses(c *gin.Context) {
+	path, err := h.Service.ExportSurveyResponses(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "导出成功", "fileRelativePath": path})
+}
+
+// ListSubmissions 任务的提交列表 (发布者)
+func (h *TaskHandler) ListSubmissions(c *gin.Context) {
+	submissions, err := h.Service.ListSubmissions(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, submissions)
+}
+
+// ReviewSubmission 审阅提交：通过或退回 (发布者)
+func (h *TaskHandler) ReviewSubmission(c *gin.Context) {
+	recordID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的提交记录ID"})
+		return
+	}
+	var req ReviewSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := h.Service.ReviewSubmission(c.GetUint("userID"), c.Param("uuid"), uint(recordID), service.ReviewSubmissionRequest{
+		Action:   req.Action,
+		Feedback: req.Feedback,
+		Score:    req.Score,
+		Version:  req.Version,
+	})
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "审阅完成", "status": record.Status})
+}
+
+// ListRecordVersions 提交的历史版本 (发布者或提交的学生)
+func (h *TaskHandler) ListRecordVersions(c *gin.Context) {
+	recordID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的提交记录ID"})
+		return
+	}
+	versions, err := h.Service.ListRecordVersions(c.GetUint("userID"), c.Param("uuid"), uint(recordID))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetCompletion 任务完成情况 (发布者)
+func (h *TaskHandler) GetCompletion(c *gin.Context) {
+	completion, err := h.Service.GetCompletion(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, completion)
+}
+
+// RemindMissing 提醒未提交的学生 (发布者)
+func (h *TaskHandler) RemindMissing(c *gin.Context) {
+	count, err := h.Service.RemindMissing(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已发送提醒", "count": count})
+}
+
+// ExportCompletion 导出任务完成情况 (发布者)
+func (h *TaskHandler) ExportCompletion(c *gin.Context) {
+	path, err := h.Service.ExportCompletion(c.GetUint("userID"), c.Param("uuid"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "导出成功", "fileRelativePath": path})
+}
+
+// respondTaskError 任务或提交不存在返回 404，无权限返回 403，版本冲突返回 409，
+// 其余业务校验错误返回 400，数据库等内部错误返回 500
+func respondTaskError(c *gin.Context, err error) {
+	var taskErr *service.TaskError
+	switch err.Error() {
+	case "任务不存在", "提交记录不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "没有权限向该目标发布任务", "无权修改该任务", "无权查看该任务":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case "学生已重新提交，请刷新后再审阅", "提交状态已变化，请刷新后重试":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "提醒过于频繁，请稍后再试":
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		if errors.As(err, &taskErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"unihub/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateHandler struct {
+	Service service.TemplateService
+}
+
+func NewTemplateHandler(s service.TemplateService) *TemplateHandler {
+	return &TemplateHandler{Service: s}
+}
+
+type TemplateRequest struct {
+	Key      string `json:"key" binding:"required,max=100"`
+	Locale   string `json:"locale" binding:"omitempty,max=10"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Category string `json:"category" binding:"omitempty,oneof=announcement ding leave task system"`
+	Title    string `json:"title" binding:"required,max=100"`
+	Content  string `json:"content" binding:"required"`
+	System   bool   `json:"system"` // 系统模板 (仅管理员)
+}
+
+func (req TemplateRequest) toService() service.TemplateRequest {
+	return service.TemplateRequest{
+		Key:      req.Key,
+		Locale:   req.Locale,
+		Name:     req.Name,
+		Category: req.Category,
+		Title:    req.Title,
+		Content:  req.Content,
+		System:   req.System,
+	}
+}
+
+// List 我的模板和系统模板
+func (h *TemplateHandler) List(c *gin.Context) {
+	templates, err := h.Service.ListTemplates(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// Create 新建模板或模板的其他语言版本
+func (h *TemplateHandler) Create(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tmpl, err := h.Service.CreateTemplate(c.GetUint("userID"), c.GetUint("roleID"), req.toService())
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// Update 修改模板 (key 和语言不可修改)
+func (h *TemplateHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+		return
+	}
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tmpl, err := h.Service.UpdateTemplate(c.GetUint("userID"), c.GetUint("roleID"), uint(id), req.toService())
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// Delete 删除模板
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+		return
+	}
+	if err := h.Service.DeleteTemplate(c.GetUint("userID"), c.GetUint("roleID"), uint(id)); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "模板已删除"})
+}
+
+func respondTemplateError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "模板不存在", "用户不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "无权管理通知模板", "无权管理系统模板":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case "该模板的此语言版本已存在":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -16,6 +16,11 @@ func NewUserHandler(s service.UserService) *UserHandler {
 	return &UserHandler{Service: s}
 }
 
+type EmergencyContactRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Phone string `json:"phone" binding:"required,max=30"`
+}
+
 // GetProfile 获取个人资料
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -38,6 +43,23 @@ func (h *UserHandler) GetOrgInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, data)
 }
 
+// UpdateEmergencyContact 登记/修改紧急联系人
+func (h *UserHandler) UpdateEmergencyContact(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req EmergencyContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.UpdateEmergencyContact(userID, req.Name, req.Phone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "紧急联系人已更新"})
+}
+
 // ListStudents 列出自己管理的部门或班级的学生
 func (h *UserHandler) ListStudents(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -39,12 +39,19 @@ type OrgUnit struct {
 	Name      string    `gorm:"size:100;not null"`
 	Type      string    `gorm:"size:30;not null"` // school, college, grade, class
 	ParentID  *uint     `gorm:"index"`
-	Path      string    `gorm:"size:255;index"` // materialized path, e.g., /1/5/9
+	Path      string    `gorm:"size:255;index"` // materialized path including self, e.g., /1/5/9
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+func (o *OrgUnit) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.UUID == uuid.Nil {
+		o.UUID = uuid.New()
+	}
+	return
+}
+
 // User represents login identity.
 type User struct {
 	ID       uint   `gorm:"primaryKey"`
@@ -57,9 +64,13 @@ type User struct {
 	StaffNo      *string `gorm:"size:50"`  // for admins/teachers/counselors
 	StudentNo    *string `gorm:"size:50"`  // for students
 	PushToken    string  `gorm:"size:255"` // for push notifications
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	PushPlatform string  `gorm:"size:20"`  // fcm, apns；为空按 fcm 处理
+	// 紧急联系人，用于逾期未返校等情况的升级通知
+	EmergencyContactName  string `gorm:"size:100"`
+	EmergencyContactPhone string `gorm:"size:30"`
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	DeletedAt             gorm.DeletedAt `gorm:"index"`
 
 	Role Role `gorm:"foreignKey:RoleID"`
 	//Department Department `gorm:"foreignKey:DepartmentID"`
@@ -77,6 +88,7 @@ type Department struct {
 	Name        string `gorm:"size:100;not null"`
 	InviteCode  string `gorm:"size:12;uniqueIndex;not null"`
 	CounselorID uint   `gorm:"index"`
+	OrgUnitID   *uint  `gorm:"index"` // 所属的学院或年级节点
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
@@ -93,6 +105,7 @@ type Class struct {
 	Name       string `gorm:"size:100;not null"`
 	InviteCode string `gorm:"size:12;uniqueIndex;not null"`
 	TeacherID  uint   `gorm:"index"`
+	OrgUnitID  *uint  `gorm:"index"` // 对应的班级节点
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	DeletedAt  gorm.DeletedAt `gorm:"index"`
@@ -142,10 +155,80 @@ type Notification struct {
 	Title      string `gorm:"size:100;not null"`  // 标题
 	Content    string `gorm:"type:text;not null"` // 内容
 	SenderID   uint   `gorm:"index"`              // 发送者ID
-	TargetType string `gorm:"size:20;not null"`   // dept 或 class
-	TargetID   uint   `gorm:"index"`              // 目标部门或班级ID
-	CreatedAt  time.Time
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	TargetType string `gorm:"size:20;not null"`   // dept, class, student, user, audience (多目标，见 Audience)
+	TargetID   uint   `gorm:"index"`              // 目标部门、班级或用户ID
+	// Category 消息分类：announcement, ding, leave, task, system；RefType/RefID 指向关联的打卡任务、请假或任务，供客户端跳转
+	Category string `gorm:"size:20;not null;default:announcement;index"`
+	RefType  string `gorm:"size:20"`
+	RefID    uint
+	// RequireAck 需要接收人明确确认“已阅读并知悉”，未确认者会被定期提醒
+	RequireAck        bool `gorm:"not null;default:false"`
+	Urgent            bool `gorm:"not null;default:false"` // 紧急通知不受免打扰时段限制
+	AckReminders      int  `gorm:"not null;default:0"`     // 已发送的确认提醒次数
+	LastAckReminderAt *time.Time
+	AckCompletedAt    *time.Time // 所有接收人均已确认的时间，之后不再提醒
+	// Status draft, scheduled, published, cancelled, completed, recalled；只有 published 对接收人可见。
+	// 一次性定时通知到期后原地发布；周期通知每次到期生成一条新的已发布通知，自身保持 scheduled 直到截止后变为 completed。
+	Status          string `gorm:"size:20;not null;default:published;index"`
+	ScheduledAt     *time.Time
+	Recurrence      string `gorm:"size:20"` // daily, weekly, monthly；为空表示不重复
+	RecurrenceUntil *time.Time
+	RecurrenceDay   int   `gorm:"not null;default:0"` // 按月重复的日期，取首次发送日；当月没有这一天时在月末发送
+	ScheduleID      *uint `gorm:"index"`              // 由周期通知生成时指向原定时通知
+	// Format 内容格式：text 或 markdown；Attachments 为图片和文件附件列表的 JSON
+	Format      string `gorm:"size:10;not null;default:text"`
+	Attachments string `gorm:"type:text"`
+	// Version 从 1 开始，发布后每次修改加一并在 NotificationVersion 中保留旧版本
+	Version    int `gorm:"not null;default:1"`
+	EditedAt   *time.Time
+	RecalledAt *time.Time             // 撤回后 Status 为 recalled，接收人不再可见
+	Audience   []NotificationAudience `gorm:"foreignKey:NotificationID"`
+	CreatedAt  time.Time              // 发布时间，定时通知发布时更新
+	DeletedAt  gorm.DeletedAt         `gorm:"index"`
+}
+
+// NotificationRead 通知的已读回执，每个接收人首次阅读时写入
+type NotificationRead struct {
+	ID             uint       `gorm:"primaryKey"`
+	NotificationID uint       `gorm:"uniqueIndex:idx_notif_read;not null"`
+	UserID         uint       `gorm:"uniqueIndex:idx_notif_read;index;not null"`
+	ReadAt         time.Time  `gorm:"not null"`
+	AckedAt        *time.Time // 确认知悉时间，仅 RequireAck 的通知使用
+}
+
+// NotificationAudience 多目标通知的受众组成。TargetType 为 dept, class, student, user, role 或 school (TargetID 为 0)；
+// 接收人为各部分的并集，同一学生只收到一次。
+type NotificationAudience struct {
+	ID             uint   `gorm:"primaryKey"`
+	NotificationID uint   `gorm:"index;not null"`
+	TargetType     string `gorm:"size:20;not null;index:idx_audience_target,priority:1"`
+	TargetID       uint   `gorm:"index:idx_audience_target,priority:2"`
+}
+
+// NotificationVersion 已发布通知被修改前的版本
+type NotificationVersion struct {
+	ID             uint   `gorm:"primaryKey"`
+	NotificationID uint   `gorm:"uniqueIndex:idx_notification_version;not null"`
+	Version        int    `gorm:"uniqueIndex:idx_notification_version;not null"`
+	Title          string `gorm:"size:100;not null"`
+	Content        string `gorm:"type:text;not null"`
+	Format         string `gorm:"size:10;not null"`
+	Attachments    string `gorm:"type:text"`
+	EditorID       uint   // 修改者，即产生下一版本的用户
+	CreatedAt      time.Time
+}
+
+// NotificationOutbox 通知投递任务，与通知在同一事务中写入，由后台任务投递并按指数退避重试
+type NotificationOutbox struct {
+	ID             uint      `gorm:"primaryKey"`
+	NotificationID uint      `gorm:"uniqueIndex;not null"`
+	Status         string    `gorm:"size:20;not null;index:idx_outbox_due,priority:1"` // pending, delivering, delivered, dead
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"index:idx_outbox_due,priority:2"`
+	LastError      string    `gorm:"size:255"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 // LeaveRequest 请假申请
@@ -156,29 +239,58 @@ type LeaveRequest struct {
 	StartTime time.Time `gorm:"not null"`
 	EndTime   time.Time `gorm:"not null"`
 	Reason    string    `gorm:"size:255"`
-	Status    string    `gorm:"size:20;default:'pending'"` // pending, approved, rejected, active, completed, overdue
-	AuditorID *uint     `gorm:"index"`                     // 审批人(辅导员)
-	DingId    uint      `gorm:"index"`                     // 关联的打卡任务ID
-	AuditTime *time.Time
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	// 附件地址列表 (JSON 数组)
+	Attachments string `gorm:"type:text"`
+	// 学生是否同意任课教师查看请假原因和附件
+	ShareWithTeachers bool   `gorm:"default:false"`
+	Status            string `gorm:"size:20;default:'pending'"` // pending, approved, rejected, cancelled, active, completed, overdue
+	AuditorID         *uint  `gorm:"index"`                     // 审批人(辅导员)
+	// 代审时记录被代理的辅导员，即 "由 AuditorID 代 OnBehalfOfID 审批"
+	OnBehalfOfID *uint  `gorm:"index"`
+	AuditComment string `gorm:"size:255"` // 审批意见
+	DingId       uint   `gorm:"index"`    // 关联的打卡任务ID
+	AuditTime    *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// ApprovalDelegation 审批委托 (辅导员外出期间将请假审批权临时委托给其他教职工)
+type ApprovalDelegation struct {
+	ID           uint      `gorm:"primaryKey"`
+	DelegatorID  uint      `gorm:"index;not null"` // 委托人(辅导员)
+	DelegateID   uint      `gorm:"index;not null"` // 受托人
+	DepartmentID uint      `gorm:"index"`          // 委托的部门，0 表示委托人名下全部部门
+	StartTime    time.Time `gorm:"not null"`
+	EndTime      time.Time `gorm:"not null"` // 过期后自动失效
+	Reason       string    `gorm:"size:255"`
+	RevokedAt    *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
 }
 
-// Task 任务 (签到/查寝)
+// Task 任务 (签到/查寝/问卷/文件提交)
 type Task struct {
 	ID          uint      `gorm:"primaryKey"`
 	UUID        uuid.UUID `gorm:"type:char(36);uniqueIndex"` // Added UUID
 	Title       string    `gorm:"size:100;not null"`
-	Type        string    `gorm:"size:50;not null"` // sign_in, dorm_check, leave_check
+	Type        string    `gorm:"size:50;not null"` // sign_in, dorm_check, survey, file_submission
 	Description string    `gorm:"size:255"`
 	CreatorID   uint      `gorm:"index"`   // 发布者
 	TargetType  string    `gorm:"size:20"` // dept, class
 	TargetID    uint      `gorm:"index"`
 	Deadline    time.Time `gorm:"not null"`
-	Config      string    `gorm:"type:json"` // 任务配置：如签到经纬度、距离限制等
-	CreatedAt   time.Time
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Config      string    `gorm:"type:json"` // 任务配置，结构由任务类型决定 (见 service/taskConfig.go)
+	// LatePolicy 截止后的提交：reject 拒绝，accept_late 接受并标记逾期，cutoff 在 LateCutoff 前接受并标记逾期
+	LatePolicy string `gorm:"size:20;not null;default:reject"`
+	LateCutoff *time.Time
+	// Assigned 已生成分派记录；分派功能上线前发布的任务为 false，补发分派后置为 true
+	Assigned bool `gorm:"not null;default:false"`
+	// LastRemindedAt 发布者最近一次催交的时间，用于限制催交频率
+	LastRemindedAt *time.Time
+	CreatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
 }
 
 func (t *Task) BeforeCreate(tx *gorm.DB) (err error) {
@@ -188,12 +300,48 @@ func (t *Task) BeforeCreate(tx *gorm.DB) (err error) {
 
 // TaskRecord 任务记录 (学生提交)
 type TaskRecord struct {
-	ID        uint      `gorm:"primaryKey"`
-	TaskID    uint      `gorm:"index;not null"`
-	StudentID uint      `gorm:"index;not null"`
-	Status    string    `gorm:"size:20"`   // completed, late
-	Data      string    `gorm:"type:json"` // 提交的数据：location, photo_url
-	CreatedAt time.Time // 提交时间
+	ID        uint   `gorm:"primaryKey"`
+	TaskID    uint   `gorm:"index;not null"`
+	StudentID uint   `gorm:"index;not null"`
+	Status    string `gorm:"size:20"`                // submitted (早期记录为 completed), returned, accepted
+	Data      string `gorm:"type:json"`              // 提交的数据，按任务类型校验后保存
+	Late      bool   `gorm:"not null;default:false"` // 截止后提交
+	// Version 从 1 开始，被退回后每次重新提交加一并在 TaskRecordVersion 中保留旧版本
+	Version    int      `gorm:"not null;default:1"`
+	Feedback   string   `gorm:"type:text"` // 发布者的审阅意见
+	Score      *float64 // 可选评分
+	ReviewerID *uint
+	ReviewedAt *time.Time
+	CreatedAt  time.Time // 提交时间，重新提交时更新
+}
+
+// TaskAssignment 任务分派到学生的记录，发布时按发布对象生成；之后加入发布对象的学生按配置补发
+type TaskAssignment struct {
+	ID         uint      `gorm:"primaryKey"`
+	TaskID     uint      `gorm:"uniqueIndex:idx_task_assignment;not null"`
+	StudentID  uint      `gorm:"uniqueIndex:idx_task_assignment;index:idx_task_assignment_student,priority:1;not null"`
+	Status     string    `gorm:"size:20;not null;default:pending"` // pending 未提交，其余与提交记录的状态一致
+	Late       bool      `gorm:"not null;default:false"`
+	AssignedAt time.Time `gorm:"not null"`
+	// DueAt 该学生的截止时间，一般为任务截止时间，后加入的学生可能被顺延
+	DueAt     time.Time `gorm:"index:idx_task_assignment_student,priority:2;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TaskRecordVersion 重新提交前的提交版本及其审阅结果
+type TaskRecordVersion struct {
+	ID          uint   `gorm:"primaryKey"`
+	RecordID    uint   `gorm:"uniqueIndex:idx_task_record_version;not null"`
+	Version     int    `gorm:"uniqueIndex:idx_task_record_version;not null"`
+	Status      string `gorm:"size:20"`
+	Data        string `gorm:"type:json"`
+	Feedback    string `gorm:"type:text"`
+	Score       *float64
+	ReviewerID  *uint
+	ReviewedAt  *time.Time
+	SubmittedAt time.Time
+	CreatedAt   time.Time // 被新版本替换的时间
 }
 
 // 打卡任务实体
@@ -227,13 +375,138 @@ type DingStudent struct {
 	UpdatedAt     time.Time
 }
 
+// LeaveEscalation 逾期未返校提醒记录，每条对应一级提醒中的一个通知对象
+type LeaveEscalation struct {
+	ID          uint   `gorm:"primaryKey"`
+	LeaveID     uint   `gorm:"index:idx_leave_level;not null"`
+	Level       int    `gorm:"index:idx_leave_level;not null"` // 第几级提醒，从 1 开始
+	Recipient   string `gorm:"size:30;not null"`               // student, counselor, admin, emergency_contact
+	RecipientID uint   `gorm:"index"`                          // 通知到的用户ID，紧急联系人为 0
+	Status      string `gorm:"size:20;not null"`               // sent, failed, skipped, pending(待人工联系)
+	Detail      string `gorm:"size:255"`
+	CreatedAt   time.Time
+}
+
+// ExitPass 请假批准后签发的电子出门凭证，请假取消时自动吊销
+type ExitPass struct {
+	ID         uint      `gorm:"primaryKey"`
+	LeaveID    uint      `gorm:"uniqueIndex;not null"`
+	StudentID  uint      `gorm:"index;not null"`
+	ValidFrom  time.Time `gorm:"not null"`
+	ValidUntil time.Time `gorm:"not null"`
+	Payload    string    `gorm:"type:text;not null"` // 已签名的二维码内容
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// PushAttempt 每个接收人在某一渠道的一次投递记录 (推送为设备令牌，邮件为邮箱地址)
+type PushAttempt struct {
+	ID             uint   `gorm:"primaryKey"`
+	NotificationID uint   `gorm:"index"`
+	UserID         uint   `gorm:"index;not null"`
+	Channel        string `gorm:"size:20;not null;default:push"` // push, email
+	Provider       string `gorm:"size:20;not null"`
+	Token          string `gorm:"size:255"`
+	Status         string `gorm:"size:20;not null"` // sent, failed, invalid_token, skipped, no_token, muted, recalled (撤回推送已送达)
+	Error          string `gorm:"size:255"`
+	CreatedAt      time.Time
+}
+
+// NotificationPreference 用户对某一类消息的接收渠道设置，没有记录时推送和站内可见、不发邮件
+type NotificationPreference struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"uniqueIndex:idx_notif_pref;not null"`
+	Category string `gorm:"uniqueIndex:idx_notif_pref;size:20;not null"` // announcement, ding, leave, task, system
+	Push     bool   `gorm:"not null;default:true"`
+	Email    bool   `gorm:"not null;default:false"`
+	InApp    bool   `gorm:"not null;default:true"` // 关闭后该类消息不出现在收件箱 (需确认的通知除外)
+}
+
+// NotificationSetting 用户的免打扰设置，QuietStart/QuietEnd 为所在时区的 "HH:MM"，可跨零点
+type NotificationSetting struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"uniqueIndex;not null"`
+	QuietEnabled bool   `gorm:"not null;default:false"`
+	QuietStart   string `gorm:"size:5"`
+	QuietEnd     string `gorm:"size:5"`
+	Timezone     string `gorm:"size:64"` // IANA 时区，为空按 Asia/Shanghai
+	Locale       string `gorm:"size:10"` // 系统消息的语言，为空按 zh-CN
+	UpdatedAt    time.Time
+}
+
+// NotificationTemplate 通知模板，OwnerID 为 0 的是系统消息模板。
+// 同一 Key 可有多个语言版本；Title/Content 中的 {{name}} 占位符在发送时替换，
+// {{student_name}}、{{student_no}} 按接收人分别渲染。
+type NotificationTemplate struct {
+	ID        uint   `gorm:"primaryKey"`
+	Key       string `gorm:"uniqueIndex:idx_template_key;size:100;not null"`
+	Locale    string `gorm:"uniqueIndex:idx_template_key;size:10;not null"`
+	OwnerID   uint   `gorm:"uniqueIndex:idx_template_key;not null"`
+	Name      string `gorm:"size:100"`
+	Category  string `gorm:"size:20;not null;default:announcement"`
+	Title     string `gorm:"size:100;not null"`
+	Content   string `gorm:"type:text;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CalendarFeed 用户的 iCalendar 订阅令牌，撤销或重新生成后旧地址即失效
+type CalendarFeed struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"uniqueIndex;not null"`
+	Token     string `gorm:"size:64;uniqueIndex;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// HolidayCampaign 假期离校登记活动，由辅导员或管理员发起
+type HolidayCampaign struct {
+	ID         uint      `gorm:"primaryKey"`
+	Title      string    `gorm:"size:100;not null"`
+	CreatorID  uint      `gorm:"index;not null"`
+	StartTime  time.Time `gorm:"not null"` // 登记开放时间
+	EndTime    time.Time `gorm:"not null"` // 登记截止时间
+	ReturnDate time.Time `gorm:"not null"` // 统一返校日期，当天生成返校签到
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+
+	Departments []HolidayCampaignDepartment `gorm:"foreignKey:CampaignID"`
+}
+
+// HolidayCampaignDepartment 登记活动覆盖的部门及该部门的返校签到任务
+type HolidayCampaignDepartment struct {
+	ID           uint `gorm:"primaryKey"`
+	CampaignID   uint `gorm:"index;not null"`
+	DepartmentID uint `gorm:"index;not null"`
+	DingID       uint `gorm:"index"`
+	Skipped      bool `gorm:"not null;default:false"` // 部门无学生等原因不生成返校签到
+}
+
+// HolidayRegistration 学生离校登记
+type HolidayRegistration struct {
+	ID            uint      `gorm:"primaryKey"`
+	CampaignID    uint      `gorm:"uniqueIndex:idx_campaign_student;not null"`
+	StudentID     uint      `gorm:"uniqueIndex:idx_campaign_student;not null"`
+	Destination   string    `gorm:"size:255;not null"` // 去向
+	Transport     string    `gorm:"size:50;not null"`  // 交通方式
+	DepartureTime time.Time `gorm:"not null"`
+	ReturnTime    time.Time `gorm:"not null"` // 预计返校时间
+	Remark        string    `gorm:"size:255"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
 // AutoMigrate migrates all models.
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&Role{}, &Permission{}, &OrgUnit{}, &User{}, &RolePermission{},
 		&Department{}, &Class{}, &StudentDepartment{}, &StudentClass{},
 		&Developer{}, &App{},
-		&Notification{}, &LeaveRequest{}, &Task{}, &TaskRecord{},
+		&Notification{}, &LeaveRequest{}, &ApprovalDelegation{}, &LeaveEscalation{}, &Task{}, &TaskRecord{}, &TaskRecordVersion{}, &TaskAssignment{},
 		&Ding{}, &DingStudent{},
+		&HolidayCampaign{}, &HolidayCampaignDepartment{}, &HolidayRegistration{},
+		&CalendarFeed{}, &ExitPass{}, &PushAttempt{}, &NotificationOutbox{}, &NotificationRead{}, &NotificationPreference{}, &NotificationSetting{}, &NotificationTemplate{}, &NotificationVersion{}, &NotificationAudience{},
 	)
 }
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type CalendarRepository interface {
+	GetFeedByUserID(userID uint) (*model.CalendarFeed, error)
+	GetFeedByToken(token string) (*model.CalendarFeed, error)
+	SaveFeed(feed *model.CalendarFeed) error
+	DeleteFeedByUserID(userID uint) error
+}
+
+type calendarRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarRepository(db *gorm.DB) CalendarRepository {
+	return &calendarRepository{db: db}
+}
+
+func (r *calendarRepository) GetFeedByUserID(userID uint) (*model.CalendarFeed, error) {
+	var feed model.CalendarFeed
+	if err := r.db.Where("user_id = ?", userID).First(&feed).Error; err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *calendarRepository) GetFeedByToken(token string) (*model.CalendarFeed, error) {
+	var feed model.CalendarFeed
+	if err := r.db.Where("token = ?", token).First(&feed).Error; err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *calendarRepository) SaveFeed(feed *model.CalendarFeed) error {
+	return r.db.Save(feed).Error
+}
+
+func (r *calendarRepository) DeleteFeedByUserID(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.CalendarFeed{}).Error
+}
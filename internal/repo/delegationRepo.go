@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"time"
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type DelegationRepository interface {
+	CreateDelegation(d *model.ApprovalDelegation) error
+	GetDelegationByID(id uint) (*model.ApprovalDelegation, error)
+	UpdateDelegation(d *model.ApprovalDelegation) error
+	ListDelegationsByDelegatorID(delegatorID uint) ([]model.ApprovalDelegation, error)
+	ListDelegationsByDelegateID(delegateID uint) ([]model.ApprovalDelegation, error)
+	ListActiveDelegationsByDelegateID(delegateID uint, at time.Time) ([]model.ApprovalDelegation, error)
+	FindActiveDelegation(delegatorID, delegateID, deptID uint, at time.Time) (*model.ApprovalDelegation, error)
+}
+
+type delegationRepository struct {
+	db *gorm.DB
+}
+
+func NewDelegationRepository(db *gorm.DB) DelegationRepository {
+	return &delegationRepository{db: db}
+}
+
+func (r *delegationRepository) CreateDelegation(d *model.ApprovalDelegation) error {
+	return r.db.Create(d).Error
+}
+
+func (r *delegationRepository) GetDelegationByID(id uint) (*model.ApprovalDelegation, error) {
+	var d model.ApprovalDelegation
+	if err := r.db.First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *delegationRepository) UpdateDelegation(d *model.ApprovalDelegation) error {
+	return r.db.Save(d).Error
+}
+
+func (r *delegationRepository) ListDelegationsByDelegatorID(delegatorID uint) ([]model.ApprovalDelegation, error) {
+	var list []model.ApprovalDelegation
+	err := r.db.Where("delegator_id = ?", delegatorID).Order("start_time desc").Find(&list).Error
+	return list, err
+}
+
+func (r *delegationRepository) ListDelegationsByDelegateID(delegateID uint) ([]model.ApprovalDelegation, error) {
+	var list []model.ApprovalDelegation
+	err := r.db.Where("delegate_id = ?", delegateID).Order("start_time desc").Find(&list).Error
+	return list, err
+}
+
+// ListActiveDelegationsByDelegateID 查询在 at 时刻对受托人生效的委托 (未撤销且在有效期内)
+func (r *delegationRepository) ListActiveDelegationsByDelegateID(delegateID uint, at time.Time) ([]model.ApprovalDelegation, error) {
+	var list []model.ApprovalDelegation
+	err := r.db.Where("delegate_id = ? AND revoked_at IS NULL AND start_time <= ? AND end_time >= ?", delegateID, at, at).
+		Find(&list).Error
+	return list, err
+}
+
+// FindActiveDelegation 查询委托人对受托人在指定部门上生效的委托，部门为 0 的委托覆盖全部部门
+func (r *delegationRepository) FindActiveDelegation(delegatorID, delegateID, deptID uint, at time.Time) (*model.ApprovalDelegation, error) {
+	var d model.ApprovalDelegation
+	if err := r.db.Where("delegator_id = ? AND delegate_id = ? AND (department_id = 0 OR department_id = ?)", delegatorID, delegateID, deptID).
+		Where("revoked_at IS NULL AND start_time <= ? AND end_time >= ?", at, at).
+		First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"log"
 	"strconv"
+	"time"
 	"unihub/internal/model"
 
 	"gorm.io/gorm"
@@ -16,6 +17,16 @@ type DingRepository interface {
 	GetDingRecordsByDingID(dingId string) (interface{}, interface{})
 	UpdateDingStudent(dingId string, userId uint) (interface{}, interface{})
 	GetDingStats(launcherID uint) (int64, int64, error)
+	ListDingsWithStatusByStudentID(studentID uint, endAfter time.Time) ([]DingWithStatus, error)
+	ListDingsByLauncherSince(launcherID uint, endAfter time.Time) ([]model.Ding, error)
+	UpdateDingStudentStatus(dingID, studentID uint, status string) error
+}
+
+// DingWithStatus 打卡任务及某个学生的打卡状态
+type DingWithStatus struct {
+	model.Ding
+	DingStatus          string
+	DingStatusUpdatedAt time.Time // 学生打卡记录的更新时间 (打卡、取消)
 }
 
 type dingRepository struct {
@@ -111,3 +122,27 @@ func (r *dingRepository) GetDingStats(launcherID uint) (int64, int64, error) {
 	log.Printf(strconv.FormatInt(checked, 10), total)
 	return total, checked, nil
 }
+
+// ListDingsWithStatusByStudentID 查询学生结束时间晚于 endAfter 的打卡任务及其打卡状态
+func (r *dingRepository) ListDingsWithStatusByStudentID(studentID uint, endAfter time.Time) ([]DingWithStatus, error) {
+	var rows []DingWithStatus
+	err := r.db.Model(&model.Ding{}).
+		Select("dings.*, ding_students.status as ding_status, ding_students.updated_at as ding_status_updated_at").
+		Joins("JOIN ding_students ON ding_students.ding_id = dings.id").
+		Where("ding_students.student_id = ? AND dings.end_time > ?", studentID, endAfter).
+		Order("dings.start_time").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *dingRepository) ListDingsByLauncherSince(launcherID uint, endAfter time.Time) ([]model.Ding, error) {
+	var dings []model.Ding
+	err := r.db.Where("launcher_id = ? AND end_time > ?", launcherID, endAfter).Order("start_time").Find(&dings).Error
+	return dings, err
+}
+
+func (r *dingRepository) UpdateDingStudentStatus(dingID, studentID uint, status string) error {
+	return r.db.Model(&model.DingStudent{}).
+		Where("ding_id = ? AND student_id = ?", dingID, studentID).
+		Update("status", status).Error
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"time"
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ExitPassRepository interface {
+	CreatePass(pass *model.ExitPass) error
+	UpdatePass(pass *model.ExitPass) error
+	GetPassByID(id uint) (*model.ExitPass, error)
+	GetPassByLeaveID(leaveID uint) (*model.ExitPass, error)
+	RevokePassByLeaveID(leaveID uint, at time.Time) error
+}
+
+type exitPassRepository struct {
+	db *gorm.DB
+}
+
+func NewExitPassRepository(db *gorm.DB) ExitPassRepository {
+	return &exitPassRepository{db: db}
+}
+
+func (r *exitPassRepository) CreatePass(pass *model.ExitPass) error {
+	return r.db.Create(pass).Error
+}
+
+func (r *exitPassRepository) UpdatePass(pass *model.ExitPass) error {
+	return r.db.Save(pass).Error
+}
+
+func (r *exitPassRepository) GetPassByID(id uint) (*model.ExitPass, error) {
+	var pass model.ExitPass
+	if err := r.db.First(&pass, id).Error; err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+func (r *exitPassRepository) GetPassByLeaveID(leaveID uint) (*model.ExitPass, error) {
+	var pass model.ExitPass
+	if err := r.db.Where("leave_id = ?", leaveID).First(&pass).Error; err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+// RevokePassByLeaveID 吊销请假对应的凭证，没有凭证或已吊销时不做修改
+func (r *exitPassRepository) RevokePassByLeaveID(leaveID uint, at time.Time) error {
+	return r.db.Model(&model.ExitPass{}).
+		Where("leave_id = ? AND revoked_at IS NULL", leaveID).
+		Update("revoked_at", at).Error
+}
@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"time"
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HolidayRepository interface {
+	CreateCampaign(campaign *model.HolidayCampaign) error
+	GetCampaignByID(id uint) (*model.HolidayCampaign, error)
+	UpdateCampaignDepartment(cd *model.HolidayCampaignDepartment) error
+	CreateReturnDing(cd *model.HolidayCampaignDepartment, ding *model.Ding, studentIDs []uint) (bool, error)
+	ListCampaignsByCreatorID(creatorID uint) ([]model.HolidayCampaign, error)
+	ListCampaignsByDepartmentID(deptID uint) ([]model.HolidayCampaign, error)
+	GetRegistration(campaignID, studentID uint) (*model.HolidayRegistration, error)
+	SaveRegistration(reg *model.HolidayRegistration) error
+	ListRegistrationsWithStudents(campaignID uint) ([]map[string]interface{}, error)
+	ListDueReturnDepartments(before time.Time) ([]model.HolidayCampaignDepartment, error)
+}
+
+type holidayRepository struct {
+	db *gorm.DB
+}
+
+func NewHolidayRepository(db *gorm.DB) HolidayRepository {
+	return &holidayRepository{db: db}
+}
+
+// CreateCampaign 创建活动，关联的部门随活动一并写入
+func (r *holidayRepository) CreateCampaign(campaign *model.HolidayCampaign) error {
+	return r.db.Create(campaign).Error
+}
+
+func (r *holidayRepository) GetCampaignByID(id uint) (*model.HolidayCampaign, error) {
+	var campaign model.HolidayCampaign
+	if err := r.db.Preload("Departments").First(&campaign, id).Error; err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *holidayRepository) UpdateCampaignDepartment(cd *model.HolidayCampaignDepartment) error {
+	return r.db.Save(cd).Error
+}
+
+// CreateReturnDing 在同一事务中创建返校签到、签到学生并回写到活动部门，部门已生成过时返回 false
+func (r *holidayRepository) CreateReturnDing(cd *model.HolidayCampaignDepartment, ding *model.Ding, studentIDs []uint) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked model.HolidayCampaignDepartment
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ding_id = 0", cd.ID).
+			Limit(1).Find(&locked)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Create(ding).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		students := make([]model.DingStudent, len(studentIDs))
+		for i, id := range studentIDs {
+			students[i] = model.DingStudent{DingID: ding.ID, StudentID: id, Status: "pending", DingTime: now}
+		}
+		if err := tx.CreateInBatches(students, 500).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&locked).Update("ding_id", ding.ID).Error; err != nil {
+			return err
+		}
+		created = true
+		cd.DingID = ding.ID
+		return nil
+	})
+	return created, err
+}
+
+func (r *holidayRepository) ListCampaignsByCreatorID(creatorID uint) ([]model.HolidayCampaign, error) {
+	var list []model.HolidayCampaign
+	err := r.db.Preload("Departments").Where("creator_id = ?", creatorID).Order("created_at desc").Find(&list).Error
+	return list, err
+}
+
+func (r *holidayRepository) ListCampaignsByDepartmentID(deptID uint) ([]model.HolidayCampaign, error) {
+	var list []model.HolidayCampaign
+	err := r.db.Joins("JOIN holiday_campaign_departments hcd ON hcd.campaign_id = holiday_campaigns.id").
+		Where("hcd.department_id = ?", deptID).
+		Order("holiday_campaigns.start_time desc").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *holidayRepository) GetRegistration(campaignID, studentID uint) (*model.HolidayRegistration, error) {
+	var reg model.HolidayRegistration
+	if err := r.db.Where("campaign_id = ? AND student_id = ?", campaignID, studentID).First(&reg).Error; err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+// SaveRegistration 新增或覆盖学生的登记信息
+func (r *holidayRepository) SaveRegistration(reg *model.HolidayRegistration) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "campaign_id"}, {Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"destination", "transport", "departure_time", "return_time", "remark", "updated_at"}),
+	}).Create(reg).Error
+}
+
+func (r *holidayRepository) ListRegistrationsWithStudents(campaignID uint) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	err := r.db.Table("holiday_registrations").
+		Select("holiday_registrations.*, users.nickname as student_name, users.student_no").
+		Joins("JOIN users ON holiday_registrations.student_id = users.id").
+		Where("holiday_registrations.campaign_id = ?", campaignID).
+		Order("holiday_registrations.created_at").
+		Scan(&results).Error
+	return results, err
+}
+
+// ListDueReturnDepartments 查询返校日期已到但尚未生成返校签到的活动部门
+func (r *holidayRepository) ListDueReturnDepartments(before time.Time) ([]model.HolidayCampaignDepartment, error) {
+	var list []model.HolidayCampaignDepartment
+	err := r.db.Joins("JOIN holiday_campaigns hc ON hc.id = holiday_campaign_departments.campaign_id").
+		Where("hc.deleted_at IS NULL AND hc.return_date <= ? AND holiday_campaign_departments.ding_id = 0 AND holiday_campaign_departments.skipped = ?", before, false).
+		Find(&list).Error
+	return list, err
+}
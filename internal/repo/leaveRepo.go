@@ -1,6 +1,8 @@
 package repo
 
 import (
+	"fmt"
+	"time"
 	"unihub/internal/model"
 
 	"gorm.io/gorm"
@@ -16,6 +18,89 @@ type LeaveRepository interface {
 	ListApprovedLeavesWithStudentsByStudents(students []model.User) (interface{}, interface{})
 	ListLeavesWithStudentsByStudentsByDingStatusBeforeEnd(students []model.User, ding_status string) (interface{}, interface{})
 	ListLeavesWithStudentsByStudentsAfterEnd(students []model.User) (interface{}, interface{})
+	QueryLeavesWithStudents(filter LeaveQueryFilter) ([]map[string]interface{}, int64, error)
+	ListOverdueLeavesForEscalation(level int, endBefore time.Time, endAfter *time.Time) ([]model.LeaveRequest, error)
+	CreateEscalation(e *model.LeaveEscalation) error
+	ListEscalationsByLeaveID(leaveID uint) ([]model.LeaveEscalation, error)
+	LeaveStatsBy(dimension string, f LeaveReportFilter) ([]LeaveStatRow, error)
+	LeaveTurnaroundByDepartment(f LeaveReportFilter) ([]LeaveTurnaroundRow, error)
+	TopStudentsByLeaveDays(f LeaveReportFilter, limit int) ([]StudentLeaveDaysRow, error)
+	ListLeavesOverlapping(studentIDs []uint, statuses []string, from, to time.Time) ([]LeaveWithStudent, error)
+	ListLeavesForCalendar(studentIDs []uint, endAfter time.Time, auditedOnly bool) ([]LeaveWithStudent, error)
+}
+
+// LeaveWithStudent 请假记录及学生基本信息
+type LeaveWithStudent struct {
+	model.LeaveRequest
+	StudentName string
+	StudentNo   *string
+}
+
+// LeaveReportFilter 请假报表的统计范围，DepartmentIDs 为 nil 表示全部部门
+type LeaveReportFilter struct {
+	DepartmentIDs []uint
+	From          *time.Time
+	To            *time.Time
+}
+
+// LeaveStatRow 按某一维度汇总的请假数量与天数 (天数只统计已批准的请假)
+type LeaveStatRow struct {
+	Key           string  `json:"key"`
+	Count         int64   `json:"count"`
+	ApprovedCount int64   `json:"approved_count"`
+	RejectedCount int64   `json:"rejected_count"`
+	TotalDays     float64 `json:"total_days"`
+}
+
+// LeaveTurnaroundRow 各部门审批时效 (小时)
+type LeaveTurnaroundRow struct {
+	DepartmentID   uint    `json:"department_id"`
+	DepartmentName string  `json:"department_name"`
+	AuditedCount   int64   `json:"audited_count"`
+	AvgHours       float64 `json:"avg_hours"`
+	MaxHours       float64 `json:"max_hours"`
+}
+
+// StudentLeaveDaysRow 学生请假天数排行
+type StudentLeaveDaysRow struct {
+	StudentID   uint    `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	StudentNo   *string `json:"student_no"`
+	LeaveCount  int64   `json:"leave_count"`
+	TotalDays   float64 `json:"total_days"`
+}
+
+// leaveReportDimensions 报表维度对应的分组表达式
+var leaveReportDimensions = map[string]string{
+	"type":       "leave_requests.type",
+	"department": "departments.name",
+	"week":       "DATE_FORMAT(leave_requests.start_time, '%x-W%v')",
+	"month":      "DATE_FORMAT(leave_requests.start_time, '%Y-%m')",
+}
+
+const leaveMinutesExpr = "TIMESTAMPDIFF(MINUTE, leave_requests.start_time, leave_requests.end_time)"
+
+// LeaveQueryFilter 请假队列查询条件，零值字段表示不过滤
+type LeaveQueryFilter struct {
+	DepartmentIDs []uint
+	Status        string
+	Type          string
+	From          *time.Time // 与 [From, To] 有交集的请假
+	To            *time.Time
+	MinMinutes    int // 请假时长下限(分钟)
+	MaxMinutes    int // 请假时长上限(分钟)
+	SortBy        string
+	Order         string
+	Page          int
+	PageSize      int
+}
+
+// leaveSortColumns 允许排序的字段
+var leaveSortColumns = map[string]string{
+	"created_at": "leave_requests.created_at",
+	"start_time": "leave_requests.start_time",
+	"end_time":   "leave_requests.end_time",
+	"duration":   "TIMESTAMPDIFF(MINUTE, leave_requests.start_time, leave_requests.end_time)",
 }
 
 type leaveRepository struct {
@@ -140,3 +225,187 @@ func (r *leaveRepository) ListLeavesWithStudentsByStudentsAfterEnd(students []mo
 	}
 	return leaves, nil
 }
+
+func (r *leaveRepository) QueryLeavesWithStudents(f LeaveQueryFilter) ([]map[string]interface{}, int64, error) {
+	results := []map[string]interface{}{}
+	if len(f.DepartmentIDs) == 0 {
+		return results, 0, nil
+	}
+
+	query := r.db.Model(&model.LeaveRequest{}).
+		Joins("join users on leave_requests.student_id = users.id").
+		Joins("join student_departments on student_departments.student_id = leave_requests.student_id").
+		Joins("join departments on departments.id = student_departments.department_id").
+		Where("student_departments.department_id IN ?", f.DepartmentIDs)
+	if f.Status != "" {
+		query = query.Where("leave_requests.status = ?", f.Status)
+	}
+	if f.Type != "" {
+		query = query.Where("leave_requests.type = ?", f.Type)
+	}
+	if f.From != nil {
+		query = query.Where("leave_requests.end_time >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("leave_requests.start_time <= ?", *f.To)
+	}
+	if f.MinMinutes > 0 {
+		query = query.Where("TIMESTAMPDIFF(MINUTE, leave_requests.start_time, leave_requests.end_time) >= ?", f.MinMinutes)
+	}
+	if f.MaxMinutes > 0 {
+		query = query.Where("TIMESTAMPDIFF(MINUTE, leave_requests.start_time, leave_requests.end_time) <= ?", f.MaxMinutes)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	sortCol, ok := leaveSortColumns[f.SortBy]
+	if !ok {
+		sortCol = leaveSortColumns["created_at"]
+	}
+	order := "asc"
+	if f.Order == "desc" {
+		order = "desc"
+	}
+
+	err := query.
+		Select("leave_requests.*, users.id as student_id, users.nickname as student_name, users.student_no, " +
+			"student_departments.department_id, departments.name as department_name").
+		Order(sortCol + " " + order).
+		Offset((f.Page - 1) * f.PageSize).
+		Limit(f.PageSize).
+		Scan(&results).Error
+	return results, total, err
+}
+
+// ListOverdueLeavesForEscalation 查询结束时间早于 endBefore、返校签到仍未完成且尚未进行第 level 级提醒的请假
+func (r *leaveRepository) ListOverdueLeavesForEscalation(level int, endBefore time.Time, endAfter *time.Time) ([]model.LeaveRequest, error) {
+	var leaves []model.LeaveRequest
+	query := r.db.Model(&model.LeaveRequest{}).
+		Joins("join ding_students on ding_students.ding_id = leave_requests.ding_id AND ding_students.student_id = leave_requests.student_id").
+		Where("leave_requests.status = ? AND leave_requests.end_time <= ? AND ding_students.status = ?", "approved", endBefore, "pending").
+		Where("NOT EXISTS (SELECT 1 FROM leave_escalations le WHERE le.leave_id = leave_requests.id AND le.level = ?)", level)
+	if endAfter != nil {
+		query = query.Where("leave_requests.end_time > ?", *endAfter)
+	}
+	err := query.Find(&leaves).Error
+	return leaves, err
+}
+
+func (r *leaveRepository) CreateEscalation(e *model.LeaveEscalation) error {
+	return r.db.Create(e).Error
+}
+
+func (r *leaveRepository) ListEscalationsByLeaveID(leaveID uint) ([]model.LeaveEscalation, error) {
+	var list []model.LeaveEscalation
+	err := r.db.Where("leave_id = ?", leaveID).Order("created_at").Find(&list).Error
+	return list, err
+}
+
+// reportScope 请假报表的公共查询范围：按学生所在部门和请假开始时间过滤
+func (r *leaveRepository) reportScope(f LeaveReportFilter) *gorm.DB {
+	query := r.db.Model(&model.LeaveRequest{}).
+		Joins("join student_departments on student_departments.student_id = leave_requests.student_id").
+		Joins("join departments on departments.id = student_departments.department_id")
+	if f.DepartmentIDs != nil {
+		query = query.Where("student_departments.department_id IN ?", f.DepartmentIDs)
+	}
+	if f.From != nil {
+		query = query.Where("leave_requests.start_time >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("leave_requests.start_time <= ?", *f.To)
+	}
+	return query
+}
+
+func (r *leaveRepository) LeaveStatsBy(dimension string, f LeaveReportFilter) ([]LeaveStatRow, error) {
+	rows := []LeaveStatRow{}
+	expr, ok := leaveReportDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dimension: %s", dimension)
+	}
+	if f.DepartmentIDs != nil && len(f.DepartmentIDs) == 0 {
+		return rows, nil
+	}
+
+	err := r.reportScope(f).
+		Select(expr + " AS `key`, COUNT(*) AS count, " +
+			"SUM(leave_requests.status = 'approved') AS approved_count, " +
+			"SUM(leave_requests.status = 'rejected') AS rejected_count, " +
+			"ROUND(SUM(CASE WHEN leave_requests.status = 'approved' THEN " + leaveMinutesExpr + " ELSE 0 END) / 1440, 2) AS total_days").
+		Group(expr).
+		Order("`key`").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *leaveRepository) LeaveTurnaroundByDepartment(f LeaveReportFilter) ([]LeaveTurnaroundRow, error) {
+	rows := []LeaveTurnaroundRow{}
+	if f.DepartmentIDs != nil && len(f.DepartmentIDs) == 0 {
+		return rows, nil
+	}
+
+	turnaround := "TIMESTAMPDIFF(MINUTE, leave_requests.created_at, leave_requests.audit_time) / 60"
+	err := r.reportScope(f).
+		Select("departments.id AS department_id, departments.name AS department_name, COUNT(*) AS audited_count, " +
+			"ROUND(AVG(" + turnaround + "), 2) AS avg_hours, ROUND(MAX(" + turnaround + "), 2) AS max_hours").
+		Where("leave_requests.audit_time IS NOT NULL").
+		Group("departments.id, departments.name").
+		Order("departments.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *leaveRepository) TopStudentsByLeaveDays(f LeaveReportFilter, limit int) ([]StudentLeaveDaysRow, error) {
+	rows := []StudentLeaveDaysRow{}
+	if f.DepartmentIDs != nil && len(f.DepartmentIDs) == 0 {
+		return rows, nil
+	}
+
+	err := r.reportScope(f).
+		Joins("join users on users.id = leave_requests.student_id").
+		Select("users.id AS student_id, users.nickname AS student_name, users.student_no, COUNT(*) AS leave_count, "+
+			"ROUND(SUM("+leaveMinutesExpr+") / 1440, 2) AS total_days").
+		Where("leave_requests.status = ?", "approved").
+		Group("users.id, users.nickname, users.student_no").
+		Order("total_days desc").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListLeavesOverlapping 查询与 [from, to) 有交集、状态在 statuses 中的学生请假
+func (r *leaveRepository) ListLeavesOverlapping(studentIDs []uint, statuses []string, from, to time.Time) ([]LeaveWithStudent, error) {
+	rows := []LeaveWithStudent{}
+	if len(studentIDs) == 0 {
+		return rows, nil
+	}
+	err := r.db.Model(&model.LeaveRequest{}).
+		Select("leave_requests.*, users.nickname as student_name, users.student_no").
+		Joins("join users on leave_requests.student_id = users.id").
+		Where("leave_requests.student_id IN ? AND leave_requests.status IN ?", studentIDs, statuses).
+		Where("leave_requests.start_time < ? AND leave_requests.end_time >= ?", to, from).
+		Order("leave_requests.start_time").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListLeavesForCalendar 查询用于日历订阅的请假，包含已删除的记录以便向订阅端下发取消事件
+func (r *leaveRepository) ListLeavesForCalendar(studentIDs []uint, endAfter time.Time, auditedOnly bool) ([]LeaveWithStudent, error) {
+	rows := []LeaveWithStudent{}
+	if len(studentIDs) == 0 {
+		return rows, nil
+	}
+	query := r.db.Unscoped().Model(&model.LeaveRequest{}).
+		Select("leave_requests.*, users.nickname as student_name, users.student_no").
+		Joins("join users on leave_requests.student_id = users.id").
+		Where("leave_requests.student_id IN ? AND leave_requests.end_time > ?", studentIDs, endAfter)
+	if auditedOnly {
+		query = query.Where("leave_requests.audit_time IS NOT NULL AND leave_requests.status <> ?", "rejected")
+	}
+	err := query.Order("leave_requests.start_time").Scan(&rows).Error
+	return rows, err
+}
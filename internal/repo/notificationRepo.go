@@ -1,15 +1,75 @@
 package repo
 
 import (
+	"time"
 	"unihub/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotificationRepository interface {
 	CreateNotification(notif *model.Notification) error
 	GetNotifications(targetType string, targetID uint) ([]model.Notification, error)
-	GetNotificationsForTargets(targets []model.Target) ([]model.Notification, error)
+	GetNotificationsForTargets(targets []model.Target, muted []string) ([]model.Notification, error)
+	GetNotificationByID(id uint) (*model.Notification, error)
+	CreateNotificationWithOutbox(notif *model.Notification) error
+	ListDueOutbox(now time.Time, limit int) ([]model.NotificationOutbox, error)
+	ClaimOutbox(job *model.NotificationOutbox, leaseUntil time.Time) (bool, error)
+	UpdateOutbox(job *model.NotificationOutbox) error
+	GetOutboxByNotificationID(notifID uint) (*model.NotificationOutbox, error)
+	ListRecipientDeliveries(notifID uint, userIDs []uint) ([]RecipientDelivery, error)
+	ListReadsByUser(userID uint, notifIDs []uint) ([]model.NotificationRead, error)
+	MarkRead(notifIDs []uint, userID uint) error
+	ListNotificationIDsForTargets(targets []model.Target) ([]uint, error)
+	CountUnread(userID uint, targets []model.Target, muted []string) (int64, error)
+	ListRecipientReads(notifID uint, userIDs []uint) ([]RecipientRead, error)
+	Acknowledge(notifID, userID uint) error
+	ListAckReminderDue(before time.Time, maxReminders int) ([]model.Notification, error)
+	ListAckedUserIDs(notifID uint) ([]uint, error)
+	RecordAckReminder(notifID uint, at time.Time) error
+	CompleteAckReminders(notifID uint, at time.Time) error
+	QueryInbox(targets []model.Target, filter InboxFilter) ([]model.Notification, error)
+	UpdateNotification(notif *model.Notification) error
+	ListSchedulesBySender(senderID uint) ([]model.Notification, error)
+	ListDueSchedules(now time.Time) ([]model.Notification, error)
+	PublishNotification(notif *model.Notification, now time.Time) (bool, error)
+	DispatchOccurrence(schedule *model.Notification, next *time.Time, occurrence *model.Notification) (bool, error)
+	EditNotification(notif *model.Notification, prev *model.NotificationVersion) (bool, error)
+	ListNotificationVersions(notifID uint) ([]model.NotificationVersion, error)
+	RecallNotification(notifID uint, now time.Time) (bool, error)
+	ListAudience(notifID uint) ([]model.NotificationAudience, error)
+}
+
+// InboxFilter 收件箱查询条件，Before 为游标 (上一页的最后一条)
+type InboxFilter struct {
+	Category string
+	Keyword  string
+	Before   *model.Notification
+	Limit    int
+	// Muted 用户关闭了站内显示的分类
+	Muted []string
+}
+
+// RecipientRead 接收人的阅读状态，未读时 ReadAt 为空
+type RecipientRead struct {
+	UserID    uint       `json:"user_id"`
+	Nickname  string     `json:"nickname"`
+	StudentNo *string    `json:"student_no"`
+	ReadAt    *time.Time `json:"read_at"`
+	AckedAt   *time.Time `json:"acked_at"`
+}
+
+// RecipientDelivery 单个接收人在每个渠道的最近一次投递结果，尚未投递时 Channel 和 Status 为空
+type RecipientDelivery struct {
+	UserID      uint       `json:"user_id"`
+	Channel     string     `json:"channel"`
+	Nickname    string     `json:"nickname"`
+	StudentNo   *string    `json:"student_no"`
+	Status      string     `json:"status"`
+	Provider    string     `json:"provider"`
+	Error       string     `json:"error"`
+	AttemptedAt *time.Time `json:"attempted_at"`
 }
 
 type notificationRepository struct {
@@ -30,21 +90,405 @@ func (r *notificationRepository) GetNotifications(targetType string, targetID ui
 	return notifs, err
 }
 
-func (r *notificationRepository) GetNotificationsForTargets(targets []model.Target) ([]model.Notification, error) {
+func (r *notificationRepository) GetNotificationsForTargets(targets []model.Target, muted []string) ([]model.Notification, error) {
 	if len(targets) == 0 {
 		return []model.Notification{}, nil
 	}
 
-	query := r.db.Model(&model.Notification{})
+	var notifs []model.Notification
+	err := visibleTo(r.db.Model(&model.Notification{}), targets, muted).
+		Order("created_at desc").
+		Find(&notifs).Error
+	return notifs, err
+}
+
+// visibleTo 接收人可见的通知：发给其任一目标、已发布，且不属于已关闭站内显示的分类 (需确认的通知始终可见)
+func visibleTo(query *gorm.DB, targets []model.Target, muted []string) *gorm.DB {
+	query = query.Where(targetsCondition(query, targets)).Where("status = ?", "published")
+	if len(muted) > 0 {
+		query = query.Where("category NOT IN ? OR require_ack = ?", muted, true)
+	}
+	return query
+}
+
+// targetsCondition 匹配任一目标的通知条件，包括受众中含有任一目标的多目标通知
+func targetsCondition(db *gorm.DB, targets []model.Target) *gorm.DB {
+	audience := db.Session(&gorm.Session{NewDB: true}).Model(&model.NotificationAudience{}).
+		Select("notification_id").
+		Where(matchTargets(db, targets))
+	return matchTargets(db, targets).Or("target_type = ? AND id IN (?)", "audience", audience)
+}
+
+func matchTargets(db *gorm.DB, targets []model.Target) *gorm.DB {
+	cond := db.Session(&gorm.Session{NewDB: true})
 	for i, t := range targets {
 		if i == 0 {
-			query = query.Where("target_type = ? AND target_id = ?", t.Type, t.ID)
+			cond = cond.Where("target_type = ? AND target_id = ?", t.Type, t.ID)
 		} else {
-			query = query.Or("target_type = ? AND target_id = ?", t.Type, t.ID)
+			cond = cond.Or("target_type = ? AND target_id = ?", t.Type, t.ID)
+		}
+	}
+	return cond
+}
+
+func (r *notificationRepository) GetNotificationByID(id uint) (*model.Notification, error) {
+	var notif model.Notification
+	if err := r.db.First(&notif, id).Error; err != nil {
+		return nil, err
+	}
+	return &notif, nil
+}
+
+// CreateNotificationWithOutbox 在同一事务中写入通知及其投递任务
+func (r *notificationRepository) CreateNotificationWithOutbox(notif *model.Notification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(notif).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.NotificationOutbox{
+			NotificationID: notif.ID,
+			Status:         "pending",
+			NextAttemptAt:  time.Now(),
+		}).Error
+	})
+}
+
+// ListDueOutbox 到期的投递任务，包括租约已过期的 delivering 任务 (投递中进程退出)
+func (r *notificationRepository) ListDueOutbox(now time.Time, limit int) ([]model.NotificationOutbox, error) {
+	var jobs []model.NotificationOutbox
+	err := r.db.Where("status IN ? AND next_attempt_at <= ?", []string{"pending", "delivering"}, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// ClaimOutbox 以 next_attempt_at 作为乐观锁领取任务，多实例部署时只有一个实例能领取成功
+func (r *notificationRepository) ClaimOutbox(job *model.NotificationOutbox, leaseUntil time.Time) (bool, error) {
+	res := r.db.Model(&model.NotificationOutbox{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", job.ID, job.Status, job.NextAttemptAt).
+		Updates(map[string]interface{}{"status": "delivering", "next_attempt_at": leaseUntil})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	job.Status = "delivering"
+	job.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+func (r *notificationRepository) UpdateOutbox(job *model.NotificationOutbox) error {
+	return r.db.Save(job).Error
+}
+
+func (r *notificationRepository) GetOutboxByNotificationID(notifID uint) (*model.NotificationOutbox, error) {
+	var job model.NotificationOutbox
+	if err := r.db.Where("notification_id = ?", notifID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *notificationRepository) ListRecipientDeliveries(notifID uint, userIDs []uint) ([]RecipientDelivery, error) {
+	var rows []RecipientDelivery
+	if len(userIDs) == 0 {
+		return rows, nil
+	}
+	latest := r.db.Model(&model.PushAttempt{}).
+		Select("MAX(id)").
+		Where("notification_id = ?", notifID).
+		Group("user_id, channel")
+	err := r.db.Table("users").
+		Select("users.id AS user_id, pa.channel, users.nickname, users.student_no, pa.status, pa.provider, pa.error, pa.created_at AS attempted_at").
+		Joins("LEFT JOIN push_attempts pa ON pa.user_id = users.id AND pa.id IN (?)", latest).
+		Where("users.id IN ?", userIDs).
+		Order("users.id, pa.channel").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *notificationRepository) ListReadsByUser(userID uint, notifIDs []uint) ([]model.NotificationRead, error) {
+	var reads []model.NotificationRead
+	if len(notifIDs) == 0 {
+		return reads, nil
+	}
+	err := r.db.Where("user_id = ? AND notification_id IN ?", userID, notifIDs).Find(&reads).Error
+	return reads, err
+}
+
+// MarkRead 标记已读，已读过的保留首次阅读时间
+func (r *notificationRepository) MarkRead(notifIDs []uint, userID uint) error {
+	if len(notifIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	reads := make([]model.NotificationRead, len(notifIDs))
+	for i, id := range notifIDs {
+		reads[i] = model.NotificationRead{NotificationID: id, UserID: userID, ReadAt: now}
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(reads, 200).Error
+}
+
+func (r *notificationRepository) ListNotificationIDsForTargets(targets []model.Target) ([]uint, error) {
+	var ids []uint
+	if len(targets) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&model.Notification{}).
+		Where(targetsCondition(r.db, targets)).
+		Where("status = ?", "published").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *notificationRepository) CountUnread(userID uint, targets []model.Target, muted []string) (int64, error) {
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	read := r.db.Model(&model.NotificationRead{}).Select("notification_id").Where("user_id = ?", userID)
+	var count int64
+	err := visibleTo(r.db.Model(&model.Notification{}), targets, muted).
+		Where("id NOT IN (?)", read).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) ListRecipientReads(notifID uint, userIDs []uint) ([]RecipientRead, error) {
+	var rows []RecipientRead
+	if len(userIDs) == 0 {
+		return rows, nil
+	}
+	err := r.db.Table("users").
+		Select("users.id AS user_id, users.nickname, users.student_no, nr.read_at, nr.acked_at").
+		Joins("LEFT JOIN notification_reads nr ON nr.user_id = users.id AND nr.notification_id = ?", notifID).
+		Where("users.id IN ?", userIDs).
+		Order("users.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// Acknowledge 确认知悉，同时视为已读；重复确认保留首次时间
+func (r *notificationRepository) Acknowledge(notifID, userID uint) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.NotificationRead{NotificationID: notifID, UserID: userID, ReadAt: now}).Error; err != nil {
+			return err
 		}
+		return tx.Model(&model.NotificationRead{}).
+			Where("notification_id = ? AND user_id = ? AND acked_at IS NULL", notifID, userID).
+			Update("acked_at", now).Error
+	})
+}
+
+// ListAckReminderDue 需要确认且上次提醒 (或发布) 早于 before 的通知
+func (r *notificationRepository) ListAckReminderDue(before time.Time, maxReminders int) ([]model.Notification, error) {
+	var notifs []model.Notification
+	err := r.db.Where("require_ack = ? AND status = ? AND ack_reminders < ? AND ack_completed_at IS NULL", true, "published", maxReminders).
+		Where("COALESCE(last_ack_reminder_at, created_at) <= ?", before).
+		Find(&notifs).Error
+	return notifs, err
+}
+
+func (r *notificationRepository) ListAckedUserIDs(notifID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.NotificationRead{}).
+		Where("notification_id = ? AND acked_at IS NOT NULL", notifID).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *notificationRepository) RecordAckReminder(notifID uint, at time.Time) error {
+	return r.db.Model(&model.Notification{}).Where("id = ?", notifID).
+		Updates(map[string]interface{}{
+			"ack_reminders":        gorm.Expr("ack_reminders + 1"),
+			"last_ack_reminder_at": at,
+		}).Error
+}
+
+// CompleteAckReminders 所有接收人均已确认，停止提醒
+func (r *notificationRepository) CompleteAckReminders(notifID uint, at time.Time) error {
+	return r.db.Model(&model.Notification{}).Where("id = ?", notifID).
+		Update("ack_completed_at", at).Error
+}
+
+// QueryInbox 按发布时间倒序查询收件箱中游标 Before 之后的 Limit 条
+func (r *notificationRepository) QueryInbox(targets []model.Target, filter InboxFilter) ([]model.Notification, error) {
+	var notifs []model.Notification
+	if len(targets) == 0 {
+		return notifs, nil
+	}
+	query := visibleTo(r.db.Model(&model.Notification{}), targets, filter.Muted)
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
 	}
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where("title LIKE ? OR content LIKE ?", like, like)
+	}
+	if c := filter.Before; c != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", c.CreatedAt, c.CreatedAt, c.ID)
+	}
+	err := query.Order("created_at desc, id desc").Limit(filter.Limit).Find(&notifs).Error
+	return notifs, err
+}
+
+// UpdateNotification 保存通知；多目标通知的 Audience 不为空时整体替换受众
+func (r *notificationRepository) UpdateNotification(notif *model.Notification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Audience").Save(notif).Error; err != nil {
+			return err
+		}
+		if notif.TargetType != "audience" || len(notif.Audience) == 0 {
+			return nil
+		}
+		if err := tx.Where("notification_id = ?", notif.ID).Delete(&model.NotificationAudience{}).Error; err != nil {
+			return err
+		}
+		for i := range notif.Audience {
+			notif.Audience[i].ID = 0
+			notif.Audience[i].NotificationID = notif.ID
+		}
+		return tx.Create(&notif.Audience).Error
+	})
+}
+
+func (r *notificationRepository) ListAudience(notifID uint) ([]model.NotificationAudience, error) {
+	var audience []model.NotificationAudience
+	err := r.db.Where("notification_id = ?", notifID).Order("id").Find(&audience).Error
+	return audience, err
+}
+
+// ListSchedulesBySender 发送者未发布的草稿和定时通知
+func (r *notificationRepository) ListSchedulesBySender(senderID uint) ([]model.Notification, error) {
+	var notifs []model.Notification
+	err := r.db.Preload("Audience").
+		Where("sender_id = ? AND status IN ?", senderID, []string{"draft", "scheduled"}).
+		Order("scheduled_at, id").
+		Find(&notifs).Error
+	return notifs, err
+}
 
+func (r *notificationRepository) ListDueSchedules(now time.Time) ([]model.Notification, error) {
 	var notifs []model.Notification
-	err := query.Order("created_at desc").Find(&notifs).Error
+	err := r.db.Where("status = ? AND scheduled_at <= ?", "scheduled", now).
+		Order("scheduled_at").
+		Find(&notifs).Error
 	return notifs, err
 }
+
+// PublishNotification 原地发布草稿或一次性定时通知并创建投递任务；
+// 以原状态作为条件，通知已被其他实例发布或取消时返回 false。
+func (r *notificationRepository) PublishNotification(notif *model.Notification, now time.Time) (bool, error) {
+	published := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Notification{}).
+			Where("id = ? AND status = ?", notif.ID, notif.Status).
+			Updates(map[string]interface{}{"status": "published", "created_at": now})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		published = true
+		return tx.Create(&model.NotificationOutbox{
+			NotificationID: notif.ID,
+			Status:         "pending",
+			NextAttemptAt:  now,
+		}).Error
+	})
+	if published {
+		notif.Status = "published"
+		notif.CreatedAt = now
+	}
+	return published, err
+}
+
+// DispatchOccurrence 为周期通知生成本次的已发布通知，并把原通知推进到下一次时间 (next 为空表示已结束)
+func (r *notificationRepository) DispatchOccurrence(schedule *model.Notification, next *time.Time, occurrence *model.Notification) (bool, error) {
+	dispatched := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"scheduled_at": next}
+		if next == nil {
+			updates = map[string]interface{}{"status": "completed"}
+		}
+		res := tx.Model(&model.Notification{}).
+			Where("id = ? AND status = ? AND scheduled_at = ?", schedule.ID, "scheduled", schedule.ScheduledAt).
+			Updates(updates)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		dispatched = true
+		if err := tx.Create(occurrence).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.NotificationOutbox{
+			NotificationID: occurrence.ID,
+			Status:         "pending",
+			NextAttemptAt:  occurrence.CreatedAt,
+		}).Error
+	})
+	return dispatched, err
+}
+
+// EditNotification 保存旧版本并更新已发布通知的内容；通知在此期间被他人修改或撤回时返回 false
+func (r *notificationRepository) EditNotification(notif *model.Notification, prev *model.NotificationVersion) (bool, error) {
+	edited := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Notification{}).
+			Where("id = ? AND status = ? AND version = ?", notif.ID, "published", prev.Version).
+			Updates(map[string]interface{}{
+				"title":       notif.Title,
+				"content":     notif.Content,
+				"format":      notif.Format,
+				"attachments": notif.Attachments,
+				"version":     prev.Version + 1,
+				"edited_at":   notif.EditedAt,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		edited = true
+		return tx.Create(prev).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if edited {
+		notif.Version = prev.Version + 1
+	}
+	return edited, nil
+}
+
+// ListNotificationVersions 通知的历史版本，按版本号升序
+func (r *notificationRepository) ListNotificationVersions(notifID uint) ([]model.NotificationVersion, error) {
+	var versions []model.NotificationVersion
+	err := r.db.Where("notification_id = ?", notifID).Order("version").Find(&versions).Error
+	return versions, err
+}
+
+// RecallNotification 撤回已发布的通知，并重新排入投递队列以发送撤回推送
+func (r *notificationRepository) RecallNotification(notifID uint, now time.Time) (bool, error) {
+	recalled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Notification{}).
+			Where("id = ? AND status = ?", notifID, "published").
+			Updates(map[string]interface{}{"status": "recalled", "recalled_at": now})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		recalled = true
+		res = tx.Model(&model.NotificationOutbox{}).
+			Where("notification_id = ?", notifID).
+			Updates(map[string]interface{}{"status": "pending", "attempts": 0, "next_attempt_at": now, "last_error": ""})
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		return tx.Create(&model.NotificationOutbox{
+			NotificationID: notifID,
+			Status:         "pending",
+			NextAttemptAt:  now,
+		}).Error
+	})
+	return recalled, err
+}
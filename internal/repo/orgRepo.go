@@ -31,6 +31,9 @@ type OrgRepository interface {
 	GetDepartmentDetailsByID(deptId string) (interface{}, interface{})
 	ListStudentsByDepartmentID(deptId string) (interface{}, interface{})
 	ListStudentsByCounselorID(userId uint) ([]model.User, interface{})
+	ListAllDepartments() ([]model.Department, error)
+	ListDepartmentsByIDs(ids []uint) ([]model.Department, error)
+	ListDepartmentIDsByOrgUnitIDs(unitIDs []uint) ([]uint, error)
 }
 
 type orgRepository struct {
@@ -210,3 +213,28 @@ func (r *orgRepository) ListStudentsByCounselorID(userId uint) ([]model.User, in
 	}
 	return students, nil
 }
+
+func (r *orgRepository) ListAllDepartments() ([]model.Department, error) {
+	var depts []model.Department
+	err := r.db.Find(&depts).Error
+	return depts, err
+}
+
+func (r *orgRepository) ListDepartmentsByIDs(ids []uint) ([]model.Department, error) {
+	var depts []model.Department
+	if len(ids) == 0 {
+		return depts, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&depts).Error
+	return depts, err
+}
+
+// ListDepartmentIDsByOrgUnitIDs 挂在给定组织架构节点下的部门
+func (r *orgRepository) ListDepartmentIDsByOrgUnitIDs(unitIDs []uint) ([]uint, error) {
+	ids := []uint{}
+	if len(unitIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&model.Department{}).Where("org_unit_id IN ?", unitIDs).Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"fmt"
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrgUnitRepository interface {
+	CreateOrgUnit(unit *model.OrgUnit) error
+	GetOrgUnitByID(id uint) (*model.OrgUnit, error)
+	ListOrgUnits() ([]model.OrgUnit, error)
+	RenameOrgUnit(id uint, name string) error
+	MoveOrgUnit(unit, parent *model.OrgUnit) (bool, error)
+	DeleteOrgUnit(id uint) error
+	CountChildren(id uint) (int64, error)
+	CountAttached(id uint) (int64, error)
+	AttachDepartment(deptID uint, unitID *uint) (bool, error)
+	AttachClass(classID uint, unitID *uint) (bool, error)
+	ListAttachedDepartments() ([]model.Department, error)
+	ListAttachedClasses() ([]model.Class, error)
+	ListUnitStudents() ([]UnitStudent, error)
+}
+
+// UnitStudent 节点直接关联的部门或班级中的学生
+type UnitStudent struct {
+	OrgUnitID uint
+	StudentID uint
+}
+
+type orgUnitRepository struct {
+	db *gorm.DB
+}
+
+func NewOrgUnitRepository(db *gorm.DB) OrgUnitRepository {
+	return &orgUnitRepository{db: db}
+}
+
+// CreateOrgUnit 创建节点并按 ID 写入路径
+func (r *orgUnitRepository) CreateOrgUnit(unit *model.OrgUnit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		parentPath := ""
+		if unit.ParentID != nil {
+			var parent model.OrgUnit
+			if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&parent, *unit.ParentID).Error; err != nil {
+				return err
+			}
+			parentPath = parent.Path
+		}
+		if err := tx.Create(unit).Error; err != nil {
+			return err
+		}
+		unit.Path = fmt.Sprintf("%s/%d", parentPath, unit.ID)
+		return tx.Model(unit).Update("path", unit.Path).Error
+	})
+}
+
+func (r *orgUnitRepository) GetOrgUnitByID(id uint) (*model.OrgUnit, error) {
+	var unit model.OrgUnit
+	if err := r.db.First(&unit, id).Error; err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+func (r *orgUnitRepository) ListOrgUnits() ([]model.OrgUnit, error) {
+	var units []model.OrgUnit
+	err := r.db.Order("id").Find(&units).Error
+	return units, err
+}
+
+func (r *orgUnitRepository) RenameOrgUnit(id uint, name string) error {
+	return r.db.Model(&model.OrgUnit{}).Where("id = ?", id).Update("name", name).Error
+}
+
+// MoveOrgUnit 将节点移到 parent 之下，并在同一事务中改写所有下级节点的路径。
+// 节点或新上级的路径在读取后被其他操作改变时返回 false。
+func (r *orgUnitRepository) MoveOrgUnit(unit, parent *model.OrgUnit) (bool, error) {
+	moved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked []model.OrgUnit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(id = ? AND path = ?) OR (id = ? AND path = ?)", unit.ID, unit.Path, parent.ID, parent.Path).
+			Find(&locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != 2 {
+			return nil
+		}
+
+		oldPath := unit.Path
+		newPath := fmt.Sprintf("%s/%d", parent.Path, unit.ID)
+		if err := tx.Model(&model.OrgUnit{}).
+			Where("path LIKE ?", oldPath+"/%").
+			Update("path", gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", newPath, len(oldPath)+1)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.OrgUnit{}).Where("id = ?", unit.ID).
+			Updates(map[string]interface{}{"parent_id": parent.ID, "path": newPath}).Error; err != nil {
+			return err
+		}
+		unit.ParentID = &parent.ID
+		unit.Path = newPath
+		moved = true
+		return nil
+	})
+	return moved, err
+}
+
+func (r *orgUnitRepository) DeleteOrgUnit(id uint) error {
+	return r.db.Delete(&model.OrgUnit{}, id).Error
+}
+
+func (r *orgUnitRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.OrgUnit{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CountAttached 关联到节点的部门和班级数
+func (r *orgUnitRepository) CountAttached(id uint) (int64, error) {
+	var depts, classes int64
+	if err := r.db.Model(&model.Department{}).Where("org_unit_id = ?", id).Count(&depts).Error; err != nil {
+		return 0, err
+	}
+	if err := r.db.Model(&model.Class{}).Where("org_unit_id = ?", id).Count(&classes).Error; err != nil {
+		return 0, err
+	}
+	return depts + classes, nil
+}
+
+// AttachDepartment 设置部门所属的节点，unitID 为 nil 时解除关联；部门不存在时返回 false
+func (r *orgUnitRepository) AttachDepartment(deptID uint, unitID *uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.Department{}).Where("id = ?", deptID).Count(&count).Error; err != nil || count == 0 {
+		return false, err
+	}
+	return true, r.db.Model(&model.Department{}).Where("id = ?", deptID).Update("org_unit_id", unitID).Error
+}
+
+// AttachClass 设置班级对应的节点，unitID 为 nil 时解除关联；班级不存在时返回 false
+func (r *orgUnitRepository) AttachClass(classID uint, unitID *uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.Class{}).Where("id = ?", classID).Count(&count).Error; err != nil || count == 0 {
+		return false, err
+	}
+	return true, r.db.Model(&model.Class{}).Where("id = ?", classID).Update("org_unit_id", unitID).Error
+}
+
+func (r *orgUnitRepository) ListAttachedDepartments() ([]model.Department, error) {
+	var depts []model.Department
+	err := r.db.Select("id", "name", "org_unit_id").Where("org_unit_id IS NOT NULL").Order("id").Find(&depts).Error
+	return depts, err
+}
+
+func (r *orgUnitRepository) ListAttachedClasses() ([]model.Class, error) {
+	var classes []model.Class
+	err := r.db.Select("id", "name", "org_unit_id").Where("org_unit_id IS NOT NULL").Order("id").Find(&classes).Error
+	return classes, err
+}
+
+// ListUnitStudents 各节点通过部门和班级直接关联的学生，同一学生可能出现多次
+func (r *orgUnitRepository) ListUnitStudents() ([]UnitStudent, error) {
+	var viaDept, viaClass []UnitStudent
+	if err := r.db.Table("student_departments sd").
+		Select("d.org_unit_id, sd.student_id").
+		Joins("JOIN departments d ON d.id = sd.department_id AND d.deleted_at IS NULL").
+		Where("d.org_unit_id IS NOT NULL").
+		Scan(&viaDept).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Table("student_classes sc").
+		Select("c.org_unit_id, sc.student_id").
+		Joins("JOIN classes c ON c.id = sc.class_id AND c.deleted_at IS NULL").
+		Where("c.org_unit_id IS NOT NULL").
+		Scan(&viaClass).Error; err != nil {
+		return nil, err
+	}
+	return append(viaDept, viaClass...), nil
+}
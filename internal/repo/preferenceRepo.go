@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PreferenceRepository interface {
+	ListPreferences(userID uint) ([]model.NotificationPreference, error)
+	SavePreferences(prefs []model.NotificationPreference) error
+	ListEmailRecipients(userIDs []uint, category string) ([]model.User, error)
+	ListPushMutedUserIDs(userIDs []uint, category string) ([]uint, error)
+	ListInAppMutedCategories(userID uint) ([]string, error)
+	GetSetting(userID uint) (*model.NotificationSetting, error)
+	ListSettings(userIDs []uint) ([]model.NotificationSetting, error)
+	SaveSetting(setting *model.NotificationSetting) error
+	ListQuietSettings(userIDs []uint) ([]model.NotificationSetting, error)
+}
+
+type preferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceRepository(db *gorm.DB) PreferenceRepository {
+	return &preferenceRepository{db: db}
+}
+
+func (r *preferenceRepository) ListPreferences(userID uint) ([]model.NotificationPreference, error) {
+	var prefs []model.NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Find(&prefs).Error
+	return prefs, err
+}
+
+func (r *preferenceRepository) SavePreferences(prefs []model.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	// 显式选择列，避免布尔字段为 false 时被 gorm 当作零值而写入数据库默认值
+	return r.db.Select("user_id", "category", "push", "email", "in_app").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"push", "email", "in_app"}),
+		}).Create(&prefs).Error
+}
+
+// ListEmailRecipients 开启了该类消息邮件接收的用户
+func (r *preferenceRepository) ListEmailRecipients(userIDs []uint, category string) ([]model.User, error) {
+	var users []model.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := r.db.Select("users.id", "users.nickname", "users.student_no", "users.email").
+		Joins("JOIN notification_preferences np ON np.user_id = users.id").
+		Where("users.id IN ? AND np.category = ? AND np.email = ? AND users.email <> ''", userIDs, category, true).
+		Find(&users).Error
+	return users, err
+}
+
+// ListPushMutedUserIDs 关闭了该类消息推送的用户
+func (r *preferenceRepository) ListPushMutedUserIDs(userIDs []uint, category string) ([]uint, error) {
+	var ids []uint
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&model.NotificationPreference{}).
+		Where("user_id IN ? AND category = ? AND push = ?", userIDs, category, false).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *preferenceRepository) ListInAppMutedCategories(userID uint) ([]string, error) {
+	var categories []string
+	err := r.db.Model(&model.NotificationPreference{}).
+		Where("user_id = ? AND in_app = ?", userID, false).
+		Pluck("category", &categories).Error
+	return categories, err
+}
+
+func (r *preferenceRepository) GetSetting(userID uint) (*model.NotificationSetting, error) {
+	var setting model.NotificationSetting
+	if err := r.db.Where("user_id = ?", userID).First(&setting).Error; err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+func (r *preferenceRepository) ListSettings(userIDs []uint) ([]model.NotificationSetting, error) {
+	var settings []model.NotificationSetting
+	if len(userIDs) == 0 {
+		return settings, nil
+	}
+	err := r.db.Where("user_id IN ?", userIDs).Find(&settings).Error
+	return settings, err
+}
+
+func (r *preferenceRepository) SaveSetting(setting *model.NotificationSetting) error {
+	return r.db.Select("user_id", "quiet_enabled", "quiet_start", "quiet_end", "timezone", "locale", "updated_at").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quiet_enabled", "quiet_start", "quiet_end", "timezone", "locale", "updated_at"}),
+		}).Create(setting).Error
+}
+
+// ListQuietSettings 开启了免打扰的用户设置
+func (r *preferenceRepository) ListQuietSettings(userIDs []uint) ([]model.NotificationSetting, error) {
+	var settings []model.NotificationSetting
+	if len(userIDs) == 0 {
+		return settings, nil
+	}
+	err := r.db.Where("user_id IN ? AND quiet_enabled = ?", userIDs, true).Find(&settings).Error
+	return settings, err
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PushRepository interface {
+	ListUsersWithPushToken(userIDs []uint) ([]model.User, error)
+	ClearPushToken(userID uint, token string) error
+	CreateAttempts(attempts []model.PushAttempt) error
+	ListSettledRecipientIDs(notifID uint, channel string) ([]uint, error)
+	ListRecallRecipientIDs(notifID uint) ([]uint, error)
+}
+
+type pushRepository struct {
+	db *gorm.DB
+}
+
+func NewPushRepository(db *gorm.DB) PushRepository {
+	return &pushRepository{db: db}
+}
+
+func (r *pushRepository) ListUsersWithPushToken(userIDs []uint) ([]model.User, error) {
+	var users []model.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := r.db.Select("id", "nickname", "student_no", "push_token", "push_platform").
+		Where("id IN ? AND push_token <> ''", userIDs).
+		Find(&users).Error
+	return users, err
+}
+
+// ClearPushToken 清除失效令牌；仅当令牌未被用户重新登录更新时才清除
+func (r *pushRepository) ClearPushToken(userID uint, token string) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ? AND push_token = ?", userID, token).
+		Update("push_token", "").Error
+}
+
+func (r *pushRepository) CreateAttempts(attempts []model.PushAttempt) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(attempts, 200).Error
+}
+
+// ListSettledRecipientIDs 在该渠道已有最终结果 (最近一次投递不是 failed) 的接收人，重试时跳过
+func (r *pushRepository) ListSettledRecipientIDs(notifID uint, channel string) ([]uint, error) {
+	latest := r.db.Model(&model.PushAttempt{}).
+		Select("MAX(id)").
+		Where("notification_id = ? AND channel = ?", notifID, channel).
+		Group("user_id")
+	var ids []uint
+	err := r.db.Model(&model.PushAttempt{}).
+		Where("id IN (?) AND status <> ?", latest, "failed").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// ListRecallRecipientIDs 收到过通知推送、但尚未成功推送撤回消息的用户
+func (r *pushRepository) ListRecallRecipientIDs(notifID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.PushAttempt{}).
+		Distinct("user_id").
+		Where("notification_id = ? AND channel = ? AND status = ?", notifID, "push", "sent").
+		Where("user_id NOT IN (?)", r.db.Model(&model.PushAttempt{}).
+			Select("user_id").
+			Where("notification_id = ? AND channel = ? AND status = ?", notifID, "push", "recalled")).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
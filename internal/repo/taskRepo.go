@@ -1,17 +1,77 @@
 package repo
 
 import (
+	"time"
 	"unihub/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TaskRepository interface {
-	CreateTask(task *model.Task) error
+	CreateTask(task *model.Task, assignments []model.TaskAssignment) error
 	GetTaskByUUID(uuid string) (*model.Task, error)
-	GetTasksForTargets(targets []model.Target) ([]model.Task, error)
 	CreateTaskRecord(record *model.TaskRecord) error
 	GetTaskRecord(taskID, studentID uint) (*model.TaskRecord, error)
+	ListTasksByCreator(creatorID uint) ([]model.Task, error)
+	UpdateTask(task *model.Task, prevDeadline time.Time) error
+	DeleteTask(id uint) error
+	CountTaskRecords(taskID uint) (int64, error)
+	ListTaskSubmissions(taskID uint) ([]TaskSubmission, error)
+	GetTaskRecordByID(id uint) (*model.TaskRecord, error)
+	ResubmitTaskRecord(record *model.TaskRecord, prev *model.TaskRecordVersion) (bool, error)
+	ReviewTaskRecord(record *model.TaskRecord) (bool, error)
+	ListTaskRecordVersions(recordID uint) ([]model.TaskRecordVersion, error)
+	GetAssignment(taskID, studentID uint) (*model.TaskAssignment, error)
+	ListAssignedTasks(studentID uint) ([]AssignedTask, error)
+	ListTaskAssignees(taskID uint) ([]TaskAssignee, error)
+	CreateAssignments(assignments []model.TaskAssignment) error
+	ListTasksForTarget(targetType string, targetID uint) ([]model.Task, error)
+	ListTasksWithoutAssignments() ([]model.Task, error)
+	ListTargetMembers(targetType string, targetID uint) ([]TargetMember, error)
+	BackfillAssignments(taskID uint, assignments []model.TaskAssignment) error
+	MarkTaskReminded(taskID uint, at, since time.Time) (bool, error)
+}
+
+// AssignedTask 分派给学生的任务及其分派状态
+type AssignedTask struct {
+	model.Task
+	AssignmentStatus string
+	Late             bool
+	AssignedAt       time.Time
+	DueAt            time.Time
+}
+
+// TargetMember 发布对象中的学生及其加入时间
+type TargetMember struct {
+	StudentID uint
+	JoinedAt  time.Time
+}
+
+// TaskAssignee 任务的分派对象及其完成情况
+type TaskAssignee struct {
+	StudentID   uint
+	Nickname    string
+	StudentNo   *string
+	Status      string
+	Late        bool
+	DueAt       time.Time
+	SubmittedAt *time.Time
+}
+
+// TaskSubmission 提交记录及提交学生
+type TaskSubmission struct {
+	RecordID    uint      `json:"record_id"`
+	StudentID   uint      `json:"student_id"`
+	Nickname    string    `json:"nickname"`
+	StudentNo   *string   `json:"student_no"`
+	Status      string    `json:"status"`
+	Late        bool      `json:"late"`
+	Version     int       `json:"version"`
+	Feedback    string    `json:"feedback"`
+	Score       *float64  `json:"score"`
+	Data        string    `json:"-"`
+	SubmittedAt time.Time `json:"submitted_at"`
 }
 
 type taskRepository struct {
@@ -22,8 +82,20 @@ func NewTaskRepository(db *gorm.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
-func (r *taskRepository) CreateTask(task *model.Task) error {
-	return r.db.Create(task).Error
+// CreateTask 创建任务及其分派记录
+func (r *taskRepository) CreateTask(task *model.Task, assignments []model.TaskAssignment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		if len(assignments) == 0 {
+			return nil
+		}
+		for i := range assignments {
+			assignments[i].TaskID = task.ID
+		}
+		return tx.CreateInBatches(assignments, 500).Error
+	})
 }
 
 func (r *taskRepository) GetTaskByUUID(uuidStr string) (*model.Task, error) {
@@ -34,33 +106,242 @@ func (r *taskRepository) GetTaskByUUID(uuidStr string) (*model.Task, error) {
 	return &task, nil
 }
 
-func (r *taskRepository) GetTasksForTargets(targets []model.Target) ([]model.Task, error) {
-	if len(targets) == 0 {
-		return []model.Task{}, nil
-	}
-
-	query := r.db.Model(&model.Task{})
-	for i, t := range targets {
-		if i == 0 {
-			query = query.Where("target_type = ? AND target_id = ?", t.Type, t.ID)
-		} else {
-			query = query.Or("target_type = ? AND target_id = ?", t.Type, t.ID)
+// CreateTaskRecord 写入提交记录并同步分派状态
+func (r *taskRepository) CreateTaskRecord(record *model.TaskRecord) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
 		}
+		return tx.Model(&model.TaskAssignment{}).
+			Where("task_id = ? AND student_id = ?", record.TaskID, record.StudentID).
+			Updates(map[string]interface{}{"status": record.Status, "late": record.Late}).Error
+	})
+}
+
+func (r *taskRepository) GetTaskRecord(taskID, studentID uint) (*model.TaskRecord, error) {
+	var record model.TaskRecord
+	if err := r.db.Where("task_id = ? AND student_id = ?", taskID, studentID).First(&record).Error; err != nil {
+		return nil, err
 	}
+	return &record, nil
+}
 
+func (r *taskRepository) ListTasksByCreator(creatorID uint) ([]model.Task, error) {
 	var tasks []model.Task
-	err := query.Order("created_at desc").Find(&tasks).Error
+	err := r.db.Where("creator_id = ?", creatorID).Order("created_at desc").Find(&tasks).Error
 	return tasks, err
 }
 
-func (r *taskRepository) CreateTaskRecord(record *model.TaskRecord) error {
-	return r.db.Create(record).Error
+// UpdateTask 保存任务；截止时间变化时，按原截止时间分派的记录随之调整 (已顺延的不变)
+func (r *taskRepository) UpdateTask(task *model.Task, prevDeadline time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(task).Error; err != nil {
+			return err
+		}
+		if task.Deadline.Equal(prevDeadline) {
+			return nil
+		}
+		// 截止时间延后时，顺延过的后加入学生也不早于新的截止时间；提前时只调整与原截止时间一致的分派
+		if task.Deadline.After(prevDeadline) {
+			return tx.Model(&model.TaskAssignment{}).
+				Where("task_id = ?", task.ID).
+				Update("due_at", gorm.Expr("GREATEST(due_at, ?)", task.Deadline)).Error
+		}
+		return tx.Model(&model.TaskAssignment{}).
+			Where("task_id = ? AND due_at = ?", task.ID, prevDeadline).
+			Update("due_at", task.Deadline).Error
+	})
 }
 
-func (r *taskRepository) GetTaskRecord(taskID, studentID uint) (*model.TaskRecord, error) {
+func (r *taskRepository) DeleteTask(id uint) error {
+	return r.db.Delete(&model.Task{}, id).Error
+}
+
+func (r *taskRepository) CountTaskRecords(taskID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.TaskRecord{}).Where("task_id = ?", taskID).Count(&count).Error
+	return count, err
+}
+
+func (r *taskRepository) ListTaskSubmissions(taskID uint) ([]TaskSubmission, error) {
+	var rows []TaskSubmission
+	err := r.db.Table("task_records tr").
+		Select("tr.id AS record_id, tr.student_id, u.nickname, u.student_no, tr.status, tr.late, tr.version, tr.feedback, tr.score, tr.data, tr.created_at AS submitted_at").
+		Joins("JOIN users u ON u.id = tr.student_id").
+		Where("tr.task_id = ?", taskID).
+		Order("tr.created_at").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *taskRepository) GetTaskRecordByID(id uint) (*model.TaskRecord, error) {
 	var record model.TaskRecord
-	if err := r.db.Where("task_id = ? AND student_id = ?", taskID, studentID).First(&record).Error; err != nil {
+	if err := r.db.First(&record, id).Error; err != nil {
 		return nil, err
 	}
 	return &record, nil
 }
+
+// MarkTaskReminded 记录催交时间；since 之后已催交过时返回 false
+func (r *taskRepository) MarkTaskReminded(taskID uint, at, since time.Time) (bool, error) {
+	res := r.db.Model(&model.Task{}).
+		Where("id = ? AND (last_reminded_at IS NULL OR last_reminded_at <= ?)", taskID, since).
+		Update("last_reminded_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ResubmitTaskRecord 保存旧版本并写入新提交；记录已被他人更新 (版本号不符或已不是退回状态) 时返回 false
+func (r *taskRepository) ResubmitTaskRecord(record *model.TaskRecord, prev *model.TaskRecordVersion) (bool, error) {
+	resubmitted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.TaskRecord{}).
+			Where("id = ? AND version = ? AND status = ?", record.ID, prev.Version, "returned").
+			Updates(map[string]interface{}{
+				"status":      record.Status,
+				"data":        record.Data,
+				"version":     prev.Version + 1,
+				"feedback":    "",
+				"score":       nil,
+				"reviewer_id": nil,
+				"reviewed_at": nil,
+				"late":        record.Late,
+				"created_at":  record.CreatedAt,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		resubmitted = true
+		if err := tx.Model(&model.TaskAssignment{}).
+			Where("task_id = ? AND student_id = ?", record.TaskID, record.StudentID).
+			Updates(map[string]interface{}{"status": record.Status, "late": record.Late}).Error; err != nil {
+			return err
+		}
+		return tx.Create(prev).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if resubmitted {
+		record.Version = prev.Version + 1
+	}
+	return resubmitted, nil
+}
+
+// ReviewTaskRecord 写入审阅结果；仅当记录仍是被审阅的版本时生效
+func (r *taskRepository) ReviewTaskRecord(record *model.TaskRecord) (bool, error) {
+	reviewed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.TaskRecord{}).
+			Where("id = ? AND version = ?", record.ID, record.Version).
+			Updates(map[string]interface{}{
+				"status":      record.Status,
+				"feedback":    record.Feedback,
+				"score":       record.Score,
+				"reviewer_id": record.ReviewerID,
+				"reviewed_at": record.ReviewedAt,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		reviewed = true
+		return tx.Model(&model.TaskAssignment{}).
+			Where("task_id = ? AND student_id = ?", record.TaskID, record.StudentID).
+			Update("status", record.Status).Error
+	})
+	return reviewed, err
+}
+
+// ListTaskRecordVersions 提交记录的历史版本，按版本号升序
+func (r *taskRepository) ListTaskRecordVersions(recordID uint) ([]model.TaskRecordVersion, error) {
+	var versions []model.TaskRecordVersion
+	err := r.db.Where("record_id = ?", recordID).Order("version").Find(&versions).Error
+	return versions, err
+}
+
+func (r *taskRepository) GetAssignment(taskID, studentID uint) (*model.TaskAssignment, error) {
+	var assignment model.TaskAssignment
+	if err := r.db.Where("task_id = ? AND student_id = ?", taskID, studentID).First(&assignment).Error; err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// ListAssignedTasks 分派给学生的任务，按截止时间倒序
+func (r *taskRepository) ListAssignedTasks(studentID uint) ([]AssignedTask, error) {
+	var rows []AssignedTask
+	err := r.db.Model(&model.Task{}).
+		Select("tasks.*, ta.status AS assignment_status, ta.late, ta.assigned_at, ta.due_at").
+		Joins("JOIN task_assignments ta ON ta.task_id = tasks.id").
+		Where("ta.student_id = ?", studentID).
+		Order("ta.due_at desc").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListTaskAssignees 任务的分派对象，附带提交时间
+func (r *taskRepository) ListTaskAssignees(taskID uint) ([]TaskAssignee, error) {
+	var rows []TaskAssignee
+	err := r.db.Table("task_assignments ta").
+		Select("ta.student_id, u.nickname, u.student_no, ta.status, ta.late, ta.due_at, tr.created_at AS submitted_at").
+		Joins("JOIN users u ON u.id = ta.student_id").
+		Joins("LEFT JOIN task_records tr ON tr.task_id = ta.task_id AND tr.student_id = ta.student_id").
+		Where("ta.task_id = ?", taskID).
+		Order("u.student_no").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CreateAssignments 补发分派记录，已分派的学生跳过
+func (r *taskRepository) CreateAssignments(assignments []model.TaskAssignment) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(assignments, 500).Error
+}
+
+func (r *taskRepository) ListTasksForTarget(targetType string, targetID uint) ([]model.Task, error) {
+	var tasks []model.Task
+	err := r.db.Where("target_type = ? AND target_id = ?", targetType, targetID).Find(&tasks).Error
+	return tasks, err
+}
+
+// ListTasksWithoutAssignments 尚未生成分派记录的任务 (分派功能上线前发布的任务)
+func (r *taskRepository) ListTasksWithoutAssignments() ([]model.Task, error) {
+	var tasks []model.Task
+	err := r.db.Where("assigned = ? AND NOT EXISTS (SELECT 1 FROM task_assignments ta WHERE ta.task_id = tasks.id)", false).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// ListTargetMembers 部门或班级中的学生及其加入时间
+func (r *taskRepository) ListTargetMembers(targetType string, targetID uint) ([]TargetMember, error) {
+	var members []TargetMember
+	var err error
+	switch targetType {
+	case "dept":
+		err = r.db.Model(&model.StudentDepartment{}).
+			Select("student_id, MIN(created_at) AS joined_at").
+			Where("department_id = ?", targetID).
+			Group("student_id").
+			Scan(&members).Error
+	case "class":
+		err = r.db.Model(&model.StudentClass{}).
+			Select("student_id, MIN(created_at) AS joined_at").
+			Where("class_id = ?", targetID).
+			Group("student_id").
+			Scan(&members).Error
+	}
+	return members, err
+}
+
+// BackfillAssignments 写入补发的分派记录并标记任务已分派，没有分派对象的任务也会被标记
+func (r *taskRepository) BackfillAssignments(taskID uint, assignments []model.TaskAssignment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(assignments) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(assignments, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.Task{}).Where("id = ?", taskID).Update("assigned", true).Error
+	})
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"unihub/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type TemplateRepository interface {
+	CreateTemplate(t *model.NotificationTemplate) error
+	UpdateTemplate(t *model.NotificationTemplate) error
+	DeleteTemplate(id uint) error
+	GetTemplateByID(id uint) (*model.NotificationTemplate, error)
+	FindTemplate(key, locale string, ownerID uint) (*model.NotificationTemplate, error)
+	ListTemplates(ownerID uint) ([]model.NotificationTemplate, error)
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
+
+func (r *templateRepository) CreateTemplate(t *model.NotificationTemplate) error {
+	return r.db.Create(t).Error
+}
+
+func (r *templateRepository) UpdateTemplate(t *model.NotificationTemplate) error {
+	return r.db.Save(t).Error
+}
+
+func (r *templateRepository) DeleteTemplate(id uint) error {
+	return r.db.Delete(&model.NotificationTemplate{}, id).Error
+}
+
+func (r *templateRepository) GetTemplateByID(id uint) (*model.NotificationTemplate, error) {
+	var t model.NotificationTemplate
+	if err := r.db.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *templateRepository) FindTemplate(key, locale string, ownerID uint) (*model.NotificationTemplate, error) {
+	var t model.NotificationTemplate
+	if err := r.db.Where("`key` = ? AND locale = ? AND owner_id = ?", key, locale, ownerID).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTemplates 用户自己的模板和系统模板
+func (r *templateRepository) ListTemplates(ownerID uint) ([]model.NotificationTemplate, error) {
+	var templates []model.NotificationTemplate
+	err := r.db.Where("owner_id IN ?", []uint{0, ownerID}).
+		Order("owner_id desc, `key`, locale").
+		Find(&templates).Error
+	return templates, err
+}
@@ -17,6 +17,9 @@ type UserRepository interface {
 	ListStudentsByDepartmentIDs(deptIDs []uint) ([]model.User, error)
 	ListStudentsByClassIDs(classIDs []uint) ([]model.User, error)
 	ListAllStudents() ([]model.User, error)
+	ListDepartmentAdmins(deptID uint) ([]DepartmentAdmin, error)
+	ListUserIDsByRoleID(roleID uint) ([]uint, error)
+	ListAllUserIDs() ([]uint, error)
 }
 
 type userRepository struct {
@@ -102,3 +105,38 @@ func (r *userRepository) ListAllStudents() ([]model.User, error) {
 		Find(&students).Error
 	return students, err
 }
+
+// DepartmentAdmin 可负责某部门的管理员：与该部门相同，或所在部门挂在该部门组织节点的上级节点
+type DepartmentAdmin struct {
+	ID             uint
+	Nickname       string
+	SameDepartment bool
+	UnitPath       string
+}
+
+func (r *userRepository) ListDepartmentAdmins(deptID uint) ([]DepartmentAdmin, error) {
+	var admins []DepartmentAdmin
+	err := r.db.Table("users").
+		Select("users.id, users.nickname, ad.id = sd.id AS same_department, COALESCE(au.path, '') AS unit_path").
+		Joins("JOIN roles r ON r.id = users.role_id AND r.`key` = ?", "admin").
+		Joins("JOIN departments ad ON ad.id = users.department_id AND ad.deleted_at IS NULL").
+		Joins("LEFT JOIN org_units au ON au.id = ad.org_unit_id AND au.deleted_at IS NULL").
+		Joins("JOIN departments sd ON sd.id = ?", deptID).
+		Joins("LEFT JOIN org_units su ON su.id = sd.org_unit_id AND su.deleted_at IS NULL").
+		Where("users.deleted_at IS NULL").
+		Where("ad.id = sd.id OR (au.id IS NOT NULL AND su.id IS NOT NULL AND (su.path = au.path OR su.path LIKE CONCAT(au.path, '/%')))").
+		Scan(&admins).Error
+	return admins, err
+}
+
+func (r *userRepository) ListUserIDsByRoleID(roleID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.User{}).Where("role_id = ?", roleID).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *userRepository) ListAllUserIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.User{}).Pluck("id", &ids).Error
+	return ids, err
+}
@@ -1,6 +1,10 @@
 package router
 
 import (
+	"context"
+	"log"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
@@ -8,6 +12,7 @@ import (
 	"unihub/internal/handler"
 	"unihub/internal/repo"
 	"unihub/internal/service"
+	"unihub/internal/worker"
 	"unihub/pkg/middleware"
 )
 
@@ -23,29 +28,67 @@ func Register(r *gin.Engine, cfg *config.Config, db *gorm.DB) {
 	orgRepo := repo.NewOrgRepository(db)
 	notifRepo := repo.NewNotificationRepository(db)
 	leaveRepo := repo.NewLeaveRepository(db)
-	//taskRepo := repo.NewTaskRepository(db)
+	taskRepo := repo.NewTaskRepository(db)
 	openRepo := repo.NewOpenRepository(db)
 	dingRepo := repo.NewDingRepository(db)
+	delegationRepo := repo.NewDelegationRepository(db)
+	holidayRepo := repo.NewHolidayRepository(db)
+	calendarRepo := repo.NewCalendarRepository(db)
+	passRepo := repo.NewExitPassRepository(db)
+	pushRepo := repo.NewPushRepository(db)
+	prefRepo := repo.NewPreferenceRepository(db)
+	templateRepo := repo.NewTemplateRepository(db)
+	orgUnitRepo := repo.NewOrgUnitRepository(db)
 
 	// 初始化 Services
 	authSvc := service.NewAuthService(userRepo, orgRepo, cfg)
-	orgSvc := service.NewOrgService(orgRepo, userRepo)
 	userSvc := service.NewUserService(userRepo, orgRepo)
-	notifSvc := service.NewNotificationService(notifRepo, orgRepo, userRepo, db)
-	leaveSvc := service.NewLeaveService(leaveRepo, orgRepo, userRepo)
-	//taskSvc := service.NewTaskService(taskRepo, orgRepo, userRepo)
+	templateSvc := service.NewTemplateService(templateRepo, userRepo, prefRepo)
+	if err := templateSvc.EnsureSystemTemplates(); err != nil {
+		log.Printf("failed to seed system notification templates: %v", err)
+	}
+	pushSvc := service.NewPushService(pushRepo, orgRepo, userRepo, notifRepo, prefRepo, cfg)
+	channels := []service.DeliveryChannel{pushSvc}
+	if cfg.Email.Enabled {
+		emailChannel, err := service.NewEmailChannel(prefRepo, pushRepo, cfg)
+		if err != nil {
+			log.Printf("email channel disabled: %v", err)
+		} else {
+			channels = append(channels, emailChannel)
+		}
+	}
+	notifSvc := service.NewNotificationService(notifRepo, orgRepo, userRepo, pushSvc, prefRepo, channels, templateSvc, cfg)
+	passSvc := service.NewExitPassService(passRepo, userRepo, cfg)
+	leaveSvc := service.NewLeaveService(leaveRepo, orgRepo, userRepo, delegationRepo, notifRepo, passSvc, templateSvc, db)
+	taskSvc := service.NewTaskService(taskRepo, orgRepo, userRepo, notifRepo, templateSvc, cfg)
+	orgSvc := service.NewOrgService(orgRepo, userRepo, taskSvc)
+	orgUnitSvc := service.NewOrgUnitService(orgUnitRepo, userRepo)
 	openSvc := service.NewOpenService(openRepo)
-	dingSvc := service.NewDingService(dingRepo, orgRepo, userRepo, db)
+	dingSvc := service.NewDingService(dingRepo, orgRepo, userRepo, notifRepo, templateSvc)
+	holidaySvc := service.NewHolidayService(holidayRepo, orgRepo, userRepo, dingSvc)
+	escalationSvc := service.NewEscalationService(leaveRepo, orgRepo, userRepo, notifRepo, templateSvc, cfg)
+	calendarSvc := service.NewCalendarService(calendarRepo, dingRepo, leaveRepo, orgRepo, userRepo, cfg)
 
 	// 初始化 Handlers
 	authH := handler.NewAuthHandler(authSvc)
 	orgH := handler.NewOrgHandler(orgSvc)
+	orgUnitH := handler.NewOrgUnitHandler(orgUnitSvc)
 	userH := handler.NewUserHandler(userSvc)
 	notifH := handler.NewNotificationHandler(notifSvc)
-	leaveH := handler.NewLeaveHandler(leaveSvc, dingSvc)
-	//taskH := handler.NewTaskHandler(taskSvc)
+	templateH := handler.NewTemplateHandler(templateSvc)
+	leaveH := handler.NewLeaveHandler(leaveSvc, dingSvc, escalationSvc, passSvc)
+	taskH := handler.NewTaskHandler(taskSvc)
 	openH := handler.NewOpenHandler(openSvc)
 	dingH := handler.NewDingHandler(dingSvc, userRepo)
+	holidayH := handler.NewHolidayHandler(holidaySvc)
+	calendarH := handler.NewCalendarHandler(calendarSvc)
+
+	// 后台任务
+	go worker.Every(context.Background(), 10*time.Minute, "holiday-return-ding", holidaySvc.GenerateReturnDings)
+	go worker.Every(context.Background(), time.Minute, "leave-escalation", escalationSvc.RunEscalations)
+	go worker.Every(context.Background(), cfg.Notification.Outbox.Interval, "notification-outbox", notifSvc.DeliverOutbox)
+	go worker.Every(context.Background(), 10*time.Minute, "notification-ack-reminder", notifSvc.SendAckReminders)
+	go worker.Every(context.Background(), time.Minute, "notification-scheduler", notifSvc.DispatchScheduled)
 
 	api := r.Group("/api/v1")
 	{
@@ -58,12 +101,29 @@ func Register(r *gin.Engine, cfg *config.Config, db *gorm.DB) {
 		// set resource folder
 		api.Static("/resources", "./resources")
 
+		// 日历订阅 (iCalendar)，通过地址中的令牌鉴权
+		api.GET("/calendar/feeds/:file", calendarH.Feed)
+
+		// 出门凭证校验 (门禁设备)
+		api.GET("/exit-passes/public-key", leaveH.ExitPassPublicKey)
+		api.POST("/exit-passes/verify", leaveH.VerifyExitPass)
+
 		// 受保护路由 (Internal Users)
 		protected := api.Group("/")
 		protected.Use(middleware.AuthMiddleware(cfg))
 		{
-			protected.GET("/user/profile", userH.GetProfile)  // 获取用户信息
-			protected.GET("/user/org_info", userH.GetOrgInfo) // 新增：获取用户组织详细信息
+			protected.GET("/user/profile", userH.GetProfile)                       // 获取用户信息
+			protected.GET("/user/org_info", userH.GetOrgInfo)                      // 新增：获取用户组织详细信息
+			protected.PUT("/user/emergency_contact", userH.UpdateEmergencyContact) // 登记紧急联系人
+
+			// 组织架构 (Org Tree)，修改需要 org:manage 权限
+			protected.GET("/org-units", orgUnitH.GetTree)                         // 组织架构树及学生数
+			protected.POST("/org-units", orgUnitH.Create)                         // 新建节点
+			protected.PUT("/org-units/:id", orgUnitH.Rename)                      // 修改节点名称
+			protected.PUT("/org-units/:id/parent", orgUnitH.Move)                 // 移动节点
+			protected.DELETE("/org-units/:id", orgUnitH.Delete)                   // 删除节点
+			protected.PUT("/departments/:id/org-unit", orgUnitH.AttachDepartment) // 部门关联到节点
+			protected.PUT("/classes/:id/org-unit", orgUnitH.AttachClass)          // 班级关联到节点
 
 			// 组织管理 (Org Management)
 			// 辅导员相关 (Counselor)
@@ -72,26 +132,71 @@ func Register(r *gin.Engine, cfg *config.Config, db *gorm.DB) {
 			protected.GET("/departments/mine/:deptId", orgH.ListDepartmentStudent) // 通过部门Id获取部门详情和学生信息
 			protected.GET("/leaves/pending", leaveH.ListPendingLeaves)             // 待审批请假
 			protected.POST("/leaves/audit", leaveH.Audit)                          // 审批请假 (Changed from /leaves/:uuid/audit)
+			protected.POST("/leaves/audit/batch", leaveH.BatchAudit)               // 批量审批请假
 			protected.POST("/leaves/data", leaveH.LeaveData)                       // 请假数据统计
 			protected.POST("/leaves/leavebackinfo", leaveH.LeaveBackInfo)          // 未归统计
+			protected.POST("/leaves/delegations", leaveH.CreateDelegation)         // 委托审批
+			protected.GET("/leaves/delegations", leaveH.ListMyDelegations)         // 我的审批委托
+			protected.DELETE("/leaves/delegations/:id", leaveH.RevokeDelegation)   // 撤销委托
+			protected.GET("/leaves/escalations/:leaveId", leaveH.ListEscalations)  // 逾期提醒记录
+			protected.GET("/leaves/reports", leaveH.LeaveReport)                   // 请假汇总报表
+			protected.GET("/leaves/reports/export", leaveH.ExportLeaveReport)      // 导出请假报表
 
 			// 教师相关 (Teacher)
 			protected.POST("/classes", orgH.CreateClass)                   // 创建班级
 			protected.GET("/classes/mine", orgH.ListMyClasses)             // 我的班级
 			protected.GET("/classes/mine/:classId", orgH.ListClassStudent) // 通过班级Id获取班级详情和学生信息
+			protected.GET("/leaves/classes", leaveH.ListClassLeaves)       // 班级学生当日请假 (只读)
 
 			// 通用发布 (Counselor & Teacher)
-			protected.POST("/notifications", notifH.Create) // 发布通知
-			//protected.POST("/tasks", taskH.CreateTask)      // 发布任务
+			protected.POST("/notifications", notifH.Create)                           // 发布通知 (可保存草稿或定时发送)
+			protected.GET("/notifications/schedules", notifH.ListSchedules)           // 我的草稿和定时通知
+			protected.PUT("/notifications/:id", notifH.UpdateSchedule)                // 修改草稿或定时通知
+			protected.POST("/notifications/:id/publish", notifH.PublishDraft)         // 提交草稿
+			protected.POST("/notifications/:id/cancel", notifH.CancelSchedule)        // 取消定时通知
+			protected.PUT("/notifications/:id/content", notifH.Edit)                  // 修改已发布的通知
+			protected.GET("/notifications/:id/versions", notifH.ListVersions)         // 通知历史版本
+			protected.POST("/notifications/:id/recall", notifH.Recall)                // 撤回通知
+			protected.GET("/notifications/templates", templateH.List)                 // 通知模板
+			protected.POST("/notifications/templates", templateH.Create)              // 新建模板
+			protected.PUT("/notifications/templates/:id", templateH.Update)           // 修改模板
+			protected.DELETE("/notifications/templates/:id", templateH.Delete)        // 删除模板
+			protected.POST("/tasks", taskH.CreateTask)                                // 发布任务
+			protected.GET("/tasks/created", taskH.ListCreatedTasks)                   // 我发布的任务
+			protected.PUT("/tasks/:uuid", taskH.UpdateTask)                           // 修改任务
+			protected.DELETE("/tasks/:uuid", taskH.DeleteTask)                        // 删除任务
+			protected.GET("/tasks/:uuid/results", taskH.GetSurveyResults)             // 问卷汇总
+			protected.GET("/tasks/:uuid/results/export", taskH.ExportSurveyResponses) // 导出问卷回答
+			protected.GET("/tasks/:uuid/records", taskH.ListSubmissions)              // 提交列表
+			protected.PUT("/tasks/:uuid/records/:id/review", taskH.ReviewSubmission)  // 审阅提交
+			protected.GET("/tasks/:uuid/completion", taskH.GetCompletion)             // 完成情况
+			protected.POST("/tasks/:uuid/completion/remind", taskH.RemindMissing)     // 提醒未提交的学生
+			protected.GET("/tasks/:uuid/completion/export", taskH.ExportCompletion)   // 导出完成情况
 
 			// 学生相关 (Student)
-			protected.POST("/departments/join", orgH.StudentJoinDepartment) // 加入部门
-			protected.POST("/classes/join", orgH.StudentJoinClass)          // 加入班级
-			protected.POST("/leaves", leaveH.Apply)                         // 申请请假
-			protected.GET("/leaves/mine", leaveH.MyLeaves)                  // 我的请假
-			protected.GET("/notifications/mine", notifH.GetMyNotifications) // 我的通知
-			//protected.GET("/tasks/mine", taskH.GetMyTasks)                  // 我的任务
-			//protected.POST("/tasks/:uuid/submit", taskH.SubmitTask)         // 提交任务
+			protected.POST("/departments/join", orgH.StudentJoinDepartment)              // 加入部门
+			protected.POST("/classes/join", orgH.StudentJoinClass)                       // 加入班级
+			protected.POST("/leaves", leaveH.Apply)                                      // 申请请假
+			protected.GET("/leaves/mine", leaveH.MyLeaves)                               // 我的请假
+			protected.POST("/leaves/share", leaveH.UpdateShareConsent)                   // 设置是否向任课教师公开请假详情
+			protected.POST("/leaves/cancel", leaveH.Cancel)                              // 取消请假
+			protected.GET("/leaves/pass/:leaveId", leaveH.GetExitPass)                   // 获取电子出门凭证
+			protected.GET("/notifications/mine", notifH.GetMyNotifications)              // 我的通知
+			protected.GET("/notifications/:id/deliveries", notifH.GetDeliveryStatus)     // 通知投递状态 (发送者)
+			protected.GET("/notifications/:id/reads", notifH.GetReadStats)               // 通知阅读统计 (发送者)
+			protected.POST("/notifications/:id/read", notifH.MarkRead)                   // 标记已读
+			protected.POST("/notifications/read-all", notifH.MarkAllRead)                // 全部已读
+			protected.GET("/notifications/unread-count", notifH.UnreadCount)             // 未读数
+			protected.GET("/notifications/inbox", notifH.Inbox)                          // 收件箱
+			protected.GET("/notifications/preferences", notifH.GetPreferences)           // 消息接收偏好
+			protected.PUT("/notifications/preferences", notifH.UpdatePreferences)        // 修改消息接收偏好
+			protected.POST("/notifications/:id/ack", notifH.Acknowledge)                 // 确认知悉
+			protected.GET("/notifications/:id/acks", notifH.GetAckStats)                 // 确认情况 (发送者)
+			protected.GET("/notifications/:id/acks/export", notifH.ExportAckList)        // 导出确认名单 (发送者)
+			protected.GET("/tasks/mine", taskH.GetMyTasks)                               // 我的任务
+			protected.GET("/tasks/:uuid", taskH.GetTask)                                 // 任务详情
+			protected.POST("/tasks/:uuid/submit", taskH.SubmitTask)                      // 提交任务 (被退回后可重新提交)
+			protected.GET("/tasks/:uuid/records/:id/versions", taskH.ListRecordVersions) // 提交的历史版本
 
 			// 列表查看 (List View)
 			protected.GET("/students", userH.ListStudents)
@@ -107,6 +212,18 @@ func Register(r *gin.Engine, cfg *config.Config, db *gorm.DB) {
 			// 新增统计接口
 			protected.GET("/dings/stats", dingH.GetDingStats)
 
+			// 假期离校登记 (Holiday Registration)
+			protected.POST("/holidays/campaigns", holidayH.CreateCampaign)             // 发起离校登记
+			protected.GET("/holidays/campaigns/mine", holidayH.ListMyCreatedCampaigns) // 我发起的离校登记
+			protected.GET("/holidays/campaigns/:id/stats", holidayH.GetCampaignStats)  // 登记完成情况
+			protected.GET("/holidays/mine", holidayH.ListMyCampaigns)                  // 学生待填写的登记
+			protected.POST("/holidays/campaigns/:id/register", holidayH.Register)      // 学生填写登记
+
+			// 日历订阅管理
+			protected.GET("/calendar/feed", calendarH.GetFeed)                    // 获取订阅地址
+			protected.POST("/calendar/feed/regenerate", calendarH.RegenerateFeed) // 重新生成订阅地址
+			protected.DELETE("/calendar/feed", calendarH.RevokeFeed)              // 撤销订阅地址
+
 			// 工具
 			protected.POST("/exportListOfObjectsUploaded", userH.ExportListOfObjectsUpload) // 上传导出列表文件
 		}
@@ -20,12 +20,15 @@ type RegisterRequest struct {
 	StudentNo  *string `json:"student_no"`
 	InviteCode string  `json:"invite_code"`
 	PushToken  string  `json:"push_token"`
+	// PushPlatform 推送平台 fcm / apns，为空按 fcm 处理
+	PushPlatform string `json:"push_platform"`
 }
 
 type LoginRequest struct {
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	PushToken string `json:"push_token"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	PushToken    string `json:"push_token"`
+	PushPlatform string `json:"push_platform"`
 }
 
 type AuthService interface {
@@ -93,6 +96,7 @@ func (s *authService) Register(req RegisterRequest) (string, error) {
 		StaffNo:      req.StaffNo,
 		StudentNo:    req.StudentNo,
 		PushToken:    req.PushToken,
+		PushPlatform: req.PushPlatform,
 	}
 
 	if err := s.userRepo.CreateUser(&user); err != nil {
@@ -129,8 +133,9 @@ func (s *authService) Login(req LoginRequest) (string, error) {
 	}
 
 	// Update Push Token
-	if req.PushToken != "" && req.PushToken != user.PushToken {
+	if req.PushToken != "" && (req.PushToken != user.PushToken || req.PushPlatform != user.PushPlatform) {
 		user.PushToken = req.PushToken
+		user.PushPlatform = req.PushPlatform
 		if err := s.userRepo.UpdateUser(user); err != nil {
 			// Log error but proceed?
 		}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/internal/utils"
+)
+
+// calendarLookback 日历订阅包含的历史范围
+const calendarLookback = 30 * 24 * time.Hour
+
+type CalendarService interface {
+	GetFeedURL(userID uint) (string, error)
+	RegenerateFeed(userID uint) (string, error)
+	RevokeFeed(userID uint) error
+	RenderFeed(token string) (string, error)
+}
+
+type calendarService struct {
+	calendarRepo repo.CalendarRepository
+	dingRepo     repo.DingRepository
+	leaveRepo    repo.LeaveRepository
+	orgRepo      repo.OrgRepository
+	userRepo     repo.UserRepository
+	publicURL    string
+}
+
+func NewCalendarService(calendarRepo repo.CalendarRepository, dingRepo repo.DingRepository, leaveRepo repo.LeaveRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, cfg *config.Config) CalendarService {
+	return &calendarService{
+		calendarRepo: calendarRepo,
+		dingRepo:     dingRepo,
+		leaveRepo:    leaveRepo,
+		orgRepo:      orgRepo,
+		userRepo:     userRepo,
+		publicURL:    strings.TrimRight(cfg.Server.PublicURL, "/"),
+	}
+}
+
+// GetFeedURL 返回订阅地址，首次访问时生成令牌
+func (s *calendarService) GetFeedURL(userID uint) (string, error) {
+	feed, err := s.calendarRepo.GetFeedByUserID(userID)
+	if err != nil {
+		return s.RegenerateFeed(userID)
+	}
+	return s.feedURL(feed.Token), nil
+}
+
+// RegenerateFeed 重新生成令牌，旧的订阅地址立即失效
+func (s *calendarService) RegenerateFeed(userID uint) (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bytes)
+
+	feed, err := s.calendarRepo.GetFeedByUserID(userID)
+	if err != nil {
+		feed = &model.CalendarFeed{UserID: userID}
+	}
+	feed.Token = token
+	if err := s.calendarRepo.SaveFeed(feed); err != nil {
+		return "", err
+	}
+	return s.feedURL(token), nil
+}
+
+func (s *calendarService) RevokeFeed(userID uint) error {
+	return s.calendarRepo.DeleteFeedByUserID(userID)
+}
+
+func (s *calendarService) feedURL(token string) string {
+	return fmt.Sprintf("%s/api/v1/calendar/feeds/%s.ics", s.publicURL, token)
+}
+
+// RenderFeed 根据令牌生成用户的日历：
+// 学生为自己的打卡任务和请假；教职工为自己发布的打卡任务和所管理学生的已批准请假。
+func (s *calendarService) RenderFeed(token string) (string, error) {
+	feed, err := s.calendarRepo.GetFeedByToken(token)
+	if err != nil {
+		return "", errors.New("订阅地址无效")
+	}
+	user, err := s.userRepo.GetUserByIDWithRole(feed.UserID)
+	if err != nil {
+		return "", errors.New("订阅地址无效")
+	}
+
+	since := time.Now().Add(-calendarLookback)
+	var events []utils.ICalEvent
+	if user.Role.Key == "student" {
+		events, err = s.studentEvents(user.ID, since)
+	} else {
+		events, err = s.staffEvents(user, since)
+	}
+	if err != nil {
+		return "", err
+	}
+	return utils.RenderICal("UniHub - "+user.Nickname, events), nil
+}
+
+func (s *calendarService) studentEvents(studentID uint, since time.Time) ([]utils.ICalEvent, error) {
+	var events []utils.ICalEvent
+
+	dings, err := s.dingRepo.ListDingsWithStatusByStudentID(studentID, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dings {
+		e := dingEvent(d.Ding)
+		switch d.DingStatus {
+		case "complete":
+			e.Description = "已打卡"
+		case "cancelled":
+			// 请假取消后返校打卡随之取消
+			e.Status = "CANCELLED"
+		}
+		// 学生的打卡记录变化 (打卡、取消) 也要让订阅端更新
+		if d.DingStatusUpdatedAt.After(e.LastModified) {
+			e.Sequence = d.DingStatusUpdatedAt.Unix()
+			e.LastModified = d.DingStatusUpdatedAt
+		}
+		events = append(events, e)
+	}
+
+	leaves, err := s.leaveRepo.ListLeavesForCalendar([]uint{studentID}, since, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range leaves {
+		events = append(events, leaveEvent(l, "请假："+l.Type))
+	}
+	return events, nil
+}
+
+func (s *calendarService) staffEvents(user *model.User, since time.Time) ([]utils.ICalEvent, error) {
+	var events []utils.ICalEvent
+
+	dings, err := s.dingRepo.ListDingsByLauncherSince(user.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dings {
+		events = append(events, dingEvent(d))
+	}
+
+	var studentIDs []uint
+	switch user.Role.Key {
+	case "counselor":
+		students, _ := s.orgRepo.ListStudentsByCounselorID(user.ID)
+		for _, st := range students {
+			studentIDs = append(studentIDs, st.ID)
+		}
+	case "teacher":
+		classes, err := s.orgRepo.ListClassesByTeacherID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range classes {
+			ids, _ := s.orgRepo.GetStudentIDsByClassID(c.ID)
+			studentIDs = append(studentIDs, ids...)
+		}
+	}
+
+	leaves, err := s.leaveRepo.ListLeavesForCalendar(uniqueUints(studentIDs), since, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range leaves {
+		events = append(events, leaveEvent(l, fmt.Sprintf("%s 请假：%s", l.StudentName, l.Type)))
+	}
+	return events, nil
+}
+
+func dingEvent(d model.Ding) utils.ICalEvent {
+	return utils.ICalEvent{
+		UID:          fmt.Sprintf("ding-%d@unihub", d.ID),
+		Summary:      "打卡：" + d.Title,
+		Start:        d.StartTime,
+		End:          d.EndTime,
+		Status:       "CONFIRMED",
+		Sequence:     d.UpdatedAt.Unix(),
+		LastModified: d.UpdatedAt,
+	}
+}
+
+// leaveEvent 将请假转换为日历事件，驳回、取消或删除的请假以 CANCELLED 下发
+func leaveEvent(l repo.LeaveWithStudent, summary string) utils.ICalEvent {
+	status := "CONFIRMED"
+	switch {
+	case l.DeletedAt.Valid, l.Status == "rejected", l.Status == "cancelled":
+		status = "CANCELLED"
+	case l.Status == "pending":
+		status = "TENTATIVE"
+	}
+	modified := l.UpdatedAt
+	if l.DeletedAt.Valid && l.DeletedAt.Time.After(modified) {
+		modified = l.DeletedAt.Time
+	}
+	return utils.ICalEvent{
+		UID:          fmt.Sprintf("leave-%d@unihub", l.ID),
+		Summary:      summary,
+		Start:        l.StartTime,
+		End:          l.EndTime,
+		Status:       status,
+		Sequence:     modified.Unix(),
+		LastModified: modified,
+	}
+}
+
+func uniqueUints(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,11 @@
+package service
+
+import "unihub/internal/model"
+
+// DeliveryChannel 通知投递渠道 (推送、邮件)，投递队列会把每条通知依次交给所有已启用的渠道
+type DeliveryChannel interface {
+	// Name 渠道标识，与 PushAttempt.Channel 对应
+	Name() string
+	// Deliver 向指定用户投递，返回可重试的失败用户
+	Deliver(notif model.Notification, userIDs []uint) ([]uint, error)
+}
@@ -8,33 +8,35 @@ import (
 	"unihub/internal/model"
 	"unihub/internal/repo"
 	"unihub/internal/utils"
-
-	"gorm.io/gorm"
 )
 
 type DingService interface {
 	CreateDing(req DTO.CreateDingRequest, launcherID uint, roleID uint) (uint, error)
+	NotifyDingCreated(ding *model.Ding, studentIDs []uint)
 	ListAllMyDings(studentID uint) (map[string][]model.Ding, error)
 	ListMyCreatedDings(launcherID uint) ([]model.Ding, error)
 	ListMyCreatedDingsRecords(userId uint, dingID string) (interface{}, interface{})
 	ExportMyCreatedDingRecords(dingId string) (interface{}, interface{})
 	Ding(dingIdStr string, userIdInt uint) (interface{}, interface{})
 	GetDingStats(launcherID uint) (map[string]int64, error)
+	CancelStudentDing(dingID, studentID uint) error
 }
 
 type dingService struct {
-	dingRepo repo.DingRepository
-	orgRepo  repo.OrgRepository
-	userRepo repo.UserRepository
-	db       *gorm.DB // Kept for transaction or utils.PushNotification if refactoring notification is not done yet
+	dingRepo  repo.DingRepository
+	orgRepo   repo.OrgRepository
+	userRepo  repo.UserRepository
+	notifRepo repo.NotificationRepository
+	templates TemplateService
 }
 
-func NewDingService(dingRepo repo.DingRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, db *gorm.DB) DingService {
+func NewDingService(dingRepo repo.DingRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, notifRepo repo.NotificationRepository, templates TemplateService) DingService {
 	return &dingService{
-		dingRepo: dingRepo,
-		orgRepo:  orgRepo,
-		userRepo: userRepo,
-		db:       db,
+		dingRepo:  dingRepo,
+		orgRepo:   orgRepo,
+		userRepo:  userRepo,
+		notifRepo: notifRepo,
+		templates: templates,
 	}
 }
 
@@ -87,23 +89,52 @@ func (s *dingService) CreateDing(req DTO.CreateDingRequest, launcherID uint, _ u
 		if err := s.dingRepo.CreateDingStudent(&dingStudent); err != nil {
 			return 0, err
 		}
+	}
+	s.NotifyDingCreated(&ding, studentIDs)
+	return ding.ID, nil
+}
 
+// NotifyDingCreated 通知学生有新的打卡任务：每种语言保存一条通知，由投递队列批量推送，失败只记录日志
+func (s *dingService) NotifyDingCreated(ding *model.Ding, studentIDs []uint) {
+	groups := s.templates.RenderForUsers("ding_created", studentIDs, map[string]string{
+		"ding_title": ding.Title,
+		"deadline":   ding.EndTime.Format("01-02 15:04"),
+	})
+	for _, g := range groups {
 		notif := model.Notification{
-			Title:      "新的打卡任务：" + req.Title,
-			Content:    "请在规定时间内完成打卡任务。",
-			SenderID:   launcherID,
-			TargetType: "student",
-			TargetID:   studentID,
+			Title:    g.Title,
+			Content:  g.Content,
+			SenderID: ding.LauncherID,
+			Category: g.Category,
+			RefType:  "ding",
+			RefID:    ding.ID,
+		}
+		if len(groups) == 1 {
+			notif.TargetType, notif.TargetID = dingTarget(ding)
+		} else {
+			// 接收人语言不同时按语言拆分，每条通知的受众为对应的学生
+			notif.TargetType = "audience"
+			for _, id := range g.UserIDs {
+				notif.Audience = append(notif.Audience, model.NotificationAudience{TargetType: "student", TargetID: id})
+			}
 		}
-		// TODO: Refactor Notification logic into NotificationService
-		if _, err := utils.PushNotification(notif, s.db); err != nil {
-			// logging error but not failing the whole process?
-			log.Printf("Failed to push notification to student %d: %v", studentID, err)
+		if err := s.notifRepo.CreateNotificationWithOutbox(&notif); err != nil {
+			log.Printf("Failed to notify %d students of ding %d: %v", len(g.UserIDs), ding.ID, err)
 		} else {
-			log.Printf("已向学生 %d 发送打卡任务通知", studentID)
+			log.Printf("已向 %d 名学生发送打卡任务通知", len(g.UserIDs))
 		}
 	}
-	return ding.ID, nil
+}
+
+// dingTarget 打卡任务对应的通知目标
+func dingTarget(ding *model.Ding) (string, uint) {
+	switch {
+	case ding.DeptID != 0:
+		return "dept", ding.DeptID
+	case ding.ClassID != 0:
+		return "class", ding.ClassID
+	}
+	return "student", ding.UserID
 }
 
 func (s *dingService) ListAllMyDings(studentID uint) (map[string][]model.Ding, error) {
@@ -172,3 +203,8 @@ func (s *dingService) GetDingStats(launcherID uint) (map[string]int64, error) {
 		"missed_count":  total - checked,
 	}, nil
 }
+
+// CancelStudentDing 取消学生的某个打卡 (如请假取消后的返校签到)
+func (s *dingService) CancelStudentDing(dingID, studentID uint) error {
+	return s.dingRepo.UpdateDingStudentStatus(dingID, studentID, "cancelled")
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"log"
+	"net/mail"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/internal/utils"
+	"unihub/pkg/mailer"
+)
+
+// categoryNames 消息分类的显示名称
+var categoryNames = map[string]string{
+	"announcement": "通知公告",
+	"ding":         "打卡任务",
+	"leave":        "请假",
+	"task":         "任务",
+	"system":       "系统消息",
+}
+
+// emailChannel 通过 SMTP 发送通知邮件，只发给在偏好中为该类消息开启了邮件的用户
+type emailChannel struct {
+	prefRepo repo.PreferenceRepository
+	pushRepo repo.PushRepository
+	mailer   *mailer.Mailer
+}
+
+func NewEmailChannel(prefRepo repo.PreferenceRepository, pushRepo repo.PushRepository, cfg *config.Config) (DeliveryChannel, error) {
+	m, err := mailer.New(cfg.Email.Host, cfg.Email.Port, cfg.Email.Username, cfg.Email.Password, cfg.Email.From)
+	if err != nil {
+		return nil, err
+	}
+	return &emailChannel{prefRepo: prefRepo, pushRepo: pushRepo, mailer: m}, nil
+}
+
+func (c *emailChannel) Name() string { return "email" }
+
+func (c *emailChannel) Deliver(notif model.Notification, userIDs []uint) ([]uint, error) {
+	users, err := c.prefRepo.ListEmailRecipients(userIDs, notif.Category)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+
+	categoryName := categoryNames[notif.Category]
+	if categoryName == "" {
+		categoryName = categoryNames["announcement"]
+	}
+	personalized := utils.HasRecipientPlaceholders(notif.Title + notif.Content)
+	var subject, text, html string
+	if !personalized {
+		if subject, text, html, err = renderEmail(notif, categoryName); err != nil {
+			return nil, err
+		}
+	}
+
+	var failed []uint
+	attempts := make([]model.PushAttempt, 0, len(users))
+	for _, u := range users {
+		attempt := model.PushAttempt{
+			NotificationID: notif.ID, UserID: u.ID, Channel: "email", Provider: "smtp", Token: u.Email,
+			Status: "sent",
+		}
+		if personalized {
+			if subject, text, html, err = renderEmail(personalize(notif, &u), categoryName); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.mailer.Send(&mail.Address{Name: u.Nickname, Address: u.Email}, subject, text, html); err != nil {
+			attempt.Status = "failed"
+			attempt.Error = truncate(err.Error(), 255)
+			failed = append(failed, u.ID)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err := c.pushRepo.CreateAttempts(attempts); err != nil {
+		log.Printf("email: failed to record attempts for notification %d: %v", notif.ID, err)
+	}
+	return failed, nil
+}
+
+func renderEmail(notif model.Notification, categoryName string) (subject, text, html string, err error) {
+	content := notif.Content
+	if notif.Format == "markdown" {
+		content = utils.MarkdownToText(content)
+	}
+	text, html, err = utils.RenderNotificationEmail(utils.NotificationEmail{
+		Title:        notif.Title,
+		Content:      content,
+		CategoryName: categoryName,
+		SentAt:       notif.CreatedAt.Format("2006-01-02 15:04"),
+	})
+	return "【" + categoryName + "】" + notif.Title, text, html, err
+}
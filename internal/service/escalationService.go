@@ -0,0 +1,200 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+)
+
+// EscalationService 逾期未返校的逐级提醒
+type EscalationService interface {
+	RunEscalations() error
+	ListEscalations(userID, leaveID uint) ([]model.LeaveEscalation, error)
+}
+
+type escalationService struct {
+	leaveRepo repo.LeaveRepository
+	orgRepo   repo.OrgRepository
+	userRepo  repo.UserRepository
+	notifRepo repo.NotificationRepository
+	templates TemplateService
+	steps     []config.EscalationStep
+	window    time.Duration
+}
+
+func NewEscalationService(leaveRepo repo.LeaveRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, notifRepo repo.NotificationRepository, templates TemplateService, cfg *config.Config) EscalationService {
+	return &escalationService{
+		leaveRepo: leaveRepo,
+		orgRepo:   orgRepo,
+		userRepo:  userRepo,
+		notifRepo: notifRepo,
+		templates: templates,
+		steps:     cfg.Leave.Escalation,
+		window:    cfg.Leave.EscalationWindow,
+	}
+}
+
+// RunEscalations 检查每一级提醒的到期请假并发送通知。
+// 只选取返校签到仍为 pending 的请假，学生完成签到后后续级别自然不再触发。
+func (s *escalationService) RunEscalations() error {
+	now := time.Now()
+	for i, step := range s.steps {
+		level := i + 1
+		var endAfter *time.Time
+		if s.window > 0 {
+			t := now.Add(-s.window)
+			endAfter = &t
+		}
+
+		leaves, err := s.leaveRepo.ListOverdueLeavesForEscalation(level, now.Add(-step.After), endAfter)
+		if err != nil {
+			return err
+		}
+		for i := range leaves {
+			s.escalate(&leaves[i], level, step)
+		}
+	}
+	return nil
+}
+
+func (s *escalationService) escalate(leave *model.LeaveRequest, level int, step config.EscalationStep) {
+	student, err := s.userRepo.GetUserByID(leave.StudentID)
+	if err != nil {
+		log.Printf("escalation: student %d not found for leave %d", leave.StudentID, leave.ID)
+		return
+	}
+	vars := map[string]string{
+		"student_name": student.Nickname,
+		"end_time":     leave.EndTime.Format("2006-01-02 15:04"),
+		"overdue":      time.Since(leave.EndTime).Round(time.Minute).String(),
+	}
+
+	for _, recipient := range step.Notify {
+		switch recipient {
+		case "student":
+			s.notify(leave, level, recipient, student.ID, "student", s.templates.RenderForUser("leave_overdue_student", student.ID, vars))
+		case "counselor":
+			deptID, _ := s.orgRepo.GetStudentDepartmentID(student.ID)
+			dept, err := s.orgRepo.GetDepartmentByID(deptID)
+			if deptID == 0 || err != nil {
+				s.record(leave.ID, level, recipient, 0, "skipped", "学生未加入部门")
+				continue
+			}
+			s.notify(leave, level, recipient, dept.CounselorID, "user", s.templates.RenderForUser("leave_overdue_staff", dept.CounselorID, vars))
+		case "admin":
+			admins := s.departmentAdmins(student.ID)
+			if len(admins) == 0 {
+				s.record(leave.ID, level, recipient, 0, "skipped", "未找到学院管理员")
+				continue
+			}
+			for _, admin := range admins {
+				msg := s.templates.RenderForUser("leave_overdue_staff", admin.ID, vars)
+				if student.EmergencyContactPhone != "" {
+					msg.Content += fmt.Sprintf("紧急联系人：%s %s。", student.EmergencyContactName, student.EmergencyContactPhone)
+				}
+				s.notify(leave, level, recipient, admin.ID, "user", msg)
+			}
+		case "emergency_contact":
+			// 暂无短信通道，记录联系人信息供管理员线下联系
+			if student.EmergencyContactPhone == "" {
+				s.record(leave.ID, level, recipient, 0, "skipped", "学生未登记紧急联系人")
+				continue
+			}
+			s.record(leave.ID, level, recipient, 0, "pending",
+				fmt.Sprintf("待联系 %s %s", student.EmergencyContactName, student.EmergencyContactPhone))
+		default:
+			s.record(leave.ID, level, recipient, 0, "skipped", "未知的通知对象")
+		}
+	}
+}
+
+// departmentAdmins 学生所在部门的管理员：优先同一部门，其次组织架构中最近的上级节点
+func (s *escalationService) departmentAdmins(studentID uint) []repo.DepartmentAdmin {
+	deptID, _ := s.orgRepo.GetStudentDepartmentID(studentID)
+	if deptID == 0 {
+		return nil
+	}
+	candidates, err := s.userRepo.ListDepartmentAdmins(deptID)
+	if err != nil {
+		log.Printf("escalation: list admins of department %d: %v", deptID, err)
+		return nil
+	}
+
+	var admins []repo.DepartmentAdmin
+	best := -1
+	for _, a := range candidates {
+		depth := len(a.UnitPath)
+		if a.SameDepartment {
+			depth = math.MaxInt
+		}
+		switch {
+		case depth > best:
+			best = depth
+			admins = []repo.DepartmentAdmin{a}
+		case depth == best:
+			admins = append(admins, a)
+		}
+	}
+	return admins
+}
+
+// notify 发送站内通知 (推送由通知投递队列完成)，同时记录提醒日志。
+// 逾期提醒为紧急通知，不受接收人免打扰时段限制。
+func (s *escalationService) notify(leave *model.LeaveRequest, level int, recipient string, userID uint, targetType string, msg RenderedMessage) {
+	notif := model.Notification{
+		Title:      msg.Title,
+		Content:    msg.Content,
+		SenderID:   0, // 系统发送
+		TargetType: targetType,
+		TargetID:   userID,
+		Category:   msg.Category,
+		RefType:    "leave",
+		RefID:      leave.ID,
+		Urgent:     true,
+	}
+	if err := s.notifRepo.CreateNotificationWithOutbox(&notif); err != nil {
+		s.record(leave.ID, level, recipient, userID, "failed", err.Error())
+		return
+	}
+	s.record(leave.ID, level, recipient, userID, "sent", "")
+}
+
+func (s *escalationService) record(leaveID uint, level int, recipient string, recipientID uint, status, detail string) {
+	e := model.LeaveEscalation{
+		LeaveID:     leaveID,
+		Level:       level,
+		Recipient:   recipient,
+		RecipientID: recipientID,
+		Status:      status,
+		Detail:      detail,
+	}
+	if err := s.leaveRepo.CreateEscalation(&e); err != nil {
+		log.Printf("escalation: failed to record leave %d level %d: %v", leaveID, level, err)
+	}
+}
+
+// ListEscalations 辅导员/审批人查看某条请假的提醒记录
+func (s *escalationService) ListEscalations(userID, leaveID uint) ([]model.LeaveEscalation, error) {
+	leave, err := s.leaveRepo.GetLeaveRequestByID(leaveID)
+	if err != nil {
+		return nil, errors.New("请假记录不存在")
+	}
+
+	allowed := leave.AuditorID != nil && *leave.AuditorID == userID
+	if !allowed {
+		deptID, _ := s.orgRepo.GetStudentDepartmentID(leave.StudentID)
+		if dept, err := s.orgRepo.GetDepartmentByID(deptID); deptID != 0 && err == nil && dept.CounselorID == userID {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, errors.New("无权查看该请假")
+	}
+
+	return s.leaveRepo.ListEscalationsByLeaveID(leaveID)
+}
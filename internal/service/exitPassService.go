@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"time"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/pkg/passutil"
+)
+
+// PassVerifyResult 门禁扫码校验结果
+type PassVerifyResult struct {
+	Valid  bool             `json:"valid"`
+	Reason string           `json:"reason,omitempty"`
+	Claims *passutil.Claims `json:"claims,omitempty"`
+}
+
+type ExitPassService interface {
+	Issue(leave *model.LeaveRequest) (*model.ExitPass, error)
+	Revoke(leaveID uint) error
+	GetMyPass(studentID, leaveID uint) (*model.ExitPass, error)
+	PublicKey() string
+	Verify(token string) *PassVerifyResult
+}
+
+type exitPassService struct {
+	passRepo repo.ExitPassRepository
+	userRepo repo.UserRepository
+	key      ed25519.PrivateKey
+	grace    time.Duration
+}
+
+func NewExitPassService(passRepo repo.ExitPassRepository, userRepo repo.UserRepository, cfg *config.Config) ExitPassService {
+	return &exitPassService{
+		passRepo: passRepo,
+		userRepo: userRepo,
+		key:      passutil.KeyFromSeed(cfg.ExitPass.SigningKey),
+		grace:    cfg.ExitPass.Grace,
+	}
+}
+
+// Issue 为已批准的请假签发出门凭证，有效期为请假时间前后各放宽 grace。
+// 已签发过则直接返回，上次未签名完成的凭证会重新签名，可安全重试。
+func (s *exitPassService) Issue(leave *model.LeaveRequest) (*model.ExitPass, error) {
+	existing, err := s.passRepo.GetPassByLeaveID(leave.ID)
+	if err == nil && existing.Payload != "" {
+		return existing, nil
+	}
+
+	student, err := s.userRepo.GetUserByID(leave.StudentID)
+	if err != nil {
+		return nil, err
+	}
+
+	pass := model.ExitPass{
+		LeaveID:    leave.ID,
+		StudentID:  leave.StudentID,
+		ValidFrom:  leave.StartTime.Add(-s.grace),
+		ValidUntil: leave.EndTime.Add(s.grace),
+	}
+	if existing != nil {
+		pass.ID = existing.ID
+		pass.CreatedAt = existing.CreatedAt
+	} else if err := s.passRepo.CreatePass(&pass); err != nil {
+		return nil, err
+	}
+
+	claims := passutil.Claims{
+		PassID:      pass.ID,
+		LeaveID:     leave.ID,
+		StudentID:   student.ID,
+		StudentName: student.Nickname,
+		NotBefore:   pass.ValidFrom.Unix(),
+		ExpiresAt:   pass.ValidUntil.Unix(),
+	}
+	if student.StudentNo != nil {
+		claims.StudentNo = *student.StudentNo
+	}
+	payload, err := passutil.Sign(s.key, claims)
+	if err != nil {
+		return nil, err
+	}
+	pass.Payload = payload
+	if err := s.passRepo.UpdatePass(&pass); err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+// Revoke 吊销请假对应的出门凭证，没有凭证时直接返回
+func (s *exitPassService) Revoke(leaveID uint) error {
+	return s.passRepo.RevokePassByLeaveID(leaveID, time.Now())
+}
+
+func (s *exitPassService) GetMyPass(studentID, leaveID uint) (*model.ExitPass, error) {
+	pass, err := s.passRepo.GetPassByLeaveID(leaveID)
+	if err != nil || pass.StudentID != studentID {
+		return nil, errors.New("出门凭证不存在")
+	}
+	if pass.RevokedAt != nil {
+		return nil, errors.New("出门凭证已吊销")
+	}
+	return pass, nil
+}
+
+// PublicKey 返回 Base64 编码的 Ed25519 公钥，供门禁设备离线验签
+func (s *exitPassService) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Verify 在线校验：验签、有效期及是否已吊销。接口无需登录，无效凭证不返回学生信息
+func (s *exitPassService) Verify(token string) *PassVerifyResult {
+	claims, err := passutil.Verify(s.key.Public().(ed25519.PublicKey), token, time.Now())
+	if err != nil {
+		return &PassVerifyResult{Valid: false, Reason: err.Error()}
+	}
+
+	pass, err := s.passRepo.GetPassByID(claims.PassID)
+	if err != nil || pass.LeaveID != claims.LeaveID {
+		return &PassVerifyResult{Valid: false, Reason: "凭证不存在"}
+	}
+	if pass.RevokedAt != nil {
+		return &PassVerifyResult{Valid: false, Reason: "凭证已吊销"}
+	}
+	return &PassVerifyResult{Valid: true, Claims: claims}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+)
+
+type CreateHolidayCampaignRequest struct {
+	CreatorID     uint
+	RoleID        uint
+	Title         string
+	StartTime     time.Time
+	EndTime       time.Time
+	ReturnDate    time.Time
+	DepartmentIDs []uint // 为空时：辅导员为名下全部部门，管理员为全校
+}
+
+type HolidayRegisterRequest struct {
+	StudentID     uint
+	CampaignID    uint
+	Destination   string
+	Transport     string
+	DepartureTime time.Time
+	ReturnTime    time.Time
+	Remark        string
+}
+
+type HolidayService interface {
+	CreateCampaign(req CreateHolidayCampaignRequest) (*model.HolidayCampaign, error)
+	ListMyCreatedCampaigns(userID uint) ([]model.HolidayCampaign, error)
+	ListMyCampaigns(studentID uint) ([]map[string]interface{}, error)
+	Register(req HolidayRegisterRequest) error
+	GetCampaignStats(userID, campaignID uint) (map[string]interface{}, error)
+	GenerateReturnDings() error
+}
+
+type holidayService struct {
+	holidayRepo repo.HolidayRepository
+	orgRepo     repo.OrgRepository
+	userRepo    repo.UserRepository
+	dingService DingService
+}
+
+func NewHolidayService(holidayRepo repo.HolidayRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, dingService DingService) HolidayService {
+	return &holidayService{
+		holidayRepo: holidayRepo,
+		orgRepo:     orgRepo,
+		userRepo:    userRepo,
+		dingService: dingService,
+	}
+}
+
+func (s *holidayService) CreateCampaign(req CreateHolidayCampaignRequest) (*model.HolidayCampaign, error) {
+	if allowed, _ := s.userRepo.CheckPermission(req.RoleID, "holiday:create"); !allowed {
+		return nil, errors.New("无权限发起离校登记")
+	}
+	if !req.EndTime.After(req.StartTime) || req.ReturnDate.Before(req.StartTime) {
+		return nil, errors.New("时间范围无效")
+	}
+
+	user, err := s.userRepo.GetUserByIDWithRole(req.CreatorID)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin := user.Role.Key == "admin" || user.Role.Key == "super_admin"
+
+	var depts []model.Department
+	if len(req.DepartmentIDs) == 0 {
+		if isAdmin {
+			depts, err = s.orgRepo.ListAllDepartments()
+		} else {
+			depts, err = s.orgRepo.ListDepartmentsByCounselorID(req.CreatorID)
+		}
+	} else {
+		depts, err = s.orgRepo.ListDepartmentsByIDs(req.DepartmentIDs)
+		if err == nil && len(depts) != len(req.DepartmentIDs) {
+			return nil, errors.New("部门不存在")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(depts) == 0 {
+		return nil, errors.New("没有可登记的部门")
+	}
+
+	campaign := model.HolidayCampaign{
+		Title:      req.Title,
+		CreatorID:  req.CreatorID,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		ReturnDate: req.ReturnDate,
+	}
+	for _, d := range depts {
+		if !isAdmin && d.CounselorID != req.CreatorID {
+			return nil, errors.New("只能向自己管理的部门发起登记")
+		}
+		campaign.Departments = append(campaign.Departments, model.HolidayCampaignDepartment{DepartmentID: d.ID})
+	}
+
+	if err := s.holidayRepo.CreateCampaign(&campaign); err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (s *holidayService) ListMyCreatedCampaigns(userID uint) ([]model.HolidayCampaign, error) {
+	return s.holidayRepo.ListCampaignsByCreatorID(userID)
+}
+
+// ListMyCampaigns 学生查看所在部门的登记活动及自己的登记情况
+func (s *holidayService) ListMyCampaigns(studentID uint) ([]map[string]interface{}, error) {
+	result := []map[string]interface{}{}
+	deptID, err := s.orgRepo.GetStudentDepartmentID(studentID)
+	if err != nil || deptID == 0 {
+		return result, nil
+	}
+
+	campaigns, err := s.holidayRepo.ListCampaignsByDepartmentID(deptID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range campaigns {
+		item := map[string]interface{}{
+			"campaign":     c,
+			"registration": nil,
+		}
+		if reg, err := s.holidayRepo.GetRegistration(c.ID, studentID); err == nil {
+			item["registration"] = reg
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (s *holidayService) Register(req HolidayRegisterRequest) error {
+	campaign, err := s.holidayRepo.GetCampaignByID(req.CampaignID)
+	if err != nil {
+		return errors.New("登记活动不存在")
+	}
+
+	now := time.Now()
+	if now.Before(campaign.StartTime) || now.After(campaign.EndTime) {
+		return errors.New("不在登记时间内")
+	}
+	if !req.ReturnTime.After(req.DepartureTime) {
+		return errors.New("返校时间必须晚于离校时间")
+	}
+
+	deptID, err := s.orgRepo.GetStudentDepartmentID(req.StudentID)
+	if err != nil || deptID == 0 {
+		return errors.New("学生未加入部门")
+	}
+	inScope := false
+	for _, d := range campaign.Departments {
+		if d.DepartmentID == deptID {
+			inScope = true
+			break
+		}
+	}
+	if !inScope {
+		return errors.New("无需参加该登记")
+	}
+
+	reg := model.HolidayRegistration{
+		CampaignID:    campaign.ID,
+		StudentID:     req.StudentID,
+		Destination:   req.Destination,
+		Transport:     req.Transport,
+		DepartureTime: req.DepartureTime,
+		ReturnTime:    req.ReturnTime,
+		Remark:        req.Remark,
+	}
+	return s.holidayRepo.SaveRegistration(&reg)
+}
+
+// GetCampaignStats 活动发起人查看完成情况：总人数、已登记、未登记名单及交通方式分布
+func (s *holidayService) GetCampaignStats(userID, campaignID uint) (map[string]interface{}, error) {
+	campaign, err := s.holidayRepo.GetCampaignByID(campaignID)
+	if err != nil {
+		return nil, errors.New("登记活动不存在")
+	}
+	if campaign.CreatorID != userID {
+		return nil, errors.New("无权查看该登记活动")
+	}
+
+	var deptIDs []uint
+	for _, d := range campaign.Departments {
+		deptIDs = append(deptIDs, d.DepartmentID)
+	}
+	students, err := s.userRepo.ListStudentsByDepartmentIDs(deptIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	registrations, err := s.holidayRepo.ListRegistrationsWithStudents(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[uint]bool)
+	transport := make(map[string]int)
+	for _, r := range registrations {
+		if sid, ok := toUint(r["student_id"]); ok {
+			registered[sid] = true
+		}
+		if t, ok := r["transport"].(string); ok {
+			transport[t]++
+		}
+	}
+
+	var unregistered []map[string]interface{}
+	for _, st := range students {
+		if registered[st.ID] {
+			continue
+		}
+		unregistered = append(unregistered, map[string]interface{}{
+			"student_id":   st.ID,
+			"student_name": st.Nickname,
+			"student_no":   st.StudentNo,
+		})
+	}
+
+	completionRate := 0.0
+	if len(students) > 0 {
+		completionRate = float64(len(students)-len(unregistered)) / float64(len(students))
+	}
+
+	return map[string]interface{}{
+		"campaign":           campaign,
+		"total_count":        len(students),
+		"registered_count":   len(students) - len(unregistered),
+		"unregistered_count": len(unregistered),
+		"completion_rate":    completionRate,
+		"transport":          transport,
+		"registrations":      registrations,
+		"unregistered":       unregistered,
+	}, nil
+}
+
+// GenerateReturnDings 在返校日前一天为各活动部门批量生成返校签到任务
+func (s *holidayService) GenerateReturnDings() error {
+	due, err := s.holidayRepo.ListDueReturnDepartments(time.Now().Add(24 * time.Hour))
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		cd := due[i]
+		studentIDs, err := s.orgRepo.GetStudentIDsByDepartmentID(cd.DepartmentID)
+		if err != nil {
+			return err
+		}
+		campaign, err := s.holidayRepo.GetCampaignByID(cd.CampaignID)
+		if err != nil || len(studentIDs) == 0 {
+			// 部门没有学生或活动查询失败，标记后不再重复处理
+			cd.Skipped = true
+			if err := s.holidayRepo.UpdateCampaignDepartment(&cd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ding := model.Ding{
+			LauncherID: campaign.CreatorID,
+			Title:      "返校签到",
+			StartTime:  campaign.ReturnDate,
+			EndTime:    campaign.ReturnDate.Add(24 * time.Hour),
+			Latitude:   200, // 与请假返校签到一致的默认值
+			Longitude:  200,
+			Radius:     50,
+			DeptID:     cd.DepartmentID,
+		}
+		created, err := s.holidayRepo.CreateReturnDing(&cd, &ding, studentIDs)
+		if err != nil {
+			log.Printf("生成返校签到失败 campaign=%d dept=%d: %v", cd.CampaignID, cd.DepartmentID, err)
+			continue
+		}
+		if created {
+			s.dingService.NotifyDingCreated(&ding, studentIDs)
+		}
+	}
+	return nil
+}
@@ -1,19 +1,43 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"log"
+	"slices"
 	"time"
 	"unihub/internal/DTO"
 	"unihub/internal/model"
 	"unihub/internal/repo"
+	"unihub/internal/utils"
+
+	"gorm.io/gorm"
 )
 
 type ApplyLeaveRequest struct {
-	StudentID uint
-	Type      string
-	StartTime time.Time
-	EndTime   time.Time
-	Reason    string
+	StudentID         uint
+	Type              string
+	StartTime         time.Time
+	EndTime           time.Time
+	Reason            string
+	Attachments       []string
+	ShareWithTeachers bool
+}
+
+// ClassLeaveView 任课教师可见的学生请假信息，原因和附件仅在学生同意后返回
+type ClassLeaveView struct {
+	LeaveID     uint      `json:"leave_id"`
+	StudentID   uint      `json:"student_id"`
+	StudentName string    `json:"student_name"`
+	StudentNo   *string   `json:"student_no"`
+	ClassIDs    []uint    `json:"class_ids"`
+	ClassNames  []string  `json:"class_names"`
+	Type        string    `json:"type"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+	Attachments []string  `json:"attachments,omitempty"`
 }
 
 type AuditLeaveRequest struct {
@@ -21,39 +45,126 @@ type AuditLeaveRequest struct {
 	RoleID    uint
 	LeaveID   uint
 	Status    string
+	Comment   string
+}
+
+type BatchAuditLeaveRequest struct {
+	AuditorID uint
+	RoleID    uint
+	LeaveIDs  []uint
+	Status    string
+	Comment   string
+}
+
+// BatchAuditResult 批量审批中单条请假的处理结果
+type BatchAuditResult struct {
+	LeaveID uint   `json:"leave_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PendingLeaveQuery 待审批队列的筛选、排序和分页参数
+type PendingLeaveQuery struct {
+	Type         string
+	DepartmentID uint
+	From         *time.Time
+	To           *time.Time
+	MinDays      float64
+	MaxDays      float64
+	SortBy       string
+	Order        string
+	Page         int
+	PageSize     int
+}
+
+type CreateDelegationRequest struct {
+	DelegatorID  uint
+	RoleID       uint
+	DelegateID   uint
+	DepartmentID uint // 0 表示委托全部部门
+	StartTime    time.Time
+	EndTime      time.Time
+	Reason       string
+}
+
+// LeaveReportQuery 请假报表参数
+type LeaveReportQuery struct {
+	UserID       uint
+	RoleID       uint
+	DepartmentID uint
+	From         *time.Time
+	To           *time.Time
+	Period       string // week, month
+	TopN         int
+}
+
+// LeaveReport 请假汇总报表
+type LeaveReport struct {
+	Period       string                     `json:"period"`
+	ByType       []repo.LeaveStatRow        `json:"by_type"`
+	ByDepartment []repo.LeaveStatRow        `json:"by_department"`
+	ByPeriod     []repo.LeaveStatRow        `json:"by_period"`
+	Turnaround   []repo.LeaveTurnaroundRow  `json:"turnaround"`
+	TopStudents  []repo.StudentLeaveDaysRow `json:"top_students"`
 }
 
 type LeaveService interface {
 	Apply(req ApplyLeaveRequest) (*model.LeaveRequest, error)
 	Audit(req AuditLeaveRequest, d DingService) error
-	ListPendingLeaves(counselorID, roleID uint) ([]interface{}, error) // Changed return type
+	Cancel(studentID, leaveID uint, d DingService) error
+	BatchAudit(req BatchAuditLeaveRequest, d DingService) []BatchAuditResult
+	ListPendingLeaves(counselorID, roleID uint, q PendingLeaveQuery) ([]map[string]interface{}, int64, error)
 	MyLeaves(studentID uint) ([]model.LeaveRequest, error)
 	LeaveData(userId uint) (interface{}, interface{})
 	LeaveBackInfo(userId uint) (interface{}, interface{})
+	CreateDelegation(req CreateDelegationRequest) (*model.ApprovalDelegation, error)
+	ListMyDelegations(userID uint) (map[string][]model.ApprovalDelegation, error)
+	RevokeDelegation(userID, delegationID uint) error
+	UpdateShareConsent(studentID, leaveID uint, share bool) error
+	ListClassLeaves(teacherID uint, date time.Time, classID uint) ([]ClassLeaveView, error)
+	LeaveReport(q LeaveReportQuery) (*LeaveReport, error)
+	ExportLeaveReport(q LeaveReportQuery) (string, error)
 }
 
 type leaveService struct {
-	leaveRepo repo.LeaveRepository
-	orgRepo   repo.OrgRepository
-	userRepo  repo.UserRepository
+	leaveRepo      repo.LeaveRepository
+	orgRepo        repo.OrgRepository
+	userRepo       repo.UserRepository
+	delegationRepo repo.DelegationRepository
+	notifRepo      repo.NotificationRepository
+	passService    ExitPassService
+	templates      TemplateService
+	db             *gorm.DB // 数据权限过滤 (DataScopeFilter)
 }
 
-func NewLeaveService(leaveRepo repo.LeaveRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository) LeaveService {
+func NewLeaveService(leaveRepo repo.LeaveRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, delegationRepo repo.DelegationRepository, notifRepo repo.NotificationRepository, passService ExitPassService, templates TemplateService, db *gorm.DB) LeaveService {
 	return &leaveService{
-		leaveRepo: leaveRepo,
-		orgRepo:   orgRepo,
-		userRepo:  userRepo,
+		leaveRepo:      leaveRepo,
+		orgRepo:        orgRepo,
+		userRepo:       userRepo,
+		delegationRepo: delegationRepo,
+		notifRepo:      notifRepo,
+		passService:    passService,
+		templates:      templates,
+		db:             db,
 	}
 }
 
 func (s *leaveService) Apply(req ApplyLeaveRequest) (*model.LeaveRequest, error) {
+	if req.Attachments == nil {
+		req.Attachments = []string{}
+	}
+	attachments, _ := json.Marshal(req.Attachments)
+
 	leave := model.LeaveRequest{
-		StudentID: req.StudentID,
-		Type:      req.Type,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		Reason:    req.Reason,
-		Status:    "pending",
+		StudentID:         req.StudentID,
+		Type:              req.Type,
+		StartTime:         req.StartTime,
+		EndTime:           req.EndTime,
+		Reason:            req.Reason,
+		Attachments:       string(attachments),
+		ShareWithTeachers: req.ShareWithTeachers,
+		Status:            "pending",
 	}
 
 	if err := s.leaveRepo.CreateLeaveRequest(&leave); err != nil {
@@ -63,47 +174,36 @@ func (s *leaveService) Apply(req ApplyLeaveRequest) (*model.LeaveRequest, error)
 }
 
 func (s *leaveService) Audit(req AuditLeaveRequest, dscv DingService) error {
-	if allowed, _ := s.userRepo.CheckPermission(req.RoleID, "leave:approve"); !allowed {
-		return errors.New("无权限审批")
-	}
-
 	leave, err := s.leaveRepo.GetLeaveRequestByID(req.LeaveID)
 	if err != nil {
 		return errors.New("请假记录不存在")
 	}
 
-	// Validation: Is Auditor the student's counselor?
-	studentDeptID, err := s.orgRepo.GetStudentDepartmentID(leave.StudentID)
-	if err != nil || studentDeptID == 0 {
-		return errors.New("学生未加入部门")
+	onBehalfOf, err := s.resolveAuditor(req.AuditorID, req.RoleID, leave.StudentID)
+	if err != nil {
+		return err
 	}
 
-	depts, err := s.orgRepo.ListDepartmentsByCounselorID(req.AuditorID)
-	// Check if studentDeptID is in depts
-	isManaged := false
-	if err == nil {
-		for _, d := range depts {
-			if d.ID == studentDeptID {
-				isManaged = true
-				break
-			}
+	// 已审批的请假不可重复审批，避免重复生成返校签到；
+	// 批准后返校签到或出门凭证未生成成功的，允许再次批准以补齐
+	if leave.Status != "pending" {
+		if leave.Status != "approved" || req.Status != "approved" || s.approvalComplete(leave) {
+			return errors.New("该请假已审批")
 		}
-	}
-
-	if !isManaged {
-		return errors.New("无权审批该学生请假")
-	}
+	} else {
+		now := time.Now()
+		leave.Status = req.Status
+		leave.AuditorID = &req.AuditorID
+		leave.OnBehalfOfID = onBehalfOf
+		leave.AuditComment = req.Comment
+		leave.AuditTime = &now
 
-	now := time.Now()
-	leave.Status = req.Status
-	leave.AuditorID = &req.AuditorID
-	leave.AuditTime = &now
-
-	if err := s.leaveRepo.UpdateLeaveRequest(leave); err != nil {
-		return err
+		if err := s.leaveRepo.UpdateLeaveRequest(leave); err != nil {
+			return err
+		}
 	}
 
-	if req.Status == "approved" {
+	if req.Status == "approved" && leave.DingId == 0 {
 		dingEntity := DTO.CreateDingRequest{
 			StudentId: leave.StudentID,
 			Title:     "返校签到",
@@ -124,44 +224,169 @@ func (s *leaveService) Audit(req AuditLeaveRequest, dscv DingService) error {
 			return err
 		}
 	}
+	if req.Status == "approved" {
+		// 签发电子出门凭证
+		if _, err := s.passService.Issue(leave); err != nil {
+			return err
+		}
+	}
+
+	s.notifyDecision(leave)
 	return nil
 }
 
-func (s *leaveService) ListPendingLeaves(counselorID, roleID uint) ([]interface{}, error) {
-	if allowed, _ := s.userRepo.CheckPermission(roleID, "leave:approve"); !allowed {
-		return nil, errors.New("无权限查看待审批请假")
+// approvalComplete 已批准的请假是否已生成返校签到并签发出门凭证
+func (s *leaveService) approvalComplete(leave *model.LeaveRequest) bool {
+	if leave.DingId == 0 {
+		return false
 	}
+	pass, err := s.passService.GetMyPass(leave.StudentID, leave.ID)
+	return err == nil && pass.Payload != ""
+}
 
-	// Find all students in counselor's departments
-	depts, err := s.orgRepo.ListDepartmentsByCounselorID(counselorID)
-	if err != nil {
-		return nil, err
+// notifyDecision 通知学生审批结果，失败只记录日志
+func (s *leaveService) notifyDecision(leave *model.LeaveRequest) {
+	key := "leave_approved"
+	if leave.Status == "rejected" {
+		key = "leave_rejected"
+	}
+	msg := s.templates.RenderForUser(key, leave.StudentID, map[string]string{
+		"leave_type": leave.Type,
+		"start_time": leave.StartTime.Format("01-02 15:04"),
+		"end_time":   leave.EndTime.Format("01-02 15:04"),
+	})
+	if leave.AuditComment != "" {
+		msg.Content += "审批意见：" + leave.AuditComment
 	}
 
-	var allStudentIDs []uint
-	for _, d := range depts {
-		ids, err := s.orgRepo.GetStudentIDsByDepartmentID(d.ID)
-		if err == nil {
-			allStudentIDs = append(allStudentIDs, ids...)
+	notif := model.Notification{
+		Title:      msg.Title,
+		Content:    msg.Content,
+		SenderID:   *leave.AuditorID,
+		TargetType: "student",
+		TargetID:   leave.StudentID,
+		Category:   msg.Category,
+		RefType:    "leave",
+		RefID:      leave.ID,
+	}
+	if err := s.notifRepo.CreateNotificationWithOutbox(&notif); err != nil {
+		log.Printf("leave: failed to notify student %d of leave %d decision: %v", leave.StudentID, leave.ID, err)
+	}
+}
+
+// Cancel 学生取消尚未结束的请假，已批准的请假同时吊销出门凭证并取消返校签到
+func (s *leaveService) Cancel(studentID, leaveID uint, dscv DingService) error {
+	leave, err := s.leaveRepo.GetLeaveRequestByID(leaveID)
+	if err != nil || leave.StudentID != studentID {
+		return errors.New("请假记录不存在")
+	}
+	if leave.Status != "pending" && leave.Status != "approved" {
+		return errors.New("当前状态不可取消")
+	}
+	if leave.EndTime.Before(time.Now()) {
+		return errors.New("请假已结束，不可取消")
+	}
+
+	wasApproved := leave.Status == "approved"
+	leave.Status = "cancelled"
+	if err := s.leaveRepo.UpdateLeaveRequest(leave); err != nil {
+		return err
+	}
+
+	if wasApproved {
+		if err := s.passService.Revoke(leave.ID); err != nil {
+			return err
+		}
+		if leave.DingId != 0 {
+			if err := dscv.CancelStudentDing(leave.DingId, leave.StudentID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BatchAudit 批量审批，逐条执行与单条审批相同的权限校验和返校签到逻辑
+func (s *leaveService) BatchAudit(req BatchAuditLeaveRequest, dscv DingService) []BatchAuditResult {
+	results := make([]BatchAuditResult, 0, len(req.LeaveIDs))
+	seen := make(map[uint]bool)
+	for _, id := range req.LeaveIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		err := s.Audit(AuditLeaveRequest{
+			AuditorID: req.AuditorID,
+			RoleID:    req.RoleID,
+			LeaveID:   id,
+			Status:    req.Status,
+			Comment:   req.Comment,
+		}, dscv)
+		result := BatchAuditResult{LeaveID: id, Success: err == nil}
+		if err != nil {
+			result.Error = err.Error()
 		}
+		results = append(results, result)
 	}
+	return results
+}
 
-	if len(allStudentIDs) == 0 {
-		return []interface{}{}, nil
+func (s *leaveService) ListPendingLeaves(counselorID, roleID uint, q PendingLeaveQuery) ([]map[string]interface{}, int64, error) {
+	allowed, _ := s.userRepo.CheckPermission(roleID, "leave:approve")
+	delegations, _ := s.delegationRepo.ListActiveDelegationsByDelegateID(counselorID, time.Now())
+	if !allowed && len(delegations) == 0 {
+		return nil, 0, errors.New("无权限查看待审批请假")
+	}
+	if q.MaxDays > 0 && q.MinDays > q.MaxDays {
+		return nil, 0, errors.New("最短天数不能大于最长天数")
 	}
 
-	// Use the Repo method that joins with Users table to get names
-	result, errInt := s.leaveRepo.ListLeavesWithStudentsByStudentsAndStatus(allStudentIDs, "pending")
+	// Find all departments the user may audit (own or delegated)
+	deptOwners, err := s.auditableDepartments(counselorID, allowed, delegations)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// Handle the unusual interface{} error return from repo
-	if errInt != nil {
-		if e, ok := errInt.(error); ok {
-			return nil, e
+	var deptIDs []uint
+	if q.DepartmentID != 0 {
+		if _, ok := deptOwners[q.DepartmentID]; !ok {
+			return nil, 0, errors.New("无权限查看该部门请假")
+		}
+		deptIDs = []uint{q.DepartmentID}
+	} else {
+		for id := range deptOwners {
+			deptIDs = append(deptIDs, id)
 		}
-		return nil, errors.New("unknown error fetching leaves")
 	}
 
-	return result, nil
+	result, total, err := s.leaveRepo.QueryLeavesWithStudents(repo.LeaveQueryFilter{
+		DepartmentIDs: deptIDs,
+		Status:        "pending",
+		Type:          q.Type,
+		From:          q.From,
+		To:            q.To,
+		MinMinutes:    int(q.MinDays * 24 * 60),
+		MaxMinutes:    int(q.MaxDays * 24 * 60),
+		SortBy:        q.SortBy,
+		Order:         q.Order,
+		Page:          q.Page,
+		PageSize:      q.PageSize,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 标记委托审批的请假，便于前端展示 "代 XX 审批"
+	for _, row := range result {
+		if deptID, ok := toUint(row["department_id"]); ok {
+			if owner := deptOwners[deptID]; owner != counselorID {
+				row["on_behalf_of"] = owner
+			}
+		}
+	}
+
+	return result, total, nil
 }
 
 func (s *leaveService) MyLeaves(studentID uint) ([]model.LeaveRequest, error) {
@@ -207,3 +432,373 @@ func (s *leaveService) LeaveBackInfo(userId uint) (interface{}, interface{}) {
 	result["leaving"] = leaving
 	return result, nil
 }
+
+// resolveAuditor 校验审批人对学生请假的审批权。
+// 学生所在部门的辅导员需具备 leave:approve 权限；其他人需持有该辅导员的有效委托，此时返回被代理的辅导员ID。
+func (s *leaveService) resolveAuditor(auditorID, roleID, studentID uint) (*uint, error) {
+	studentDeptID, err := s.orgRepo.GetStudentDepartmentID(studentID)
+	if err != nil || studentDeptID == 0 {
+		return nil, errors.New("学生未加入部门")
+	}
+	dept, err := s.orgRepo.GetDepartmentByID(studentDeptID)
+	if err != nil {
+		return nil, errors.New("学生未加入部门")
+	}
+
+	if dept.CounselorID == auditorID {
+		if allowed, _ := s.userRepo.CheckPermission(roleID, "leave:approve"); !allowed {
+			return nil, errors.New("无权限审批")
+		}
+		return nil, nil
+	}
+
+	if _, err := s.delegationRepo.FindActiveDelegation(dept.CounselorID, auditorID, dept.ID, time.Now()); err != nil {
+		return nil, errors.New("无权审批该学生请假")
+	}
+	counselorID := dept.CounselorID
+	return &counselorID, nil
+}
+
+// auditableDepartments 返回用户可审批的部门ID -> 部门辅导员ID，包括自己名下的部门和被委托的部门
+func (s *leaveService) auditableDepartments(userID uint, includeOwn bool, delegations []model.ApprovalDelegation) (map[uint]uint, error) {
+	result := make(map[uint]uint)
+	if includeOwn {
+		depts, err := s.orgRepo.ListDepartmentsByCounselorID(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range depts {
+			result[d.ID] = userID
+		}
+	}
+
+	for _, dg := range delegations {
+		if dg.DepartmentID != 0 {
+			if _, ok := result[dg.DepartmentID]; !ok {
+				result[dg.DepartmentID] = dg.DelegatorID
+			}
+			continue
+		}
+		depts, err := s.orgRepo.ListDepartmentsByCounselorID(dg.DelegatorID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range depts {
+			if _, ok := result[d.ID]; !ok {
+				result[d.ID] = dg.DelegatorID
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *leaveService) CreateDelegation(req CreateDelegationRequest) (*model.ApprovalDelegation, error) {
+	if allowed, _ := s.userRepo.CheckPermission(req.RoleID, "leave:approve"); !allowed {
+		return nil, errors.New("无权限委托审批")
+	}
+	if req.DelegateID == req.DelegatorID {
+		return nil, errors.New("不能委托给自己")
+	}
+	if !req.EndTime.After(req.StartTime) || req.EndTime.Before(time.Now()) {
+		return nil, errors.New("委托时间范围无效")
+	}
+
+	delegate, err := s.userRepo.GetUserByIDWithRole(req.DelegateID)
+	if err != nil {
+		return nil, errors.New("受托人不存在")
+	}
+	if delegate.Role.Key == "student" {
+		return nil, errors.New("只能委托给教职工")
+	}
+
+	if req.DepartmentID != 0 {
+		dept, err := s.orgRepo.GetDepartmentByID(req.DepartmentID)
+		if err != nil || dept.CounselorID != req.DelegatorID {
+			return nil, errors.New("只能委托自己管理的部门")
+		}
+	}
+
+	delegation := model.ApprovalDelegation{
+		DelegatorID:  req.DelegatorID,
+		DelegateID:   req.DelegateID,
+		DepartmentID: req.DepartmentID,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		Reason:       req.Reason,
+	}
+	if err := s.delegationRepo.CreateDelegation(&delegation); err != nil {
+		return nil, err
+	}
+	return &delegation, nil
+}
+
+// ListMyDelegations 返回我发出的委托和委托给我的审批
+func (s *leaveService) ListMyDelegations(userID uint) (map[string][]model.ApprovalDelegation, error) {
+	given, err := s.delegationRepo.ListDelegationsByDelegatorID(userID)
+	if err != nil {
+		return nil, err
+	}
+	received, err := s.delegationRepo.ListDelegationsByDelegateID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]model.ApprovalDelegation{
+		"given":    given,
+		"received": received,
+	}, nil
+}
+
+func (s *leaveService) RevokeDelegation(userID, delegationID uint) error {
+	delegation, err := s.delegationRepo.GetDelegationByID(delegationID)
+	if err != nil {
+		return errors.New("委托记录不存在")
+	}
+	if delegation.DelegatorID != userID {
+		return errors.New("无权撤销该委托")
+	}
+	if delegation.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	delegation.RevokedAt = &now
+	return s.delegationRepo.UpdateDelegation(delegation)
+}
+
+// toUint 将 Scan 到 map 中的数值列转换为 uint
+func toUint(v interface{}) (uint, bool) {
+	switch n := v.(type) {
+	case int64:
+		return uint(n), true
+	case uint64:
+		return uint(n), true
+	case int32:
+		return uint(n), true
+	case uint32:
+		return uint(n), true
+	case int:
+		return uint(n), true
+	case uint:
+		return n, true
+	}
+	return 0, false
+}
+
+// UpdateShareConsent 学生设置是否允许任课教师查看请假原因和附件
+func (s *leaveService) UpdateShareConsent(studentID, leaveID uint, share bool) error {
+	leave, err := s.leaveRepo.GetLeaveRequestByID(leaveID)
+	if err != nil || leave.StudentID != studentID {
+		return errors.New("请假记录不存在")
+	}
+	leave.ShareWithTeachers = share
+	return s.leaveRepo.UpdateLeaveRequest(leave)
+}
+
+// ListClassLeaves 任课教师查看自己班级学生在指定日期的已批准请假 (只读)
+func (s *leaveService) ListClassLeaves(teacherID uint, date time.Time, classID uint) ([]ClassLeaveView, error) {
+	classes, err := s.orgRepo.ListClassesByTeacherID(teacherID)
+	if err != nil {
+		return nil, err
+	}
+
+	studentClasses := make(map[uint][]model.Class)
+	var studentIDs []uint
+	found := classID == 0
+	for _, c := range classes {
+		if classID != 0 && c.ID != classID {
+			continue
+		}
+		found = true
+		ids, err := s.orgRepo.GetStudentIDsByClassID(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if _, ok := studentClasses[id]; !ok {
+				studentIDs = append(studentIDs, id)
+			}
+			studentClasses[id] = append(studentClasses[id], c)
+		}
+	}
+	if !found {
+		return nil, errors.New("无权查看该班级")
+	}
+
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	leaves, err := s.leaveRepo.ListLeavesOverlapping(studentIDs, []string{"approved", "active"}, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ClassLeaveView, 0, len(leaves))
+	for _, l := range leaves {
+		view := ClassLeaveView{
+			LeaveID:     l.ID,
+			StudentID:   l.StudentID,
+			StudentName: l.StudentName,
+			StudentNo:   l.StudentNo,
+			Type:        l.Type,
+			StartTime:   l.StartTime,
+			EndTime:     l.EndTime,
+			Status:      l.Status,
+		}
+		for _, c := range studentClasses[l.StudentID] {
+			view.ClassIDs = append(view.ClassIDs, c.ID)
+			view.ClassNames = append(view.ClassNames, c.Name)
+		}
+		if l.ShareWithTeachers {
+			view.Reason = l.Reason
+			_ = json.Unmarshal([]byte(l.Attachments), &view.Attachments)
+		}
+		result = append(result, view)
+	}
+	return result, nil
+}
+
+// reportDepartments 按角色确定报表可见的部门：管理员按角色的数据范围 (全校时返回 nil)，辅导员仅限自己的部门
+func (s *leaveService) reportDepartments(userID, roleID, deptID uint) ([]uint, error) {
+	if allowed, _ := s.userRepo.CheckPermission(roleID, "leave:report"); !allowed {
+		return nil, errors.New("无权限查看请假报表")
+	}
+	user, err := s.userRepo.GetUserByIDWithRole(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role.Key == "admin" || user.Role.Key == "super_admin" {
+		scope, err := s.adminDepartments(user)
+		if err != nil {
+			return nil, err
+		}
+		if deptID == 0 {
+			return scope, nil
+		}
+		if scope != nil && !slices.Contains(scope, deptID) {
+			return nil, errors.New("无权限查看该部门请假")
+		}
+		return []uint{deptID}, nil
+	}
+
+	depts, err := s.orgRepo.ListDepartmentsByCounselorID(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint{}
+	for _, d := range depts {
+		if deptID == 0 || d.ID == deptID {
+			ids = append(ids, d.ID)
+		}
+	}
+	if deptID != 0 && len(ids) == 0 {
+		return nil, errors.New("无权限查看该部门请假")
+	}
+	return ids, nil
+}
+
+// adminDepartments 管理员数据范围内的部门：所在部门及挂在其组织架构节点 (按数据范围含下级) 下的部门，nil 表示全部
+func (s *leaveService) adminDepartments(admin *model.User) ([]uint, error) {
+	var unitID *uint
+	if admin.DepartmentID != 0 {
+		if dept, err := s.orgRepo.GetDepartmentByID(admin.DepartmentID); err == nil {
+			unitID = dept.OrgUnitID
+		}
+	}
+	unitIDs, err := DataScopeFilter(s.db, admin.Role, unitID)
+	if err != nil || unitIDs == nil {
+		return nil, err
+	}
+
+	ids, err := s.orgRepo.ListDepartmentIDsByOrgUnitIDs(unitIDs)
+	if err != nil {
+		return nil, err
+	}
+	if admin.DepartmentID != 0 && !slices.Contains(ids, admin.DepartmentID) {
+		ids = append(ids, admin.DepartmentID)
+	}
+	return ids, nil
+}
+
+func (s *leaveService) LeaveReport(q LeaveReportQuery) (*LeaveReport, error) {
+	deptIDs, err := s.reportDepartments(q.UserID, q.RoleID, q.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	if q.Period != "week" {
+		q.Period = "month"
+	}
+	if q.TopN <= 0 {
+		q.TopN = 10
+	}
+
+	f := repo.LeaveReportFilter{DepartmentIDs: deptIDs, From: q.From, To: q.To}
+	report := &LeaveReport{Period: q.Period}
+	if report.ByType, err = s.leaveRepo.LeaveStatsBy("type", f); err != nil {
+		return nil, err
+	}
+	if report.ByDepartment, err = s.leaveRepo.LeaveStatsBy("department", f); err != nil {
+		return nil, err
+	}
+	if report.ByPeriod, err = s.leaveRepo.LeaveStatsBy(q.Period, f); err != nil {
+		return nil, err
+	}
+	if report.Turnaround, err = s.leaveRepo.LeaveTurnaroundByDepartment(f); err != nil {
+		return nil, err
+	}
+	if report.TopStudents, err = s.leaveRepo.TopStudentsByLeaveDays(f, q.TopN); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ExportLeaveReport 导出请假报表，每个统计维度一个工作表
+func (s *leaveService) ExportLeaveReport(q LeaveReportQuery) (string, error) {
+	report, err := s.LeaveReport(q)
+	if err != nil {
+		return "", err
+	}
+
+	statSheet := func(name, keyHeader string, rows []repo.LeaveStatRow) utils.Sheet {
+		sheet := utils.Sheet{
+			Name:    name,
+			Headers: []string{keyHeader, "请假次数", "批准次数", "驳回次数", "批准天数"},
+		}
+		for _, r := range rows {
+			sheet.Rows = append(sheet.Rows, []interface{}{r.Key, r.Count, r.ApprovedCount, r.RejectedCount, r.TotalDays})
+		}
+		return sheet
+	}
+
+	periodName := "按月统计"
+	periodHeader := "月份"
+	if report.Period == "week" {
+		periodName = "按周统计"
+		periodHeader = "周"
+	}
+
+	turnaround := utils.Sheet{
+		Name:    "审批时效",
+		Headers: []string{"部门ID", "部门", "已审批数", "平均耗时(小时)", "最长耗时(小时)"},
+	}
+	for _, r := range report.Turnaround {
+		turnaround.Rows = append(turnaround.Rows, []interface{}{r.DepartmentID, r.DepartmentName, r.AuditedCount, r.AvgHours, r.MaxHours})
+	}
+
+	top := utils.Sheet{
+		Name:    "请假天数排行",
+		Headers: []string{"排名", "学生ID", "姓名", "学号", "请假次数", "请假天数"},
+	}
+	for i, r := range report.TopStudents {
+		studentNo := ""
+		if r.StudentNo != nil {
+			studentNo = *r.StudentNo
+		}
+		top.Rows = append(top.Rows, []interface{}{i + 1, r.StudentID, r.StudentName, studentNo, r.LeaveCount, r.TotalDays})
+	}
+
+	return utils.ExportSheetsToExcel([]utils.Sheet{
+		statSheet("按类型统计", "类型", report.ByType),
+		statSheet("按部门统计", "部门", report.ByDepartment),
+		statSheet(periodName, periodHeader, report.ByPeriod),
+		turnaround,
+		top,
+	}, "leave_report")
+}
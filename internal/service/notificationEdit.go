@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unihub/internal/model"
+)
+
+// maxAttachments 每条通知最多的附件数
+const maxAttachments = 9
+
+// NotificationAttachment 通知附件，URL 为已上传到对象存储的地址
+type NotificationAttachment struct {
+	Type string `json:"type"` // image, file
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// EditNotifRequest 修改已发布通知的内容
+type EditNotifRequest struct {
+	Title       string
+	Content     string
+	Format      string
+	Attachments []NotificationAttachment
+}
+
+func validateContent(format string, attachments []NotificationAttachment) error {
+	if format != "" && format != "text" && format != "markdown" {
+		return errors.New("内容格式只能是 text 或 markdown")
+	}
+	if len(attachments) > maxAttachments {
+		return fmt.Errorf("附件最多 %d 个", maxAttachments)
+	}
+	for _, a := range attachments {
+		if a.Type != "image" && a.Type != "file" {
+			return errors.New("附件类型只能是 image 或 file")
+		}
+		if !strings.HasPrefix(a.URL, "https://") && !strings.HasPrefix(a.URL, "http://") {
+			return errors.New("附件地址无效")
+		}
+	}
+	return nil
+}
+
+func encodeAttachments(attachments []NotificationAttachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(attachments)
+	return string(data)
+}
+
+func decodeAttachments(raw string) []NotificationAttachment {
+	attachments := []NotificationAttachment{}
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &attachments)
+	}
+	return attachments
+}
+
+// sentNotification 发送者自己已发布的通知
+func (s *notificationService) sentNotification(userID, notifID uint) (*model.Notification, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, errors.New("通知不存在")
+	}
+	if notif.SenderID != userID {
+		return nil, errors.New("无权修改该通知")
+	}
+	if notif.Status != "published" {
+		return nil, errors.New("通知未发布或已撤回")
+	}
+	return notif, nil
+}
+
+// Edit 修改已发布的通知，旧内容保存为历史版本，接收人看到“已编辑”标记。修改不会重新推送。
+func (s *notificationService) Edit(userID, notifID uint, req EditNotifRequest) (*model.Notification, error) {
+	notif, err := s.sentNotification(userID, notifID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Title == "" || req.Content == "" {
+		return nil, errors.New("标题和内容不能为空")
+	}
+	if err := validateContent(req.Format, req.Attachments); err != nil {
+		return nil, err
+	}
+
+	prev := &model.NotificationVersion{
+		NotificationID: notif.ID,
+		Version:        notif.Version,
+		Title:          notif.Title,
+		Content:        notif.Content,
+		Format:         notif.Format,
+		Attachments:    notif.Attachments,
+		EditorID:       userID,
+	}
+	now := time.Now()
+	notif.Title = req.Title
+	notif.Content = req.Content
+	notif.Format = req.Format
+	if notif.Format == "" {
+		notif.Format = "text"
+	}
+	notif.Attachments = encodeAttachments(req.Attachments)
+	notif.EditedAt = &now
+
+	edited, err := s.notifRepo.EditNotification(notif, prev)
+	if err != nil {
+		return nil, err
+	}
+	if !edited {
+		return nil, errors.New("通知已被修改或撤回，请刷新后重试")
+	}
+	return notif, nil
+}
+
+// ListVersions 发送者查看通知的历史版本 (不含当前版本)
+func (s *notificationService) ListVersions(userID, notifID uint) ([]model.NotificationVersion, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, errors.New("通知不存在")
+	}
+	if notif.SenderID != userID {
+		return nil, errors.New("无权查看该通知")
+	}
+	return s.notifRepo.ListNotificationVersions(notifID)
+}
+
+// Recall 在撤回时限内撤回通知：接收人收件箱中不再显示，已收到推送的设备会收到撤回推送
+func (s *notificationService) Recall(userID, notifID uint) error {
+	notif, err := s.sentNotification(userID, notifID)
+	if err != nil {
+		return err
+	}
+	if s.recallWindow > 0 && time.Since(notif.CreatedAt) > s.recallWindow {
+		return errors.New("已超过撤回时限")
+	}
+	recalled, err := s.notifRepo.RecallNotification(notif.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !recalled {
+		return errors.New("通知未发布或已撤回")
+	}
+	return nil
+}
+
+// deliverRecall 向已收到推送的用户发送撤回推送，不受免打扰限制
+func (s *notificationService) deliverRecall(notif model.Notification) error {
+	userIDs, err := s.pushSvc.RecallRecipients(notif)
+	if err != nil || len(userIDs) == 0 {
+		return err
+	}
+	recall := notif
+	recall.Title = "通知已撤回"
+	recall.Content = "「" + notif.Title + "」已被发送者撤回"
+	recall.Format = "text"
+	failed, err := s.pushSvc.Deliver(recall, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("push: %d 个接收人撤回推送失败", len(failed))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // 容器镜像可能没有系统时区数据
+	"unihub/internal/model"
+)
+
+// defaultTimezone 用户未设置时区时使用
+const defaultTimezone = "Asia/Shanghai"
+
+// notificationCategories 可设置偏好的消息分类
+var notificationCategories = []string{"announcement", "ding", "leave", "task", "system"}
+
+// CategoryPreference 某一类消息的接收渠道设置
+type CategoryPreference struct {
+	Category string `json:"category"`
+	Push     bool   `json:"push"`
+	Email    bool   `json:"email"`
+	InApp    bool   `json:"in_app"`
+}
+
+// QuietHours 免打扰时段，Start/End 为 Timezone 时区的 "HH:MM"，Start 晚于 End 表示跨零点
+type QuietHours struct {
+	Enabled  bool   `json:"enabled"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+// NotificationPreferences 用户的消息接收偏好
+type NotificationPreferences struct {
+	Categories []CategoryPreference `json:"categories"`
+	QuietHours QuietHours           `json:"quiet_hours"`
+	// Locale 系统消息使用的语言
+	Locale string `json:"locale"`
+}
+
+// GetPreferences 用户各类消息的接收渠道及免打扰设置，未设置的分类默认推送和站内可见、不发邮件
+func (s *notificationService) GetPreferences(userID uint) (*NotificationPreferences, error) {
+	saved, err := s.prefRepo.ListPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	byCategory := make(map[string]model.NotificationPreference, len(saved))
+	for _, p := range saved {
+		byCategory[p.Category] = p
+	}
+
+	prefs := &NotificationPreferences{
+		Categories: make([]CategoryPreference, len(notificationCategories)),
+		QuietHours: QuietHours{Start: "22:00", End: "07:00", Timezone: defaultTimezone},
+		Locale:     defaultLocale,
+	}
+	for i, c := range notificationCategories {
+		p, ok := byCategory[c]
+		if !ok {
+			p = model.NotificationPreference{Push: true, InApp: true}
+		}
+		prefs.Categories[i] = CategoryPreference{Category: c, Push: p.Push, Email: p.Email, InApp: p.InApp}
+	}
+
+	if setting, err := s.prefRepo.GetSetting(userID); err == nil {
+		prefs.QuietHours = QuietHours{
+			Enabled:  setting.QuietEnabled,
+			Start:    setting.QuietStart,
+			End:      setting.QuietEnd,
+			Timezone: setting.Timezone,
+		}
+		if prefs.QuietHours.Timezone == "" {
+			prefs.QuietHours.Timezone = defaultTimezone
+		}
+		if setting.Locale != "" {
+			prefs.Locale = setting.Locale
+		}
+	}
+	return prefs, nil
+}
+
+func (s *notificationService) UpdatePreferences(userID uint, prefs NotificationPreferences) error {
+	valid := make(map[string]bool, len(notificationCategories))
+	for _, c := range notificationCategories {
+		valid[c] = true
+	}
+
+	rows := make([]model.NotificationPreference, 0, len(prefs.Categories))
+	for _, p := range prefs.Categories {
+		if !valid[p.Category] {
+			return errors.New("未知的消息分类：" + p.Category)
+		}
+		rows = append(rows, model.NotificationPreference{
+			UserID:   userID,
+			Category: p.Category,
+			Push:     p.Push,
+			Email:    p.Email,
+			InApp:    p.InApp,
+		})
+	}
+
+	q := prefs.QuietHours
+	if q.Timezone == "" {
+		q.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return errors.New("无效的时区：" + q.Timezone)
+	}
+	if prefs.Locale == "" {
+		// 未提交语言时保留原设置
+		prefs.Locale = defaultLocale
+		if setting, err := s.prefRepo.GetSetting(userID); err == nil && setting.Locale != "" {
+			prefs.Locale = setting.Locale
+		}
+	}
+	if !supportedLocales[prefs.Locale] {
+		return errors.New("不支持的语言：" + prefs.Locale)
+	}
+	if q.Enabled {
+		start, err1 := parseClock(q.Start)
+		end, err2 := parseClock(q.End)
+		if err1 != nil || err2 != nil {
+			return errors.New("免打扰时间格式应为 HH:MM")
+		}
+		if start == end {
+			return errors.New("免打扰开始和结束时间不能相同")
+		}
+	}
+
+	if err := s.prefRepo.SavePreferences(rows); err != nil {
+		return err
+	}
+	return s.prefRepo.SaveSetting(&model.NotificationSetting{
+		UserID:       userID,
+		QuietEnabled: q.Enabled,
+		QuietStart:   q.Start,
+		QuietEnd:     q.End,
+		Timezone:     q.Timezone,
+		Locale:       prefs.Locale,
+		UpdatedAt:    time.Now(),
+	})
+}
+
+// mutedCategories 用户关闭了站内显示的分类，查询失败时不过滤
+func (s *notificationService) mutedCategories(userID uint) []string {
+	muted, err := s.prefRepo.ListInAppMutedCategories(userID)
+	if err != nil {
+		log.Printf("preferences: list muted categories of user %d: %v", userID, err)
+		return nil
+	}
+	return muted
+}
+
+// splitQuiet 去掉当前处于免打扰时段的用户，并返回其中最早结束的时间
+func (s *notificationService) splitQuiet(userIDs []uint, now time.Time) ([]uint, *time.Time) {
+	settings, err := s.prefRepo.ListQuietSettings(userIDs)
+	if err != nil {
+		log.Printf("preferences: list quiet settings: %v", err)
+		return userIDs, nil
+	}
+	quiet := make(map[uint]bool, len(settings))
+	var earliest *time.Time
+	for i := range settings {
+		until, ok := quietUntil(&settings[i], now)
+		if !ok {
+			continue
+		}
+		quiet[settings[i].UserID] = true
+		if earliest == nil || until.Before(*earliest) {
+			earliest = &until
+		}
+	}
+	if len(quiet) == 0 {
+		return userIDs, nil
+	}
+
+	active := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !quiet[id] {
+			active = append(active, id)
+		}
+	}
+	return active, earliest
+}
+
+// quietUntil 判断 now 是否处于用户的免打扰时段，是则返回时段结束时间
+func quietUntil(setting *model.NotificationSetting, now time.Time) (time.Time, bool) {
+	if !setting.QuietEnabled {
+		return time.Time{}, false
+	}
+	start, err1 := parseClock(setting.QuietStart)
+	end, err2 := parseClock(setting.QuietEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return time.Time{}, false
+	}
+	tz := setting.Timezone
+	if tz == "" {
+		tz = defaultTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	switch {
+	case start < end && minute >= start && minute < end:
+		return endToday, true
+	case start > end && minute >= start:
+		return endToday.AddDate(0, 0, 1), true
+	case start > end && minute < end:
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+// parseClock 将 "HH:MM" 转换为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -2,107 +2,1017 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unihub/internal/config"
 	"unihub/internal/model"
 	"unihub/internal/repo"
 	"unihub/internal/utils"
-
-	"gorm.io/gorm"
 )
 
+// outboxBatchSize 每轮最多处理的投递任务数
+const outboxBatchSize = 50
+
+// outboxLease 任务领取后的租约，超时未完成视为投递进程退出，可被重新领取
+const outboxLease = 5 * time.Minute
+
 type CreateNotifRequest struct {
 	Title      string
 	Content    string
 	TargetType string
 	TargetID   uint
 	SenderID   uint
+	RoleID     uint
+	RequireAck bool
+	// Urgent 紧急通知，不受接收人免打扰时段限制，需要 notification:urgent 权限
+	Urgent bool
+	// Draft 保存为草稿，学生不可见
+	Draft bool
+	// ScheduledAt 定时发送时间；Recurrence 为 daily/weekly/monthly 时按周期重复直到 RecurrenceUntil
+	ScheduledAt     *time.Time
+	Recurrence      string
+	RecurrenceUntil *time.Time
+	// TemplateKey 使用模板生成标题和内容，Variables 替换模板中的占位符；
+	// {{student_name}}、{{student_no}} 未指定时保留，投递和展示时按接收人替换
+	TemplateKey string
+	Locale      string
+	Variables   map[string]string
+	// Format 为 text 或 markdown；Attachments 为图片和文件附件
+	Format      string
+	Attachments []NotificationAttachment
+	// Audience 多个通知对象 (dept, class, student, role, school)，为空时使用 TargetType/TargetID
+	Audience []model.Target
 }
 
 type NotificationService interface {
-	Create(req CreateNotifRequest) error
-	GetMyNotifications(studentID uint) ([]model.Notification, error)
+	Create(req CreateNotifRequest) (*model.Notification, error)
+	ListSchedules(userID uint) ([]model.Notification, error)
+	UpdateSchedule(userID, notifID uint, req CreateNotifRequest) (*model.Notification, error)
+	PublishDraft(userID, notifID uint) (*model.Notification, error)
+	CancelSchedule(userID, notifID uint) error
+	DispatchScheduled() error
+	GetMyNotifications(studentID uint) ([]NotificationView, error)
+	MarkRead(userID, notifID uint) error
+	MarkAllRead(userID uint) error
+	UnreadCount(userID uint) (int64, error)
+	GetReadStats(userID, notifID uint) (*ReadStats, error)
+	Acknowledge(userID, notifID uint) error
+	GetAckStats(userID, notifID uint) (*AckStats, error)
+	ExportAckList(userID, notifID uint) (string, error)
+	SendAckReminders() error
+	Inbox(userID uint, q InboxQuery) (*InboxPage, error)
+	GetPreferences(userID uint) (*NotificationPreferences, error)
+	UpdatePreferences(userID uint, prefs NotificationPreferences) error
+	DeliverOutbox() error
+	GetDeliveryStatus(userID, notifID uint) (*DeliveryStatus, error)
+	Edit(userID, notifID uint, req EditNotifRequest) (*model.Notification, error)
+	ListVersions(userID, notifID uint) ([]model.NotificationVersion, error)
+	Recall(userID, notifID uint) error
+}
+
+// NotificationView 接收人视角的通知，附带阅读状态
+type NotificationView struct {
+	model.Notification
+	// Attachments 覆盖 model.Notification 中的 JSON 字符串
+	Attachments []NotificationAttachment
+	Edited      bool       `json:"edited"`
+	Read        bool       `json:"read"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	Acked       bool       `json:"acked"`
+	AckedAt     *time.Time `json:"acked_at,omitempty"`
+}
+
+// InboxQuery 收件箱查询
+type InboxQuery struct {
+	Category string
+	Keyword  string
+	Cursor   uint
+	Limit    int
+}
+
+// InboxPage 收件箱的一页，NextCursor 为 0 表示没有更多
+type InboxPage struct {
+	Items      []NotificationView `json:"items"`
+	NextCursor uint               `json:"next_cursor"`
+}
+
+// ReadStats 通知的阅读统计
+type ReadStats struct {
+	Total       int                  `json:"total"`
+	Read        int                  `json:"read"`
+	Unread      int                  `json:"unread"`
+	UnreadUsers []repo.RecipientRead `json:"unread_users"`
+	ReadUsers   []repo.RecipientRead `json:"read_users"`
+}
+
+// AckStats 需确认通知的确认情况
+type AckStats struct {
+	Total        int                  `json:"total"`
+	Acked        int                  `json:"acked"`
+	Pending      int                  `json:"pending"`
+	AckReminders int                  `json:"ack_reminders"`
+	AckedUsers   []repo.RecipientRead `json:"acked_users"`
+	PendingUsers []repo.RecipientRead `json:"pending_users"`
+}
+
+// DeliveryStatus 通知的投递任务状态及每个接收人的推送结果
+type DeliveryStatus struct {
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+	LastError     string                   `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time               `json:"delivered_at,omitempty"`
+	Recipients    []repo.RecipientDelivery `json:"recipients"`
 }
 
 type notificationService struct {
 	notifRepo repo.NotificationRepository
 	orgRepo   repo.OrgRepository
 	userRepo  repo.UserRepository
-	db        *gorm.DB
+	pushSvc   PushService
+	prefRepo  repo.PreferenceRepository
+	channels  []DeliveryChannel
+	templates TemplateService
+
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	ackRemindEvery  time.Duration
+	ackMaxReminders int
+
+	recallWindow time.Duration
 }
 
-func NewNotificationService(notifRepo repo.NotificationRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, db *gorm.DB) NotificationService {
+func NewNotificationService(notifRepo repo.NotificationRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, pushSvc PushService, prefRepo repo.PreferenceRepository, channels []DeliveryChannel, templates TemplateService, cfg *config.Config) NotificationService {
+	outbox := cfg.Notification.Outbox
 	return &notificationService{
 		notifRepo: notifRepo,
 		orgRepo:   orgRepo,
 		userRepo:  userRepo,
-		db:        db,
+		pushSvc:   pushSvc,
+		prefRepo:  prefRepo,
+		channels:  channels,
+		templates: templates,
+
+		maxAttempts: outbox.MaxAttempts,
+		baseBackoff: outbox.BaseBackoff,
+		maxBackoff:  outbox.MaxBackoff,
+
+		ackRemindEvery:  cfg.Notification.Ack.RemindEvery,
+		ackMaxReminders: cfg.Notification.Ack.MaxReminders,
+		recallWindow:    cfg.Notification.RecallWindow,
 	}
 }
 
-func (s *notificationService) Create(req CreateNotifRequest) error {
-	// Permission Check inside Service
-	// Verify sender is counselor of dept or teacher of class
+func (s *notificationService) Create(req CreateNotifRequest) (*model.Notification, error) {
+	audience, err := s.resolveAudience(&req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUrgentPermission(req); err != nil {
+		return nil, err
+	}
+	if err := s.applyTemplate(&req); err != nil {
+		return nil, err
+	}
+
+	notif := model.Notification{
+		SenderID: req.SenderID,
+		Category: "announcement",
+		Audience: audience,
+	}
+	applyNotifRequest(&notif, req)
+	if err := s.saveAndMaybePublish(&notif, req.Draft, true); err != nil {
+		return nil, err
+	}
+	return &notif, nil
+}
+
+// senderScope 发送者可以发送通知的范围
+type senderScope struct {
+	depts     map[uint]bool // 担任辅导员的部门
+	classes   map[uint]bool // 任教的班级
+	broadcast bool          // 可按角色或全校发送 (notification:broadcast)
+}
+
+func (s *notificationService) senderScope(senderID, roleID uint) senderScope {
+	scope := senderScope{depts: map[uint]bool{}, classes: map[uint]bool{}}
+	if depts, err := s.orgRepo.ListDepartmentsByCounselorID(senderID); err == nil {
+		for _, d := range depts {
+			scope.depts[d.ID] = true
+		}
+	}
+	if classes, err := s.orgRepo.ListClassesByTeacherID(senderID); err == nil {
+		for _, c := range classes {
+			scope.classes[c.ID] = true
+		}
+	}
+	scope.broadcast, _ = s.userRepo.CheckPermission(roleID, "notification:broadcast")
+	return scope
+}
+
+// checkTargetPermission 部门需由发送者担任辅导员，班级需由发送者任教，
+// 学生需在发送者的部门或班级中；按角色和全校发送需要 notification:broadcast 权限
+func (s *notificationService) checkTargetPermission(scope senderScope, t model.Target) error {
 	hasPerm := false
-	if req.TargetType == "dept" {
-		depts, err := s.orgRepo.ListDepartmentsByCounselorID(req.SenderID)
-		if err == nil {
-			for _, d := range depts {
-				if d.ID == req.TargetID {
-					hasPerm = true
-					break
-				}
-			}
+	switch t.Type {
+	case "dept":
+		hasPerm = scope.depts[t.ID]
+	case "class":
+		hasPerm = scope.classes[t.ID]
+	case "student":
+		hasPerm = scope.broadcast
+		if deptID, err := s.orgRepo.GetStudentDepartmentID(t.ID); err == nil && scope.depts[deptID] {
+			hasPerm = true
 		}
-	} else if req.TargetType == "class" {
-		classes, err := s.orgRepo.ListClassesByTeacherID(req.SenderID)
-		if err == nil {
-			for _, c := range classes {
-				if c.ID == req.TargetID {
-					hasPerm = true
-					break
-				}
+		if classIDs, err := s.orgRepo.GetStudentClassIDs(t.ID); err == nil {
+			for _, id := range classIDs {
+				hasPerm = hasPerm || scope.classes[id]
 			}
 		}
+	case "role", "school":
+		hasPerm = scope.broadcast
+	default:
+		return errors.New("未知的通知对象类型")
 	}
 
 	if !hasPerm {
 		return errors.New("没有权限向该目标发送通知")
 	}
+	return nil
+}
 
-	notif := model.Notification{
-		Title:      req.Title,
-		Content:    req.Content,
-		SenderID:   req.SenderID,
-		TargetType: req.TargetType,
-		TargetID:   req.TargetID,
+// resolveAudience 合并请求中的通知对象并逐项检查权限。只有一个对象时按单目标保存，
+// 否则请求的目标改为 audience，返回需要保存的受众组成。
+func (s *notificationService) resolveAudience(req *CreateNotifRequest) ([]model.NotificationAudience, error) {
+	parts := req.Audience
+	if len(parts) == 0 && req.TargetType != "" {
+		parts = []model.Target{{Type: req.TargetType, ID: req.TargetID}}
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("请指定通知对象")
+	}
+
+	scope := s.senderScope(req.SenderID, req.RoleID)
+	seen := make(map[model.Target]bool, len(parts))
+	var unique []model.Target
+	for _, t := range parts {
+		if t.Type == "school" {
+			t.ID = 0
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if err := s.checkTargetPermission(scope, t); err != nil {
+			return nil, err
+		}
+		unique = append(unique, t)
 	}
 
-	if err := s.notifRepo.CreateNotification(&notif); err != nil {
+	if len(unique) == 1 {
+		req.TargetType, req.TargetID = unique[0].Type, unique[0].ID
+		return nil, nil
+	}
+	req.TargetType, req.TargetID = "audience", 0
+	audience := make([]model.NotificationAudience, len(unique))
+	for i, t := range unique {
+		audience[i] = model.NotificationAudience{TargetType: t.Type, TargetID: t.ID}
+	}
+	return audience, nil
+}
+
+func (s *notificationService) checkUrgentPermission(req CreateNotifRequest) error {
+	if !req.Urgent {
+		return nil
+	}
+	if allowed, _ := s.userRepo.CheckPermission(req.RoleID, "notification:urgent"); !allowed {
+		return errors.New("无权限发送紧急通知")
+	}
+	return nil
+}
+
+// applyTemplate 用模板渲染请求的标题和内容，已填写的标题或内容优先
+func (s *notificationService) applyTemplate(req *CreateNotifRequest) error {
+	if req.TemplateKey != "" {
+		tmpl, err := s.templates.Resolve(req.TemplateKey, req.Locale, req.SenderID)
+		if err != nil {
+			return err
+		}
+		if req.Title == "" {
+			req.Title = tmpl.Title
+		}
+		if req.Content == "" {
+			req.Content = tmpl.Content
+		}
+		req.Title = utils.RenderPlaceholders(req.Title, req.Variables)
+		req.Content = utils.RenderPlaceholders(req.Content, req.Variables)
+	}
+	if req.Title == "" || req.Content == "" {
+		return errors.New("标题和内容不能为空")
+	}
+	return validateContent(req.Format, req.Attachments)
+}
+
+func applyNotifRequest(notif *model.Notification, req CreateNotifRequest) {
+	notif.Title = req.Title
+	notif.Content = req.Content
+	notif.TargetType = req.TargetType
+	notif.TargetID = req.TargetID
+	notif.RequireAck = req.RequireAck
+	notif.Urgent = req.Urgent
+	notif.ScheduledAt = req.ScheduledAt
+	notif.Recurrence = req.Recurrence
+	notif.RecurrenceUntil = req.RecurrenceUntil
+	notif.RecurrenceDay = 0
+	if req.Recurrence == "monthly" && req.ScheduledAt != nil {
+		notif.RecurrenceDay = req.ScheduledAt.Day()
+	}
+	notif.Format = req.Format
+	if notif.Format == "" {
+		notif.Format = "text"
+	}
+	notif.Attachments = encodeAttachments(req.Attachments)
+}
+
+// saveAndMaybePublish 按草稿/定时/立即发布保存通知。立即发布的通知由后台任务异步推送，推送失败不影响发布。
+func (s *notificationService) saveAndMaybePublish(notif *model.Notification, draft, isNew bool) error {
+	switch {
+	case draft:
+		notif.Status = "draft"
+	case notif.ScheduledAt != nil:
+		if err := validateSchedule(notif); err != nil {
+			return err
+		}
+		notif.Status = "scheduled"
+	case notif.Recurrence != "":
+		return errors.New("周期通知需要设置首次发送时间")
+	default:
+		if isNew {
+			notif.Status = "published"
+			return s.notifRepo.CreateNotificationWithOutbox(notif)
+		}
+		if err := s.notifRepo.UpdateNotification(notif); err != nil {
+			return err
+		}
+		_, err := s.notifRepo.PublishNotification(notif, time.Now())
+		return err
+	}
+
+	if isNew {
+		return s.notifRepo.CreateNotification(notif)
+	}
+	return s.notifRepo.UpdateNotification(notif)
+}
+
+func validateSchedule(notif *model.Notification) error {
+	if !notif.ScheduledAt.After(time.Now()) {
+		return errors.New("定时发送时间必须晚于当前时间")
+	}
+	if notif.RecurrenceUntil != nil && notif.RecurrenceUntil.Before(*notif.ScheduledAt) {
+		return errors.New("重复截止时间不能早于首次发送时间")
+	}
+	return nil
+}
+
+// ListSchedules 发送者的草稿和待发送的定时通知
+func (s *notificationService) ListSchedules(userID uint) ([]model.Notification, error) {
+	return s.notifRepo.ListSchedulesBySender(userID)
+}
+
+// editableNotification 获取发送者尚未发布的通知
+func (s *notificationService) editableNotification(userID, notifID uint) (*model.Notification, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, errors.New("通知不存在")
+	}
+	if notif.SenderID != userID {
+		return nil, errors.New("无权修改该通知")
+	}
+	if notif.Status != "draft" && notif.Status != "scheduled" {
+		return nil, errors.New("通知已发送或已取消")
+	}
+	return notif, nil
+}
+
+// UpdateSchedule 修改草稿或定时通知；去掉定时且不保存为草稿时立即发布
+func (s *notificationService) UpdateSchedule(userID, notifID uint, req CreateNotifRequest) (*model.Notification, error) {
+	notif, err := s.editableNotification(userID, notifID)
+	if err != nil {
+		return nil, err
+	}
+	req.SenderID = userID
+	audience, err := s.resolveAudience(&req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUrgentPermission(req); err != nil {
+		return nil, err
+	}
+	if err := s.applyTemplate(&req); err != nil {
+		return nil, err
+	}
+	applyNotifRequest(notif, req)
+	notif.Audience = audience
+	if err := s.saveAndMaybePublish(notif, req.Draft, false); err != nil {
+		return nil, err
+	}
+	return notif, nil
+}
+
+// PublishDraft 提交草稿：设置了定时则进入定时队列，否则立即发布
+func (s *notificationService) PublishDraft(userID, notifID uint) (*model.Notification, error) {
+	notif, err := s.editableNotification(userID, notifID)
+	if err != nil {
+		return nil, err
+	}
+	if notif.Status != "draft" {
+		return nil, errors.New("只能提交草稿")
+	}
+	if err := s.saveAndMaybePublish(notif, false, false); err != nil {
+		return nil, err
+	}
+	return notif, nil
+}
+
+func (s *notificationService) CancelSchedule(userID, notifID uint) error {
+	notif, err := s.editableNotification(userID, notifID)
+	if err != nil {
 		return err
 	}
+	notif.Status = "cancelled"
+	return s.notifRepo.UpdateNotification(notif)
+}
 
-	if _, err := utils.PushNotification(notif, s.db); err != nil {
+// DispatchScheduled 发送到期的定时通知
+func (s *notificationService) DispatchScheduled() error {
+	now := time.Now()
+	due, err := s.notifRepo.ListDueSchedules(now)
+	if err != nil {
 		return err
 	}
 
+	for i := range due {
+		notif := &due[i]
+		if notif.Recurrence == "" {
+			if _, err := s.notifRepo.PublishNotification(notif, now); err != nil {
+				log.Printf("scheduler: publish notification %d: %v", notif.ID, err)
+			}
+			continue
+		}
+
+		occurrence := model.Notification{
+			Title:       notif.Title,
+			Content:     notif.Content,
+			SenderID:    notif.SenderID,
+			TargetType:  notif.TargetType,
+			TargetID:    notif.TargetID,
+			Category:    notif.Category,
+			RequireAck:  notif.RequireAck,
+			Urgent:      notif.Urgent,
+			Format:      notif.Format,
+			Attachments: notif.Attachments,
+			Status:      "published",
+			ScheduleID:  &notif.ID,
+			CreatedAt:   now,
+		}
+		if notif.TargetType == "audience" {
+			audience, err := s.notifRepo.ListAudience(notif.ID)
+			if err != nil {
+				log.Printf("scheduler: load audience of notification %d: %v", notif.ID, err)
+				continue
+			}
+			for _, a := range audience {
+				occurrence.Audience = append(occurrence.Audience, model.NotificationAudience{TargetType: a.TargetType, TargetID: a.TargetID})
+			}
+		}
+		next := nextOccurrence(*notif.ScheduledAt, notif.Recurrence, notif.RecurrenceDay, now)
+		if notif.RecurrenceUntil != nil && next.After(*notif.RecurrenceUntil) {
+			next = nil
+		}
+		if _, err := s.notifRepo.DispatchOccurrence(notif, next, &occurrence); err != nil {
+			log.Printf("scheduler: dispatch recurring notification %d: %v", notif.ID, err)
+		}
+	}
 	return nil
 }
 
-func (s *notificationService) GetMyNotifications(studentID uint) ([]model.Notification, error) {
-	targets := []model.Target{}
+// nextOccurrence 周期通知的下一次发送时间，停机期间错过的次数直接跳过。
+// 按月重复时固定在每月的 day 日，短月取月末，不会因某次落在月末而漂移。
+func nextOccurrence(from time.Time, recurrence string, day int, now time.Time) *time.Time {
+	next := from
+	for !next.After(now) {
+		switch recurrence {
+		case "daily":
+			next = next.AddDate(0, 0, 1)
+		case "weekly":
+			next = next.AddDate(0, 0, 7)
+		case "monthly":
+			next = nextMonthDay(next, day)
+		default:
+			return nil
+		}
+	}
+	return &next
+}
+
+// nextMonthDay t 的下个月的第 day 日 (day 为 0 时取 t 的日期)，超出当月天数时取最后一天
+func nextMonthDay(t time.Time, day int) time.Time {
+	if day == 0 {
+		day = t.Day()
+	}
+	first := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+func (s *notificationService) GetMyNotifications(studentID uint) ([]NotificationView, error) {
+	notifs, err := s.notifRepo.GetNotificationsForTargets(s.myTargets(studentID), s.mutedCategories(studentID))
+	if err != nil {
+		return nil, err
+	}
+	return s.withReadState(studentID, notifs)
+}
+
+// Inbox 合并部门、班级和个人消息的收件箱，按时间倒序游标分页
+func (s *notificationService) Inbox(userID uint, q InboxQuery) (*InboxPage, error) {
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	filter := repo.InboxFilter{
+		Muted:    s.mutedCategories(userID),
+		Category: q.Category,
+		Keyword:  q.Keyword,
+		Limit:    q.Limit + 1,
+	}
+	if q.Cursor != 0 {
+		cursor, err := s.notifRepo.GetNotificationByID(q.Cursor)
+		if err != nil {
+			return nil, errors.New("无效的分页游标")
+		}
+		filter.Before = cursor
+	}
+	notifs, err := s.notifRepo.QueryInbox(s.myTargets(userID), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &InboxPage{}
+	if len(notifs) > q.Limit {
+		notifs = notifs[:q.Limit]
+		page.NextCursor = notifs[len(notifs)-1].ID
+	}
+	page.Items, err = s.withReadState(userID, notifs)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// withReadState 为通知附加当前用户的阅读与确认状态
+func (s *notificationService) withReadState(userID uint, notifs []model.Notification) ([]NotificationView, error) {
+	ids := make([]uint, len(notifs))
+	for i, n := range notifs {
+		ids[i] = n.ID
+	}
+	reads, err := s.notifRepo.ListReadsByUser(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	readByID := make(map[uint]model.NotificationRead, len(reads))
+	for _, r := range reads {
+		readByID[r.NotificationID] = r
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]NotificationView, len(notifs))
+	for i, n := range notifs {
+		views[i] = NotificationView{
+			Notification: personalize(n, user),
+			Attachments:  decodeAttachments(n.Attachments),
+			Edited:       n.EditedAt != nil,
+		}
+		if r, ok := readByID[n.ID]; ok {
+			views[i].Read = true
+			views[i].ReadAt = &r.ReadAt
+			views[i].Acked = r.AckedAt != nil
+			views[i].AckedAt = r.AckedAt
+		}
+	}
+	return views, nil
+}
+
+// myTargets 用户所在的部门和班级、角色和全校，以及发给个人的消息
+func (s *notificationService) myTargets(userID uint) []model.Target {
+	targets := []model.Target{
+		{Type: "student", ID: userID},
+		{Type: "user", ID: userID},
+		{Type: "school", ID: 0},
+	}
+	if user, err := s.userRepo.GetUserByID(userID); err == nil {
+		targets = append(targets, model.Target{Type: "role", ID: user.RoleID})
+	}
 
 	// Get Student's Department
-	deptID, err := s.orgRepo.GetStudentDepartmentID(studentID)
+	deptID, err := s.orgRepo.GetStudentDepartmentID(userID)
 	if err == nil && deptID != 0 {
 		targets = append(targets, model.Target{Type: "dept", ID: deptID})
 	}
 
 	// Get Student's Classes
-	classIDs, err := s.orgRepo.GetStudentClassIDs(studentID)
+	classIDs, err := s.orgRepo.GetStudentClassIDs(userID)
 	if err == nil {
 		for _, cid := range classIDs {
 			targets = append(targets, model.Target{Type: "class", ID: cid})
 		}
 	}
+	return targets
+}
+
+func (s *notificationService) MarkRead(userID, notifID uint) error {
+	if _, err := s.receivedNotification(userID, notifID); err != nil {
+		return err
+	}
+	return s.notifRepo.MarkRead([]uint{notifID}, userID)
+}
+
+// receivedNotification 获取用户收到的通知，不是接收人时按不存在处理
+func (s *notificationService) receivedNotification(userID, notifID uint) (*model.Notification, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, errors.New("通知不存在")
+	}
+	if notif.Status != "published" {
+		return nil, errors.New("通知不存在")
+	}
+	parts := []model.Target{{Type: notif.TargetType, ID: notif.TargetID}}
+	if notif.TargetType == "audience" {
+		audience, err := s.notifRepo.ListAudience(notif.ID)
+		if err != nil {
+			return nil, err
+		}
+		parts = parts[:0]
+		for _, a := range audience {
+			parts = append(parts, model.Target{Type: a.TargetType, ID: a.TargetID})
+		}
+	}
+	for _, t := range s.myTargets(userID) {
+		for _, p := range parts {
+			if t == p {
+				return notif, nil
+			}
+		}
+	}
+	return nil, errors.New("通知不存在")
+}
+
+func (s *notificationService) MarkAllRead(userID uint) error {
+	ids, err := s.notifRepo.ListNotificationIDsForTargets(s.myTargets(userID))
+	if err != nil {
+		return err
+	}
+	return s.notifRepo.MarkRead(ids, userID)
+}
+
+func (s *notificationService) UnreadCount(userID uint) (int64, error) {
+	return s.notifRepo.CountUnread(userID, s.myTargets(userID), s.mutedCategories(userID))
+}
+
+// GetReadStats 发送者查看通知的阅读情况
+func (s *notificationService) GetReadStats(userID, notifID uint) (*ReadStats, error) {
+	_, rows, err := s.recipientReads(userID, notifID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ReadStats{
+		Total:       len(rows),
+		UnreadUsers: []repo.RecipientRead{},
+		ReadUsers:   []repo.RecipientRead{},
+	}
+	for _, r := range rows {
+		if r.ReadAt != nil {
+			stats.ReadUsers = append(stats.ReadUsers, r)
+		} else {
+			stats.UnreadUsers = append(stats.UnreadUsers, r)
+		}
+	}
+	stats.Read = len(stats.ReadUsers)
+	stats.Unread = len(stats.UnreadUsers)
+	return stats, nil
+}
+
+// recipientReads 发送者查看通知每个接收人的阅读与确认状态
+func (s *notificationService) recipientReads(userID, notifID uint) (*model.Notification, []repo.RecipientRead, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, nil, errors.New("通知不存在")
+	}
+	if notif.SenderID != userID {
+		return nil, nil, errors.New("无权查看该通知")
+	}
+
+	userIDs, err := s.pushSvc.ResolveRecipients(*notif)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := s.notifRepo.ListRecipientReads(notifID, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return notif, rows, nil
+}
+
+// Acknowledge 学生确认已阅读并知悉
+func (s *notificationService) Acknowledge(userID, notifID uint) error {
+	notif, err := s.receivedNotification(userID, notifID)
+	if err != nil {
+		return err
+	}
+	if !notif.RequireAck {
+		return errors.New("该通知无需确认")
+	}
+	return s.notifRepo.Acknowledge(notifID, userID)
+}
+
+func (s *notificationService) GetAckStats(userID, notifID uint) (*AckStats, error) {
+	notif, rows, err := s.recipientReads(userID, notifID)
+	if err != nil {
+		return nil, err
+	}
+	if !notif.RequireAck {
+		return nil, errors.New("该通知无需确认")
+	}
+
+	stats := &AckStats{
+		Total:        len(rows),
+		AckReminders: notif.AckReminders,
+		AckedUsers:   []repo.RecipientRead{},
+		PendingUsers: []repo.RecipientRead{},
+	}
+	for _, r := range rows {
+		if r.AckedAt != nil {
+			stats.AckedUsers = append(stats.AckedUsers, r)
+		} else {
+			stats.PendingUsers = append(stats.PendingUsers, r)
+		}
+	}
+	stats.Acked = len(stats.AckedUsers)
+	stats.Pending = len(stats.PendingUsers)
+	return stats, nil
+}
+
+// ExportAckList 导出确认名单，未确认的学生排在前面
+func (s *notificationService) ExportAckList(userID, notifID uint) (string, error) {
+	stats, err := s.GetAckStats(userID, notifID)
+	if err != nil {
+		return "", err
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	var rows [][]interface{}
+	for _, group := range []struct {
+		status string
+		users  []repo.RecipientRead
+	}{{"未确认", stats.PendingUsers}, {"已确认", stats.AckedUsers}} {
+		for _, u := range group.users {
+			studentNo := ""
+			if u.StudentNo != nil {
+				studentNo = *u.StudentNo
+			}
+			rows = append(rows, []interface{}{studentNo, u.Nickname, group.status, formatTime(u.ReadAt), formatTime(u.AckedAt)})
+		}
+	}
+
+	return utils.ExportSheetsToExcel([]utils.Sheet{{
+		Name:    "确认情况",
+		Headers: []string{"学号", "姓名", "状态", "阅读时间", "确认时间"},
+		Rows:    rows,
+	}}, fmt.Sprintf("notification_ack_%d", notifID))
+}
+
+// SendAckReminders 向未确认的接收人发送确认提醒，全部确认后不再提醒
+func (s *notificationService) SendAckReminders() error {
+	if s.ackRemindEvery <= 0 || s.ackMaxReminders <= 0 {
+		return nil
+	}
+	now := time.Now()
+	notifs, err := s.notifRepo.ListAckReminderDue(now.Add(-s.ackRemindEvery), s.ackMaxReminders)
+	if err != nil {
+		return err
+	}
+
+	for _, notif := range notifs {
+		userIDs, err := s.pushSvc.ResolveRecipients(notif)
+		if err != nil {
+			log.Printf("ack reminder: resolve recipients of notification %d: %v", notif.ID, err)
+			continue
+		}
+		acked, err := s.notifRepo.ListAckedUserIDs(notif.ID)
+		if err != nil {
+			log.Printf("ack reminder: list acks of notification %d: %v", notif.ID, err)
+			continue
+		}
+		done := make(map[uint]bool, len(acked))
+		for _, id := range acked {
+			done[id] = true
+		}
+		var pending []uint
+		for _, id := range userIDs {
+			if !done[id] {
+				pending = append(pending, id)
+			}
+		}
+
+		if len(pending) == 0 {
+			if err := s.notifRepo.CompleteAckReminders(notif.ID, now); err != nil {
+				log.Printf("ack reminder: complete notification %d: %v", notif.ID, err)
+			}
+			continue
+		}
+
+		// 提醒作为一条新通知发给未确认的人，经投递队列按渠道、免打扰和重试规则发送
+		title := []rune("请确认：" + notif.Title)
+		if len(title) > 100 {
+			title = title[:100]
+		}
+		reminder := model.Notification{
+			Title:      string(title),
+			Content:    notif.Content,
+			Format:     notif.Format,
+			SenderID:   notif.SenderID,
+			TargetType: "audience",
+			Category:   notif.Category,
+			Urgent:     notif.Urgent,
+			RefType:    "notification",
+			RefID:      notif.ID,
+		}
+		for _, id := range pending {
+			reminder.Audience = append(reminder.Audience, model.NotificationAudience{TargetType: "user", TargetID: id})
+		}
+		if err := s.notifRepo.CreateNotificationWithOutbox(&reminder); err != nil {
+			log.Printf("ack reminder: create reminder of notification %d: %v", notif.ID, err)
+			continue
+		}
+		if err := s.notifRepo.RecordAckReminder(notif.ID, now); err != nil {
+			log.Printf("ack reminder: record reminder of notification %d: %v", notif.ID, err)
+		}
+	}
+	return nil
+}
+
+// DeliverOutbox 投递到期的通知任务。失败后按指数退避重试，只重发尚未成功的接收人；
+// 达到最大次数后进入 dead 状态，不再自动重试。
+func (s *notificationService) DeliverOutbox() error {
+	now := time.Now()
+	jobs, err := s.notifRepo.ListDueOutbox(now, outboxBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		claimed, err := s.notifRepo.ClaimOutbox(job, now.Add(outboxLease))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		s.deliver(job)
+	}
+	return nil
+}
 
-	return s.notifRepo.GetNotificationsForTargets(targets)
+func (s *notificationService) deliver(job *model.NotificationOutbox) {
+	now := time.Now()
+	var deferUntil *time.Time
+	deliverErr := func() error {
+		notif, err := s.notifRepo.GetNotificationByID(job.NotificationID)
+		if err != nil {
+			return err
+		}
+		if notif.Status == "recalled" {
+			return s.deliverRecall(*notif)
+		}
+		// 每个渠道独立计算待投递的接收人，某一渠道失败重试时不会在其他渠道重复发送
+		var errs []string
+		for _, ch := range s.channels {
+			pending, err := s.pushSvc.PendingRecipients(*notif, ch.Name())
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", ch.Name(), err))
+				continue
+			}
+			// 非紧急通知对处于免打扰时段的接收人延后到时段结束
+			if !notif.Urgent {
+				var until *time.Time
+				pending, until = s.splitQuiet(pending, now)
+				if until != nil && (deferUntil == nil || until.Before(*deferUntil)) {
+					deferUntil = until
+				}
+			}
+			if len(pending) == 0 {
+				continue
+			}
+			failed, err := ch.Deliver(*notif, pending)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", ch.Name(), err))
+				continue
+			}
+			if len(failed) > 0 {
+				errs = append(errs, fmt.Sprintf("%s: %d 个接收人投递失败", ch.Name(), len(failed)))
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+		return nil
+	}()
+
+	switch {
+	case deliverErr != nil:
+		job.Attempts++
+		job.LastError = truncate(deliverErr.Error(), 255)
+		if job.Attempts >= s.maxAttempts {
+			job.Status = "dead"
+			break
+		}
+		job.Status = "pending"
+		job.NextAttemptAt = now.Add(s.backoff(job.Attempts))
+	case deferUntil != nil:
+		// 免打扰延后不计入失败次数
+		job.Status = "pending"
+		job.NextAttemptAt = *deferUntil
+	default:
+		job.Attempts++
+		job.Status = "delivered"
+		job.LastError = ""
+		job.DeliveredAt = &now
+	}
+	if err := s.notifRepo.UpdateOutbox(job); err != nil {
+		log.Printf("outbox: failed to update job %d: %v", job.ID, err)
+	}
+}
+
+// backoff 第 n 次失败后的等待时长：base * 2^(n-1)，不超过 maxBackoff
+func (s *notificationService) backoff(attempts int) time.Duration {
+	d := s.baseBackoff
+	for i := 1; i < attempts && d < s.maxBackoff; i++ {
+		d *= 2
+	}
+	if s.maxBackoff > 0 && d > s.maxBackoff {
+		d = s.maxBackoff
+	}
+	return d
+}
+
+// GetDeliveryStatus 发送者查看通知的投递状态
+func (s *notificationService) GetDeliveryStatus(userID, notifID uint) (*DeliveryStatus, error) {
+	notif, err := s.notifRepo.GetNotificationByID(notifID)
+	if err != nil {
+		return nil, errors.New("通知不存在")
+	}
+	if notif.SenderID != userID {
+		return nil, errors.New("无权查看该通知")
+	}
+
+	status := &DeliveryStatus{Status: "delivered"}
+	if notif.Status != "published" {
+		status.Status = notif.Status
+	}
+	if job, err := s.notifRepo.GetOutboxByNotificationID(notifID); err == nil {
+		status.Status = job.Status
+		status.Attempts = job.Attempts
+		status.LastError = job.LastError
+		status.DeliveredAt = job.DeliveredAt
+		if job.Status == "pending" {
+			status.NextAttemptAt = &job.NextAttemptAt
+		}
+	}
+
+	userIDs, err := s.pushSvc.ResolveRecipients(*notif)
+	if err != nil {
+		return nil, err
+	}
+	status.Recipients, err = s.notifRepo.ListRecipientDeliveries(notifID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range status.Recipients {
+		if status.Recipients[i].Status == "" {
+			status.Recipients[i].Status = "pending"
+		}
+	}
+	return status, nil
 }
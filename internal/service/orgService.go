@@ -22,12 +22,14 @@ type OrgService interface {
 type orgService struct {
 	orgRepo  repo.OrgRepository
 	userRepo repo.UserRepository
+	taskSvc  TaskService
 }
 
-func NewOrgService(orgRepo repo.OrgRepository, userRepo repo.UserRepository) OrgService {
+func NewOrgService(orgRepo repo.OrgRepository, userRepo repo.UserRepository, taskSvc TaskService) OrgService {
 	return &orgService{
 		orgRepo:  orgRepo,
 		userRepo: userRepo,
+		taskSvc:  taskSvc,
 	}
 }
 
@@ -116,6 +118,11 @@ func (s *orgService) StudentJoinDepartment(studentID, roleID uint, inviteCode st
 
 	log.Printf("Student %d joined Department %d", studentID, dept.ID)
 
+	// 补发该部门已发布的任务
+	if err := s.taskSvc.AssignLateJoiner(studentID, "dept", dept.ID); err != nil {
+		log.Printf("failed to assign tasks of department %d to student %d: %v", dept.ID, studentID, err)
+	}
+
 	// Update user's department_id field for quick access
 	user, err := s.userRepo.GetUserByID(studentID)
 	if err != nil {
@@ -153,6 +160,11 @@ func (s *orgService) StudentJoinClass(studentID, roleID uint, inviteCode string)
 		return err
 	}
 
+	// 补发该班级已发布的任务
+	if err := s.taskSvc.AssignLateJoiner(studentID, "class", class.ID); err != nil {
+		log.Printf("failed to assign tasks of class %d to student %d: %v", class.ID, studentID, err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+)
+
+// orgUnitTypes 组织架构的层级，下级节点只能位于上一层级的节点之下
+var orgUnitTypes = []string{"school", "college", "grade", "class"}
+
+var orgUnitTypeNames = map[string]string{
+	"school":  "学校",
+	"college": "学院",
+	"grade":   "年级",
+	"class":   "班级",
+}
+
+type OrgUnitRequest struct {
+	Name     string
+	Type     string
+	ParentID *uint
+}
+
+// OrgUnitMember 关联到节点的部门或班级
+type OrgUnitMember struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// OrgUnitNode 组织架构树的节点。StudentCount 为本节点及所有下级节点关联的学生数 (去重)。
+type OrgUnitNode struct {
+	model.OrgUnit
+	StudentCount int             `json:"student_count"`
+	Departments  []OrgUnitMember `json:"departments"`
+	Classes      []OrgUnitMember `json:"classes"`
+	Children     []*OrgUnitNode  `json:"children"`
+}
+
+type OrgUnitService interface {
+	GetTree(roleID uint) ([]*OrgUnitNode, error)
+	CreateUnit(roleID uint, req OrgUnitRequest) (*model.OrgUnit, error)
+	RenameUnit(roleID, id uint, name string) error
+	MoveUnit(roleID, id, parentID uint) (*model.OrgUnit, error)
+	DeleteUnit(roleID, id uint) error
+	AttachDepartment(roleID, deptID uint, unitID *uint) error
+	AttachClass(roleID, classID uint, unitID *uint) error
+}
+
+type orgUnitService struct {
+	unitRepo repo.OrgUnitRepository
+	userRepo repo.UserRepository
+}
+
+func NewOrgUnitService(unitRepo repo.OrgUnitRepository, userRepo repo.UserRepository) OrgUnitService {
+	return &orgUnitService{
+		unitRepo: unitRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *orgUnitService) checkView(roleID uint) error {
+	if allowed, _ := s.userRepo.CheckPermission(roleID, "org:view"); !allowed {
+		return errors.New("无权限查看组织架构")
+	}
+	return nil
+}
+
+func (s *orgUnitService) checkManage(roleID uint) error {
+	if allowed, _ := s.userRepo.CheckPermission(roleID, "org:manage"); !allowed {
+		return errors.New("无权限管理组织架构")
+	}
+	return nil
+}
+
+func orgUnitLevel(unitType string) int {
+	for i, t := range orgUnitTypes {
+		if t == unitType {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkParent 校验 unitType 类型的节点能否位于 parent 之下 (parent 为 nil 表示根节点)
+func checkParent(unitType string, parent *model.OrgUnit) error {
+	level := orgUnitLevel(unitType)
+	if level < 0 {
+		return errors.New("未知的节点类型：" + unitType)
+	}
+	if level == 0 {
+		if parent != nil {
+			return errors.New("学校只能作为根节点")
+		}
+		return nil
+	}
+	expected := orgUnitTypes[level-1]
+	if parent == nil || parent.Type != expected {
+		return errors.New(orgUnitTypeNames[unitType] + "只能位于" + orgUnitTypeNames[expected] + "之下")
+	}
+	return nil
+}
+
+func (s *orgUnitService) getUnit(id uint) (*model.OrgUnit, error) {
+	unit, err := s.unitRepo.GetOrgUnitByID(id)
+	if err != nil {
+		return nil, errors.New("节点不存在")
+	}
+	return unit, nil
+}
+
+func (s *orgUnitService) CreateUnit(roleID uint, req OrgUnitRequest) (*model.OrgUnit, error) {
+	if err := s.checkManage(roleID); err != nil {
+		return nil, err
+	}
+	var parent *model.OrgUnit
+	if req.ParentID != nil {
+		p, err := s.getUnit(*req.ParentID)
+		if err != nil {
+			return nil, errors.New("上级节点不存在")
+		}
+		parent = p
+	}
+	if err := checkParent(req.Type, parent); err != nil {
+		return nil, err
+	}
+
+	unit := model.OrgUnit{Name: strings.TrimSpace(req.Name), Type: req.Type, ParentID: req.ParentID}
+	if unit.Name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if err := s.unitRepo.CreateOrgUnit(&unit); err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+func (s *orgUnitService) RenameUnit(roleID, id uint, name string) error {
+	if err := s.checkManage(roleID); err != nil {
+		return err
+	}
+	if _, err := s.getUnit(id); err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("名称不能为空")
+	}
+	return s.unitRepo.RenameOrgUnit(id, name)
+}
+
+// MoveUnit 将节点及其下级移到新的上级节点之下。
+// 层级固定 (学校 → 学院 → 年级 → 班级)，新上级只能是同一层级的其他节点，因此不会形成环。
+func (s *orgUnitService) MoveUnit(roleID, id, parentID uint) (*model.OrgUnit, error) {
+	if err := s.checkManage(roleID); err != nil {
+		return nil, err
+	}
+	unit, err := s.getUnit(id)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := s.getUnit(parentID)
+	if err != nil {
+		return nil, errors.New("上级节点不存在")
+	}
+	if err := checkParent(unit.Type, parent); err != nil {
+		return nil, err
+	}
+	if unit.ParentID != nil && *unit.ParentID == parent.ID {
+		return unit, nil
+	}
+
+	moved, err := s.unitRepo.MoveOrgUnit(unit, parent)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, errors.New("组织架构已变化，请刷新后重试")
+	}
+	return unit, nil
+}
+
+// DeleteUnit 删除没有下级节点、也没有关联部门和班级的节点
+func (s *orgUnitService) DeleteUnit(roleID, id uint) error {
+	if err := s.checkManage(roleID); err != nil {
+		return err
+	}
+	if _, err := s.getUnit(id); err != nil {
+		return err
+	}
+	children, err := s.unitRepo.CountChildren(id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("请先删除下级节点")
+	}
+	attached, err := s.unitRepo.CountAttached(id)
+	if err != nil {
+		return err
+	}
+	if attached > 0 {
+		return errors.New("请先解除关联的部门和班级")
+	}
+	return s.unitRepo.DeleteOrgUnit(id)
+}
+
+// AttachDepartment 部门关联到学院或年级节点，unitID 为 nil 时解除关联
+func (s *orgUnitService) AttachDepartment(roleID, deptID uint, unitID *uint) error {
+	if err := s.checkManage(roleID); err != nil {
+		return err
+	}
+	if unitID != nil {
+		unit, err := s.getUnit(*unitID)
+		if err != nil {
+			return err
+		}
+		if unit.Type != "college" && unit.Type != "grade" {
+			return errors.New("部门只能关联学院或年级节点")
+		}
+	}
+	found, err := s.unitRepo.AttachDepartment(deptID, unitID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("部门不存在")
+	}
+	return nil
+}
+
+// AttachClass 班级关联到班级节点，unitID 为 nil 时解除关联
+func (s *orgUnitService) AttachClass(roleID, classID uint, unitID *uint) error {
+	if err := s.checkManage(roleID); err != nil {
+		return err
+	}
+	if unitID != nil {
+		unit, err := s.getUnit(*unitID)
+		if err != nil {
+			return err
+		}
+		if unit.Type != "class" {
+			return errors.New("班级只能关联班级节点")
+		}
+	}
+	found, err := s.unitRepo.AttachClass(classID, unitID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("班级不存在")
+	}
+	return nil
+}
+
+// GetTree 完整的组织架构树，附带关联的部门、班级和学生数
+func (s *orgUnitService) GetTree(roleID uint) ([]*OrgUnitNode, error) {
+	if err := s.checkView(roleID); err != nil {
+		return nil, err
+	}
+	units, err := s.unitRepo.ListOrgUnits()
+	if err != nil {
+		return nil, err
+	}
+	depts, err := s.unitRepo.ListAttachedDepartments()
+	if err != nil {
+		return nil, err
+	}
+	classes, err := s.unitRepo.ListAttachedClasses()
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.unitRepo.ListUnitStudents()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*OrgUnitNode, len(units))
+	for _, u := range units {
+		nodes[u.ID] = &OrgUnitNode{
+			OrgUnit:     u,
+			Departments: []OrgUnitMember{},
+			Classes:     []OrgUnitMember{},
+			Children:    []*OrgUnitNode{},
+		}
+	}
+	for _, d := range depts {
+		if n, ok := nodes[*d.OrgUnitID]; ok {
+			n.Departments = append(n.Departments, OrgUnitMember{ID: d.ID, Name: d.Name})
+		}
+	}
+	for _, c := range classes {
+		if n, ok := nodes[*c.OrgUnitID]; ok {
+			n.Classes = append(n.Classes, OrgUnitMember{ID: c.ID, Name: c.Name})
+		}
+	}
+
+	// 学生计入所在节点及其全部上级，按节点去重
+	students := make(map[uint]map[uint]bool, len(nodes))
+	for _, l := range links {
+		n, ok := nodes[l.OrgUnitID]
+		if !ok {
+			continue
+		}
+		for _, id := range pathIDs(n.Path) {
+			if students[id] == nil {
+				students[id] = make(map[uint]bool)
+			}
+			students[id][l.StudentID] = true
+		}
+	}
+
+	roots := []*OrgUnitNode{}
+	for _, u := range units {
+		n := nodes[u.ID]
+		n.StudentCount = len(students[u.ID])
+		if u.ParentID == nil {
+			roots = append(roots, n)
+		} else if parent, ok := nodes[*u.ParentID]; ok {
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	return roots, nil
+}
+
+// pathIDs 物化路径 (如 /1/5/9) 中的节点 ID
+func pathIDs(path string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if id, err := strconv.ParseUint(part, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/internal/utils"
+	"unihub/pkg/push"
+)
+
+// defaultPushPlatform 未登记平台的令牌按 FCM 推送
+const defaultPushPlatform = "fcm"
+
+// PushService 将站内通知推送到目标用户的移动设备，同时负责解析通知的接收人
+type PushService interface {
+	DeliveryChannel
+	PushNotification(notif model.Notification) (string, error)
+	ResolveRecipients(notif model.Notification) ([]uint, error)
+	PendingRecipients(notif model.Notification, channel string) ([]uint, error)
+	RecallRecipients(notif model.Notification) ([]uint, error)
+}
+
+type pushService struct {
+	pushRepo  repo.PushRepository
+	orgRepo   repo.OrgRepository
+	userRepo  repo.UserRepository
+	notifRepo repo.NotificationRepository
+	prefRepo  repo.PreferenceRepository
+	pushers   map[string]push.Pusher
+}
+
+// NewPushService 根据配置注册推送服务商，未配置凭据的平台不会推送
+func NewPushService(pushRepo repo.PushRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, notifRepo repo.NotificationRepository, prefRepo repo.PreferenceRepository, cfg *config.Config) PushService {
+	client := &http.Client{Timeout: cfg.Push.Timeout}
+	var pushers []push.Pusher
+	if cfg.Push.FCM.Endpoint != "" && cfg.Push.FCM.ServerKey != "" {
+		pushers = append(pushers, push.NewFCMPusher(cfg.Push.FCM.Endpoint, cfg.Push.FCM.ServerKey, cfg.Push.BatchSize, client))
+	}
+	if cfg.Push.APNs.Endpoint != "" && cfg.Push.APNs.AuthToken != "" {
+		pushers = append(pushers, push.NewAPNsPusher(cfg.Push.APNs.Endpoint, cfg.Push.APNs.Topic, cfg.Push.APNs.AuthToken, cfg.Push.BatchSize, client))
+	}
+	return NewPushServiceWithPushers(pushRepo, orgRepo, userRepo, notifRepo, prefRepo, pushers...)
+}
+
+// NewPushServiceWithPushers 使用指定的推送服务商，便于接入模拟服务
+func NewPushServiceWithPushers(pushRepo repo.PushRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, notifRepo repo.NotificationRepository, prefRepo repo.PreferenceRepository, pushers ...push.Pusher) PushService {
+	m := make(map[string]push.Pusher, len(pushers))
+	for _, p := range pushers {
+		m[p.Name()] = p
+	}
+	return &pushService{
+		pushRepo:  pushRepo,
+		orgRepo:   orgRepo,
+		userRepo:  userRepo,
+		notifRepo: notifRepo,
+		prefRepo:  prefRepo,
+		pushers:   m,
+	}
+}
+
+func (s *pushService) Name() string { return "push" }
+
+// PushNotification 解析通知目标用户并推送
+func (s *pushService) PushNotification(notif model.Notification) (string, error) {
+	userIDs, err := s.ResolveRecipients(notif)
+	if err != nil {
+		return "查询目标用户失败", err
+	}
+	if len(userIDs) == 0 {
+		return "未找到目标学生", nil
+	}
+	failed, err := s.Deliver(notif, userIDs)
+	if err != nil {
+		return "查询推送令牌失败", err
+	}
+	return fmt.Sprintf("消息推送成功 %d/%d", len(userIDs)-len(failed), len(userIDs)), nil
+}
+
+// Deliver 按平台分批推送给指定用户，记录每个接收人的结果并清除失效令牌。
+// 返回可重试 (非令牌失效) 的失败用户。
+func (s *pushService) Deliver(notif model.Notification, userIDs []uint) ([]uint, error) {
+	var attempts []model.PushAttempt
+
+	// 跳过关闭了该类消息推送的用户
+	muted, err := s.prefRepo.ListPushMutedUserIDs(userIDs, notif.Category)
+	if err != nil {
+		return nil, err
+	}
+	if len(muted) > 0 {
+		isMuted := make(map[uint]bool, len(muted))
+		for _, id := range muted {
+			isMuted[id] = true
+			attempts = append(attempts, model.PushAttempt{NotificationID: notif.ID, UserID: id, Channel: "push", Status: "muted"})
+		}
+		filtered := make([]uint, 0, len(userIDs))
+		for _, id := range userIDs {
+			if !isMuted[id] {
+				filtered = append(filtered, id)
+			}
+		}
+		userIDs = filtered
+	}
+
+	users, err := s.pushRepo.ListUsersWithPushToken(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	hasToken := make(map[uint]bool, len(users))
+	byPlatform := make(map[string][]model.User)
+	for _, u := range users {
+		hasToken[u.ID] = true
+		platform := u.PushPlatform
+		if platform == "" {
+			platform = defaultPushPlatform
+		}
+		byPlatform[platform] = append(byPlatform[platform], u)
+	}
+	for _, id := range userIDs {
+		if !hasToken[id] {
+			attempts = append(attempts, model.PushAttempt{NotificationID: notif.ID, UserID: id, Channel: "push", Status: "no_token"})
+		}
+	}
+
+	sentStatus := "sent"
+	if notif.Status == "recalled" {
+		sentStatus = "recalled"
+	}
+
+	var failed []uint
+	for platform, group := range byPlatform {
+		pusher, ok := s.pushers[platform]
+		if !ok {
+			for _, u := range group {
+				attempts = append(attempts, model.PushAttempt{
+					NotificationID: notif.ID, UserID: u.ID, Channel: "push", Provider: platform, Token: u.PushToken,
+					Status: "skipped", Error: "推送平台未配置",
+				})
+			}
+			continue
+		}
+
+		for i, r := range send(pusher, notif, group) {
+			u := group[i]
+			attempt := model.PushAttempt{
+				NotificationID: notif.ID, UserID: u.ID, Channel: "push", Provider: platform, Token: u.PushToken,
+				Status: sentStatus, Error: truncate(r.Error, 255),
+			}
+			switch {
+			case r.Success:
+			case r.Invalid:
+				attempt.Status = "invalid_token"
+				if err := s.pushRepo.ClearPushToken(u.ID, u.PushToken); err != nil {
+					log.Printf("push: failed to clear token of user %d: %v", u.ID, err)
+				}
+			default:
+				attempt.Status = "failed"
+				failed = append(failed, u.ID)
+			}
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	if err := s.pushRepo.CreateAttempts(attempts); err != nil {
+		log.Printf("push: failed to record attempts for notification %d: %v", notif.ID, err)
+	}
+	return failed, nil
+}
+
+// send 批量推送；通知含按接收人渲染的占位符时逐个推送。返回结果与 users 一一对应。
+func send(pusher push.Pusher, notif model.Notification, users []model.User) []push.Result {
+	if utils.HasRecipientPlaceholders(notif.Title + notif.Content) {
+		results := make([]push.Result, 0, len(users))
+		for i := range users {
+			results = append(results, sendBatch(pusher, personalize(notif, &users[i]), users[i:i+1])...)
+		}
+		return results
+	}
+	return sendBatch(pusher, notif, users)
+}
+
+func sendBatch(pusher push.Pusher, notif model.Notification, users []model.User) []push.Result {
+	msg := push.Message{
+		Title: notif.Title,
+		Body:  notif.Content,
+		Data:  map[string]string{"notification_id": fmt.Sprint(notif.ID)},
+	}
+	if notif.Format == "markdown" {
+		msg.Body = utils.MarkdownToText(notif.Content)
+	}
+	if notif.Status == "recalled" {
+		// 客户端收到后移除本地的通知
+		msg.Data["action"] = "recall"
+	}
+	tokens := make([]string, len(users))
+	for i, u := range users {
+		tokens[i] = u.PushToken
+	}
+	results, err := pusher.Send(context.Background(), tokens, msg)
+	if err != nil {
+		log.Printf("push: %s send failed: %v", pusher.Name(), err)
+		results = make([]push.Result, len(tokens))
+		for i, t := range tokens {
+			results[i] = push.Result{Token: t, Error: err.Error()}
+		}
+	}
+	return results
+}
+
+// ResolveRecipients 通知的目标用户；多目标通知取各部分受众的并集并去重
+func (s *pushService) ResolveRecipients(notif model.Notification) ([]uint, error) {
+	if notif.TargetType != "audience" {
+		return s.resolveTarget(notif.TargetType, notif.TargetID)
+	}
+
+	audience, err := s.notifRepo.ListAudience(notif.ID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool)
+	var userIDs []uint
+	for _, a := range audience {
+		ids, err := s.resolveTarget(a.TargetType, a.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+	return userIDs, nil
+}
+
+func (s *pushService) resolveTarget(targetType string, targetID uint) ([]uint, error) {
+	switch targetType {
+	case "dept":
+		return s.orgRepo.GetStudentIDsByDepartmentID(targetID)
+	case "class":
+		return s.orgRepo.GetStudentIDsByClassID(targetID)
+	case "student", "user":
+		return []uint{targetID}, nil
+	case "role":
+		return s.userRepo.ListUserIDsByRoleID(targetID)
+	case "school":
+		return s.userRepo.ListAllUserIDs()
+	}
+	return nil, nil
+}
+
+// PendingRecipients 在指定渠道尚未得到最终结果的目标用户，用于失败重试时避免重复投递
+func (s *pushService) PendingRecipients(notif model.Notification, channel string) ([]uint, error) {
+	userIDs, err := s.ResolveRecipients(notif)
+	if err != nil {
+		return nil, err
+	}
+	settled, err := s.pushRepo.ListSettledRecipientIDs(notif.ID, channel)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[uint]bool, len(settled))
+	for _, id := range settled {
+		done[id] = true
+	}
+	pending := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !done[id] {
+			pending = append(pending, id)
+		}
+	}
+	return pending, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// RecallRecipients 已收到通知推送、尚未收到撤回推送的用户
+func (s *pushService) RecallRecipients(notif model.Notification) ([]uint, error) {
+	return s.pushRepo.ListRecallRecipientIDs(notif.ID)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"time"
+	"unihub/internal/config"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+)
+
+// MyTaskView 学生的任务及分派状态
+type MyTaskView struct {
+	TaskView
+	Status     string    `json:"status"` // pending 未提交，其余为提交的审阅状态
+	Late       bool      `json:"late"`
+	AssignedAt time.Time `json:"assigned_at"`
+	DueAt      time.Time `json:"due_at"`
+}
+
+func newAssignments(studentIDs []uint, dueAt, now time.Time) []model.TaskAssignment {
+	assignments := make([]model.TaskAssignment, len(studentIDs))
+	for i, id := range studentIDs {
+		assignments[i] = model.TaskAssignment{StudentID: id, Status: "pending", AssignedAt: now, DueAt: dueAt}
+	}
+	return assignments
+}
+
+// lateJoinerDue 任务发布后 joinedAt 才加入发布对象的学生的截止时间；按配置不分派时 ok 为 false
+func (s *taskService) lateJoinerDue(task *model.Task, joinedAt time.Time) (dueAt time.Time, ok bool) {
+	if s.lateJoiner == "none" {
+		return time.Time{}, false
+	}
+	dueAt = task.Deadline
+	if s.lateJoiner == "extend" && dueAt.Before(joinedAt.Add(s.lateJoinerGrace)) {
+		dueAt = joinedAt.Add(s.lateJoinerGrace)
+	}
+	if open, _ := submissionWindow(task, dueAt, joinedAt); !open {
+		return time.Time{}, false
+	}
+	return dueAt, true
+}
+
+// AssignLateJoiner 学生加入部门或班级后，按配置补发该对象下的任务
+func (s *taskService) AssignLateJoiner(studentID uint, targetType string, targetID uint) error {
+	if s.lateJoiner == "none" {
+		return nil
+	}
+	tasks, err := s.taskRepo.ListTasksForTarget(targetType, targetID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var assignments []model.TaskAssignment
+	for i := range tasks {
+		task := &tasks[i]
+		dueAt, ok := s.lateJoinerDue(task, now)
+		if !ok {
+			continue
+		}
+		assignments = append(assignments, model.TaskAssignment{
+			TaskID: task.ID, StudentID: studentID, Status: "pending", AssignedAt: now, DueAt: dueAt,
+		})
+	}
+	return s.taskRepo.CreateAssignments(assignments)
+}
+
+// BackfillAssignments 为分派功能上线前发布的任务生成分派记录，处理过的任务会被标记，不会重复处理：
+// 发布时已在发布对象中的学生按任务截止时间分派，之后加入的学生按后加入配置处理；
+// 已提交的学生总会分派，状态取自已有的提交
+func (s *taskService) BackfillAssignments() error {
+	tasks, err := s.taskRepo.ListTasksWithoutAssignments()
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		members, err := s.targetMembers(task)
+		if err != nil {
+			return err
+		}
+		submissions, err := s.taskRepo.ListTaskSubmissions(task.ID)
+		if err != nil {
+			return err
+		}
+
+		byStudent := make(map[uint]model.TaskAssignment)
+		for _, m := range members {
+			assignedAt, dueAt := task.CreatedAt, task.Deadline
+			if m.JoinedAt.After(task.CreatedAt) {
+				var ok bool
+				if dueAt, ok = s.lateJoinerDue(task, m.JoinedAt); !ok {
+					continue
+				}
+				assignedAt = m.JoinedAt
+			}
+			byStudent[m.StudentID] = model.TaskAssignment{TaskID: task.ID, StudentID: m.StudentID, Status: "pending", AssignedAt: assignedAt, DueAt: dueAt}
+		}
+		for _, sub := range submissions {
+			status := sub.Status
+			if status == "completed" {
+				status = "submitted"
+			}
+			a, ok := byStudent[sub.StudentID]
+			if !ok {
+				a = model.TaskAssignment{TaskID: task.ID, StudentID: sub.StudentID, AssignedAt: task.CreatedAt, DueAt: task.Deadline}
+			}
+			a.Status, a.Late = status, sub.Late
+			byStudent[sub.StudentID] = a
+		}
+		assignments := make([]model.TaskAssignment, 0, len(byStudent))
+		for _, a := range byStudent {
+			assignments = append(assignments, a)
+		}
+		if err := s.taskRepo.BackfillAssignments(task.ID, assignments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targetMembers 任务发布对象中的学生及其加入时间，直接发布给学生的视为发布时加入
+func (s *taskService) targetMembers(task *model.Task) ([]repo.TargetMember, error) {
+	if task.TargetType == "student" {
+		return []repo.TargetMember{{StudentID: task.TargetID, JoinedAt: task.CreatedAt}}, nil
+	}
+	return s.taskRepo.ListTargetMembers(task.TargetType, task.TargetID)
+}
+
+// lateJoinerPolicy 配置中的后加入学生处理方式，未知值按 open 处理
+func lateJoinerPolicy(cfg *config.Config) string {
+	switch cfg.Task.LateJoiner {
+	case "none", "extend":
+		return cfg.Task.LateJoiner
+	}
+	return "open"
+}
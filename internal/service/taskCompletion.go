@@ -0,0 +1,198 @@
+package service
+
+import (
+	"log"
+	"time"
+	"unihub/internal/model"
+	"unihub/internal/utils"
+)
+
+// CompletionStudent 完成情况中的一名学生
+type CompletionStudent struct {
+	StudentID   uint       `json:"student_id"`
+	Nickname    string     `json:"nickname"`
+	StudentNo   *string    `json:"student_no"`
+	Status      string     `json:"status"` // 提交的审阅状态，未提交为 missing
+	DueAt       time.Time  `json:"due_at"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+}
+
+// TaskCompletion 任务完成情况：按时提交、逾期提交和未提交的学生
+type TaskCompletion struct {
+	Total     int                 `json:"total"`
+	Submitted []CompletionStudent `json:"submitted"`
+	Late      []CompletionStudent `json:"late"`
+	Missing   []CompletionStudent `json:"missing"`
+}
+
+// targetStudents 任务发布对象中的学生，发布时据此生成分派记录
+func (s *taskService) targetStudents(task *model.Task) ([]model.User, error) {
+	switch task.TargetType {
+	case "dept":
+		return s.userRepo.ListStudentsByDepartmentIDs([]uint{task.TargetID})
+	case "class":
+		return s.userRepo.ListStudentsByClassIDs([]uint{task.TargetID})
+	case "student":
+		user, err := s.userRepo.GetUserByID(task.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		return []model.User{*user}, nil
+	}
+	return nil, nil
+}
+
+// completion 按分派记录统计完成情况
+func (s *taskService) completion(task *model.Task) (*TaskCompletion, error) {
+	assignees, err := s.taskRepo.ListTaskAssignees(task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TaskCompletion{
+		Total:     len(assignees),
+		Submitted: []CompletionStudent{},
+		Late:      []CompletionStudent{},
+		Missing:   []CompletionStudent{},
+	}
+	for _, a := range assignees {
+		entry := CompletionStudent{
+			StudentID:   a.StudentID,
+			Nickname:    a.Nickname,
+			StudentNo:   a.StudentNo,
+			Status:      a.Status,
+			DueAt:       a.DueAt,
+			SubmittedAt: a.SubmittedAt,
+		}
+		switch {
+		case a.Status == "pending":
+			entry.Status = "missing"
+			result.Missing = append(result.Missing, entry)
+		case a.Late:
+			result.Late = append(result.Late, entry)
+		default:
+			result.Submitted = append(result.Submitted, entry)
+		}
+	}
+	return result, nil
+}
+
+// GetCompletion 任务完成情况 (发布者)
+func (s *taskService) GetCompletion(userID uint, taskUUID string) (*TaskCompletion, error) {
+	task, err := s.createdTask(userID, taskUUID)
+	if err != nil {
+		return nil, err
+	}
+	return s.completion(task)
+}
+
+// RemindMissing 向未提交的学生发送催交通知，返回提醒人数
+func (s *taskService) RemindMissing(userID uint, taskUUID string) (int, error) {
+	task, err := s.ownedTask(userID, taskUUID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.completion(task)
+	if err != nil {
+		return 0, err
+	}
+
+	// 截止时间不同的学生提醒内容不同，按截止时间分组后每组每种语言发一条通知
+	now := time.Now()
+	closed := 0
+	var deadlines []time.Time
+	byDeadline := make(map[time.Time][]uint)
+	for _, st := range result.Missing {
+		// 已无法提交的学生不再提醒
+		if open, _ := submissionWindow(task, st.DueAt, now); !open {
+			closed++
+			continue
+		}
+		dueAt := st.DueAt
+		if task.LatePolicy == "cutoff" && task.LateCutoff != nil && now.After(dueAt) {
+			dueAt = *task.LateCutoff
+		}
+		if _, ok := byDeadline[dueAt]; !ok {
+			deadlines = append(deadlines, dueAt)
+		}
+		byDeadline[dueAt] = append(byDeadline[dueAt], st.StudentID)
+	}
+	if closed > 0 && closed == len(result.Missing) {
+		return 0, taskError("任务已截止，学生无法再提交")
+	}
+	if len(deadlines) == 0 {
+		return 0, nil
+	}
+	// 先占用本次催交，并发的重复请求和间隔内的再次催交都会被拒绝
+	marked, err := s.taskRepo.MarkTaskReminded(task.ID, now, now.Add(-s.remindInterval))
+	if err != nil {
+		return 0, err
+	}
+	if !marked {
+		return 0, taskError("提醒过于频繁，请稍后再试")
+	}
+
+	reminded := 0
+	for _, dueAt := range deadlines {
+		groups := s.templates.RenderForUsers("task_reminder", byDeadline[dueAt], map[string]string{
+			"task_title": task.Title,
+			"deadline":   dueAt.Format("01-02 15:04"),
+		})
+		for _, g := range groups {
+			notif := model.Notification{
+				Title:      g.Title,
+				Content:    g.Content,
+				SenderID:   userID,
+				TargetType: "audience",
+				Category:   g.Category,
+				RefType:    "task",
+				RefID:      task.ID,
+			}
+			for _, id := range g.UserIDs {
+				notif.Audience = append(notif.Audience, model.NotificationAudience{TargetType: "student", TargetID: id})
+			}
+			if err := s.notifRepo.CreateNotificationWithOutbox(&notif); err != nil {
+				log.Printf("task reminder: notify %d students of task %d: %v", len(g.UserIDs), task.ID, err)
+				continue
+			}
+			reminded += len(g.UserIDs)
+		}
+	}
+	return reminded, nil
+}
+
+// ExportCompletion 导出任务完成情况
+func (s *taskService) ExportCompletion(userID uint, taskUUID string) (string, error) {
+	task, err := s.createdTask(userID, taskUUID)
+	if err != nil {
+		return "", err
+	}
+	result, err := s.completion(task)
+	if err != nil {
+		return "", err
+	}
+
+	sheet := utils.Sheet{Name: "完成情况", Headers: []string{"学号", "姓名", "完成情况", "审阅状态", "提交时间"}}
+	groups := []struct {
+		label    string
+		students []CompletionStudent
+	}{
+		{"按时提交", result.Submitted},
+		{"逾期提交", result.Late},
+		{"未提交", result.Missing},
+	}
+	for _, g := range groups {
+		for _, st := range g.students {
+			studentNo, status, submittedAt := "", "", ""
+			if st.StudentNo != nil {
+				studentNo = *st.StudentNo
+			}
+			if st.SubmittedAt != nil {
+				status = st.Status
+				submittedAt = st.SubmittedAt.Format("2006-01-02 15:04:05")
+			}
+			sheet.Rows = append(sheet.Rows, []interface{}{studentNo, st.Nickname, g.label, status, submittedAt})
+		}
+	}
+	return utils.ExportSheetsToExcel([]utils.Sheet{sheet}, "task_completion")
+}
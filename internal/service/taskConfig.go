@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"slices"
+	"strings"
+	"unihub/internal/utils"
+)
+
+// taskKind 一种任务类型：校验发布时的配置和学生提交的数据，返回规范化后的结构
+type taskKind interface {
+	parseConfig(raw json.RawMessage) (any, error)
+	validateSubmission(config any, raw json.RawMessage) (any, error)
+}
+
+var taskKinds = map[string]taskKind{
+	"sign_in":         signInKind{},
+	"dorm_check":      dormCheckKind{},
+	"survey":          surveyKind{},
+	"file_submission": fileSubmissionKind{},
+}
+
+// decodeStrict 解析 JSON，不允许未定义的字段
+func decodeStrict(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// SignInConfig 定位签到：需在中心点 Radius 米范围内提交
+type SignInConfig struct {
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Radius       float64 `json:"radius"`
+	RequirePhoto bool    `json:"require_photo"`
+}
+
+type SignInSubmission struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	PhotoURL  string  `json:"photo_url,omitempty"`
+	Distance  float64 `json:"distance"` // 由服务端计算
+}
+
+type signInKind struct{}
+
+func (signInKind) parseConfig(raw json.RawMessage) (any, error) {
+	var cfg SignInConfig
+	if err := decodeStrict(raw, &cfg); err != nil {
+		return nil, taskErrorf("签到配置格式错误：%v", err)
+	}
+	if cfg.Latitude < -90 || cfg.Latitude > 90 || cfg.Longitude < -180 || cfg.Longitude > 180 {
+		return nil, taskError("签到位置经纬度无效")
+	}
+	if cfg.Radius <= 0 || cfg.Radius > 5000 {
+		return nil, taskError("签到范围应在 1-5000 米之间")
+	}
+	return cfg, nil
+}
+
+func (signInKind) validateSubmission(config any, raw json.RawMessage) (any, error) {
+	cfg := config.(SignInConfig)
+	var sub SignInSubmission
+	if err := decodeStrict(raw, &sub); err != nil {
+		return nil, taskErrorf("提交数据格式错误：%v", err)
+	}
+	if cfg.RequirePhoto && !isHTTPURL(sub.PhotoURL) {
+		return nil, taskError("请上传签到照片")
+	}
+	sub.Distance = utils.Distance(cfg.Latitude, cfg.Longitude, sub.Latitude, sub.Longitude)
+	if sub.Distance > cfg.Radius {
+		return nil, taskErrorf("不在签到范围内 (距离 %.0f 米)", sub.Distance)
+	}
+	return sub, nil
+}
+
+// DormCheckConfig 查寝：Items 为需逐项确认的检查项，Buildings 不为空时只能选择其中的楼栋
+type DormCheckConfig struct {
+	Buildings    []string `json:"buildings"`
+	Items        []string `json:"items"`
+	RequirePhoto bool     `json:"require_photo"`
+}
+
+type DormCheckSubmission struct {
+	Building string          `json:"building"`
+	Room     string          `json:"room"`
+	Items    map[string]bool `json:"items"`
+	PhotoURL string          `json:"photo_url,omitempty"`
+	Remark   string          `json:"remark,omitempty"`
+}
+
+type dormCheckKind struct{}
+
+func (dormCheckKind) parseConfig(raw json.RawMessage) (any, error) {
+	var cfg DormCheckConfig
+	if err := decodeStrict(raw, &cfg); err != nil {
+		return nil, taskErrorf("查寝配置格式错误：%v", err)
+	}
+	if len(cfg.Items) > 20 {
+		return nil, taskError("检查项最多 20 个")
+	}
+	return cfg, nil
+}
+
+func (dormCheckKind) validateSubmission(config any, raw json.RawMessage) (any, error) {
+	cfg := config.(DormCheckConfig)
+	var sub DormCheckSubmission
+	if err := decodeStrict(raw, &sub); err != nil {
+		return nil, taskErrorf("提交数据格式错误：%v", err)
+	}
+	if sub.Building == "" || sub.Room == "" {
+		return nil, taskError("请填写楼栋和寝室号")
+	}
+	if len(cfg.Buildings) > 0 && !slices.Contains(cfg.Buildings, sub.Building) {
+		return nil, taskError("楼栋不在本次查寝范围内")
+	}
+	for _, item := range cfg.Items {
+		if _, ok := sub.Items[item]; !ok {
+			return nil, taskError("请完成检查项：" + item)
+		}
+	}
+	for item := range sub.Items {
+		if !slices.Contains(cfg.Items, item) {
+			return nil, taskError("未知的检查项：" + item)
+		}
+	}
+	if cfg.RequirePhoto && !isHTTPURL(sub.PhotoURL) {
+		return nil, taskError("请上传寝室照片")
+	}
+	return sub, nil
+}
+
+// FileSubmissionConfig 文件提交：Extensions 为空表示不限类型
+type FileSubmissionConfig struct {
+	MaxFiles   int      `json:"max_files"`
+	Extensions []string `json:"extensions"`
+	MaxSizeMB  int      `json:"max_size_mb"`
+}
+
+type SubmittedFile struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+type FileSubmission struct {
+	Files  []SubmittedFile `json:"files"`
+	Remark string          `json:"remark,omitempty"`
+}
+
+type fileSubmissionKind struct{}
+
+func (fileSubmissionKind) parseConfig(raw json.RawMessage) (any, error) {
+	var cfg FileSubmissionConfig
+	if err := decodeStrict(raw, &cfg); err != nil {
+		return nil, taskErrorf("文件提交配置格式错误：%v", err)
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = 1
+	}
+	if cfg.MaxFiles < 0 || cfg.MaxFiles > 10 {
+		return nil, taskError("文件数量上限应在 1-10 之间")
+	}
+	if cfg.MaxSizeMB < 0 {
+		return nil, taskError("文件大小上限无效")
+	}
+	for i, ext := range cfg.Extensions {
+		cfg.Extensions[i] = strings.ToLower(strings.TrimPrefix(ext, "."))
+	}
+	return cfg, nil
+}
+
+func (fileSubmissionKind) validateSubmission(config any, raw json.RawMessage) (any, error) {
+	cfg := config.(FileSubmissionConfig)
+	var sub FileSubmission
+	if err := decodeStrict(raw, &sub); err != nil {
+		return nil, taskErrorf("提交数据格式错误：%v", err)
+	}
+	if len(sub.Files) == 0 {
+		return nil, taskError("请上传文件")
+	}
+	if len(sub.Files) > cfg.MaxFiles {
+		return nil, taskErrorf("最多上传 %d 个文件", cfg.MaxFiles)
+	}
+	for _, f := range sub.Files {
+		if f.Name == "" || !isHTTPURL(f.URL) {
+			return nil, taskError("文件信息无效")
+		}
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))
+		if len(cfg.Extensions) > 0 && !slices.Contains(cfg.Extensions, ext) {
+			return nil, taskError("不支持的文件类型：" + f.Name)
+		}
+		if cfg.MaxSizeMB > 0 && f.Size > int64(cfg.MaxSizeMB)<<20 {
+			return nil, taskErrorf("文件超过 %d MB：%s", cfg.MaxSizeMB, f.Name)
+		}
+	}
+	return sub, nil
+}
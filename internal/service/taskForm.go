@@ -0,0 +1,474 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unihub/internal/repo"
+	"unihub/internal/utils"
+)
+
+// SurveyConfig 问卷表单
+type SurveyConfig struct {
+	Fields []FormField `json:"fields"`
+}
+
+// FormField 表单字段。Min/Max 对 number 限制取值，对 multiple_choice 限制选择个数，对 text 限制字数。
+type FormField struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Type      string          `json:"type"` // single_choice, multiple_choice, text, number, date, file, location
+	Options   []string        `json:"options,omitempty"`
+	Required  bool            `json:"required"`
+	Min       *float64        `json:"min,omitempty"`
+	Max       *float64        `json:"max,omitempty"`
+	MaxFiles  int             `json:"max_files,omitempty"` // file 字段，默认 1
+	VisibleIf *FieldCondition `json:"visible_if,omitempty"`
+}
+
+// FieldCondition 条件显示：前面某个选择题的回答包含 Values 中任一值时才显示本字段
+type FieldCondition struct {
+	Field  string   `json:"field"`
+	Values []string `json:"values"`
+}
+
+// LocationAnswer 位置字段的回答
+type LocationAnswer struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address,omitempty"`
+}
+
+// SurveySubmission 问卷回答，值的类型由字段决定：
+// 选择和文本为 string，多选为 []string，number 为 float64，date 为 "2006-01-02"，file 为 []SubmittedFile，location 为 LocationAnswer
+type SurveySubmission struct {
+	Answers map[string]any `json:"answers"`
+}
+
+var formFieldTypes = []string{"single_choice", "multiple_choice", "text", "number", "date", "file", "location"}
+
+type surveyKind struct{}
+
+func (surveyKind) parseConfig(raw json.RawMessage) (any, error) {
+	var cfg SurveyConfig
+	if err := decodeStrict(raw, &cfg); err != nil {
+		return nil, taskErrorf("问卷配置格式错误：%v", err)
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, taskError("问卷至少需要一个字段")
+	}
+	if len(cfg.Fields) > 100 {
+		return nil, taskError("问卷字段最多 100 个")
+	}
+
+	defined := make(map[string]FormField, len(cfg.Fields))
+	for i := range cfg.Fields {
+		f := &cfg.Fields[i]
+		if f.ID == "" || f.Title == "" {
+			return nil, taskError("字段的 id 和标题不能为空")
+		}
+		if _, ok := defined[f.ID]; ok {
+			return nil, taskError("字段 id 重复：" + f.ID)
+		}
+		if !slices.Contains(formFieldTypes, f.Type) {
+			return nil, taskError("未知的字段类型：" + f.Type)
+		}
+		if (f.Type == "single_choice" || f.Type == "multiple_choice") && len(f.Options) < 2 {
+			return nil, taskError("选择题至少需要两个选项：" + f.Title)
+		}
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return nil, taskError("最小值不能大于最大值：" + f.Title)
+		}
+		if f.Type == "file" {
+			if f.MaxFiles == 0 {
+				f.MaxFiles = 1
+			}
+			if f.MaxFiles < 0 || f.MaxFiles > 10 {
+				return nil, taskError("文件数量上限应在 1-10 之间：" + f.Title)
+			}
+		}
+		if c := f.VisibleIf; c != nil {
+			ref, ok := defined[c.Field]
+			if !ok {
+				return nil, taskError("显示条件只能引用前面的字段：" + f.Title)
+			}
+			if ref.Type != "single_choice" && ref.Type != "multiple_choice" {
+				return nil, taskError("显示条件只能引用选择题：" + f.Title)
+			}
+			if len(c.Values) == 0 {
+				return nil, taskError("显示条件至少需要一个选项：" + f.Title)
+			}
+			for _, v := range c.Values {
+				if !slices.Contains(ref.Options, v) {
+					return nil, taskError("显示条件的选项不存在：" + v)
+				}
+			}
+		}
+		defined[f.ID] = *f
+	}
+	return cfg, nil
+}
+
+func (surveyKind) validateSubmission(config any, raw json.RawMessage) (any, error) {
+	cfg := config.(SurveyConfig)
+	var sub struct {
+		Answers map[string]json.RawMessage `json:"answers"`
+	}
+	if err := decodeStrict(raw, &sub); err != nil {
+		return nil, taskErrorf("提交数据格式错误：%v", err)
+	}
+
+	known := make(map[string]bool, len(cfg.Fields))
+	answers := make(map[string]any, len(cfg.Fields))
+	for _, f := range cfg.Fields {
+		known[f.ID] = true
+		// 隐藏字段的回答直接丢弃
+		if !fieldVisible(f, answers) {
+			continue
+		}
+		value, err := parseAnswer(f, sub.Answers[f.ID])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			if f.Required {
+				return nil, taskError("请填写：" + f.Title)
+			}
+			continue
+		}
+		answers[f.ID] = value
+	}
+	for id := range sub.Answers {
+		if !known[id] {
+			return nil, taskError("未知的字段：" + id)
+		}
+	}
+	return SurveySubmission{Answers: answers}, nil
+}
+
+// fieldVisible 字段是否满足显示条件，answers 为前面字段已校验的回答
+func fieldVisible(f FormField, answers map[string]any) bool {
+	if f.VisibleIf == nil {
+		return true
+	}
+	switch v := answers[f.VisibleIf.Field].(type) {
+	case string:
+		return slices.Contains(f.VisibleIf.Values, v)
+	case []string:
+		for _, item := range v {
+			if slices.Contains(f.VisibleIf.Values, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAnswer 按字段类型解析并校验回答，未回答时返回 nil
+func parseAnswer(f FormField, raw json.RawMessage) (any, error) {
+	s := strings.TrimSpace(string(raw))
+	if s == "" || s == "null" || s == `""` || s == "[]" {
+		return nil, nil
+	}
+	invalid := taskError("回答格式错误：" + f.Title)
+
+	switch f.Type {
+	case "single_choice":
+		var v string
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if !slices.Contains(f.Options, v) {
+			return nil, taskError("选项无效：" + f.Title)
+		}
+		return v, nil
+	case "multiple_choice":
+		var v []string
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		for _, item := range v {
+			if !slices.Contains(f.Options, item) {
+				return nil, taskError("选项无效：" + f.Title)
+			}
+		}
+		if err := checkRange(f, float64(len(v)), "选择个数"); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "text":
+		var v string
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if err := checkRange(f, float64(len([]rune(v))), "字数"); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "number":
+		var v float64
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if err := checkRange(f, v, "数值"); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "date":
+		var v string
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return nil, taskError("日期格式应为 YYYY-MM-DD：" + f.Title)
+		}
+		return v, nil
+	case "file":
+		var v []SubmittedFile
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if len(v) > f.MaxFiles {
+			return nil, taskErrorf("最多上传 %d 个文件：%s", f.MaxFiles, f.Title)
+		}
+		for _, file := range v {
+			if file.Name == "" || !isHTTPURL(file.URL) {
+				return nil, taskError("文件信息无效：" + f.Title)
+			}
+		}
+		return v, nil
+	case "location":
+		var v LocationAnswer
+		if json.Unmarshal(raw, &v) != nil {
+			return nil, invalid
+		}
+		if v.Latitude < -90 || v.Latitude > 90 || v.Longitude < -180 || v.Longitude > 180 {
+			return nil, taskError("位置无效：" + f.Title)
+		}
+		return v, nil
+	}
+	return nil, invalid
+}
+
+func checkRange(f FormField, v float64, name string) error {
+	if f.Min != nil && v < *f.Min {
+		return taskErrorf("%s不能小于 %g：%s", name, *f.Min, f.Title)
+	}
+	if f.Max != nil && v > *f.Max {
+		return taskErrorf("%s不能大于 %g：%s", name, *f.Max, f.Title)
+	}
+	return nil
+}
+
+// OptionCount 选项 (或日期) 的回答人数
+type OptionCount struct {
+	Option string `json:"option"`
+	Count  int    `json:"count"`
+}
+
+type NumberStats struct {
+	Sum float64 `json:"sum"`
+	Avg float64 `json:"avg"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// FieldResult 单个字段的汇总结果
+type FieldResult struct {
+	ID        string           `json:"id"`
+	Title     string           `json:"title"`
+	Type      string           `json:"type"`
+	Answered  int              `json:"answered"`
+	Options   []OptionCount    `json:"options,omitempty"`   // 选择题和日期
+	Number    *NumberStats     `json:"number,omitempty"`    // 数值
+	Texts     []string         `json:"texts,omitempty"`     // 文本
+	Files     int              `json:"files,omitempty"`     // 文件总数
+	Locations []LocationAnswer `json:"locations,omitempty"` // 位置
+}
+
+// SurveyResults 问卷汇总
+type SurveyResults struct {
+	Submitted int           `json:"submitted"`
+	Fields    []FieldResult `json:"fields"`
+}
+
+// surveyResponses 问卷配置及全部回答 (仅发布者)
+func (s *taskService) surveyResponses(userID uint, taskUUID string) (SurveyConfig, []repo.TaskSubmission, []map[string]any, error) {
+	task, err := s.createdTask(userID, taskUUID)
+	if err != nil {
+		return SurveyConfig{}, nil, nil, err
+	}
+	if task.Type != "survey" {
+		return SurveyConfig{}, nil, nil, taskError("该任务不是问卷")
+	}
+	parsed, err := surveyKind{}.parseConfig(json.RawMessage(task.Config))
+	if err != nil {
+		return SurveyConfig{}, nil, nil, err
+	}
+	cfg := parsed.(SurveyConfig)
+
+	rows, err := s.taskRepo.ListTaskSubmissions(task.ID)
+	if err != nil {
+		return SurveyConfig{}, nil, nil, err
+	}
+	answers := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		answers[i] = decodeSurveyAnswers(cfg, row.Data)
+	}
+	return cfg, rows, answers, nil
+}
+
+// decodeSurveyAnswers 把保存的回答还原为字段对应的类型
+func decodeSurveyAnswers(cfg SurveyConfig, data string) map[string]any {
+	var stored struct {
+		Answers map[string]json.RawMessage `json:"answers"`
+	}
+	_ = json.Unmarshal([]byte(data), &stored)
+	answers := make(map[string]any, len(stored.Answers))
+	for _, f := range cfg.Fields {
+		if raw, ok := stored.Answers[f.ID]; ok {
+			if v, err := parseAnswer(f, raw); err == nil && v != nil {
+				answers[f.ID] = v
+			}
+		}
+	}
+	return answers
+}
+
+// GetSurveyResults 按字段汇总问卷结果
+func (s *taskService) GetSurveyResults(userID uint, taskUUID string) (*SurveyResults, error) {
+	cfg, _, answers, err := s.surveyResponses(userID, taskUUID)
+	if err != nil {
+		return nil, err
+	}
+	return summarizeSurvey(cfg, answers), nil
+}
+
+// summarizeSurvey 按字段汇总已解析的回答
+func summarizeSurvey(cfg SurveyConfig, answers []map[string]any) *SurveyResults {
+	results := &SurveyResults{Submitted: len(answers), Fields: make([]FieldResult, len(cfg.Fields))}
+	for i, f := range cfg.Fields {
+		r := FieldResult{ID: f.ID, Title: f.Title, Type: f.Type}
+		counts := map[string]int{}
+		for _, a := range answers {
+			v, ok := a[f.ID]
+			if !ok {
+				continue
+			}
+			r.Answered++
+			switch v := v.(type) {
+			case string:
+				if f.Type == "text" {
+					r.Texts = append(r.Texts, v)
+				} else {
+					counts[v]++
+				}
+			case []string:
+				for _, item := range v {
+					counts[item]++
+				}
+			case float64:
+				if r.Number == nil {
+					r.Number = &NumberStats{Min: math.Inf(1), Max: math.Inf(-1)}
+				}
+				r.Number.Sum += v
+				r.Number.Min = math.Min(r.Number.Min, v)
+				r.Number.Max = math.Max(r.Number.Max, v)
+			case []SubmittedFile:
+				r.Files += len(v)
+			case LocationAnswer:
+				r.Locations = append(r.Locations, v)
+			}
+		}
+		if r.Number != nil {
+			r.Number.Avg = r.Number.Sum / float64(r.Answered)
+		}
+		switch f.Type {
+		case "single_choice", "multiple_choice":
+			for _, o := range f.Options {
+				r.Options = append(r.Options, OptionCount{Option: o, Count: counts[o]})
+			}
+		case "date":
+			dates := make([]string, 0, len(counts))
+			for d := range counts {
+				dates = append(dates, d)
+			}
+			slices.Sort(dates)
+			for _, d := range dates {
+				r.Options = append(r.Options, OptionCount{Option: d, Count: counts[d]})
+			}
+		}
+		results.Fields[i] = r
+	}
+	return results
+}
+
+// ExportSurveyResponses 导出问卷：原始回答一人一行，另附各字段汇总
+func (s *taskService) ExportSurveyResponses(userID uint, taskUUID string) (string, error) {
+	cfg, rows, answers, err := s.surveyResponses(userID, taskUUID)
+	if err != nil {
+		return "", err
+	}
+
+	raw := utils.Sheet{Name: "回答", Headers: []string{"学号", "姓名", "提交时间"}}
+	for _, f := range cfg.Fields {
+		raw.Headers = append(raw.Headers, f.Title)
+	}
+	for i, row := range rows {
+		studentNo := ""
+		if row.StudentNo != nil {
+			studentNo = *row.StudentNo
+		}
+		line := []interface{}{studentNo, row.Nickname, row.SubmittedAt.Format("2006-01-02 15:04:05")}
+		for _, f := range cfg.Fields {
+			line = append(line, formatAnswer(answers[i][f.ID]))
+		}
+		raw.Rows = append(raw.Rows, line)
+	}
+
+	results := summarizeSurvey(cfg, answers)
+	summary := utils.Sheet{Name: "汇总", Headers: []string{"字段", "类型", "项目", "数值"}}
+	for _, r := range results.Fields {
+		summary.Rows = append(summary.Rows, []interface{}{r.Title, r.Type, "回答人数", r.Answered})
+		for _, o := range r.Options {
+			summary.Rows = append(summary.Rows, []interface{}{r.Title, r.Type, o.Option, o.Count})
+		}
+		if r.Number != nil {
+			summary.Rows = append(summary.Rows,
+				[]interface{}{r.Title, r.Type, "平均值", r.Number.Avg},
+				[]interface{}{r.Title, r.Type, "最小值", r.Number.Min},
+				[]interface{}{r.Title, r.Type, "最大值", r.Number.Max})
+		}
+		if r.Type == "file" {
+			summary.Rows = append(summary.Rows, []interface{}{r.Title, r.Type, "文件数", r.Files})
+		}
+	}
+
+	return utils.ExportSheetsToExcel([]utils.Sheet{raw, summary}, "survey_responses")
+}
+
+// formatAnswer 回答在表格中的显示文本
+func formatAnswer(v any) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, "、")
+	case []SubmittedFile:
+		urls := make([]string, len(v))
+		for i, f := range v {
+			urls[i] = f.URL
+		}
+		return strings.Join(urls, "\n")
+	case LocationAnswer:
+		text := fmt.Sprintf("%.6f,%.6f", v.Latitude, v.Longitude)
+		if v.Address != "" {
+			text += " " + v.Address
+		}
+		return text
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+)
+
+// ReviewSubmissionRequest 审阅提交：accept 通过，return 退回重新提交
+type ReviewSubmissionRequest struct {
+	Action   string
+	Feedback string
+	Score    *float64
+	// Version 审阅时看到的提交版本，不为 0 时与当前版本不一致则拒绝
+	Version int
+}
+
+// TaskSubmissionView 提交记录及解析后的数据
+type TaskSubmissionView struct {
+	repo.TaskSubmission
+	Data json.RawMessage `json:"data"`
+}
+
+// TaskRecordVersionView 历史版本及解析后的数据
+type TaskRecordVersionView struct {
+	model.TaskRecordVersion
+	Data json.RawMessage
+}
+
+// createdTask 发布者查看自己的任务
+func (s *taskService) createdTask(userID uint, taskUUID string) (*model.Task, error) {
+	task, err := s.taskRepo.GetTaskByUUID(taskUUID)
+	if err != nil {
+		return nil, taskError("任务不存在")
+	}
+	if task.CreatorID != userID {
+		return nil, taskError("无权查看该任务")
+	}
+	return task, nil
+}
+
+// ListSubmissions 任务的全部提交及审阅状态 (发布者)
+func (s *taskService) ListSubmissions(userID uint, taskUUID string) ([]TaskSubmissionView, error) {
+	task, err := s.createdTask(userID, taskUUID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.taskRepo.ListTaskSubmissions(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]TaskSubmissionView, len(rows))
+	for i, row := range rows {
+		views[i] = TaskSubmissionView{TaskSubmission: row, Data: rawJSON(row.Data)}
+	}
+	return views, nil
+}
+
+// ReviewSubmission 审阅学生的提交。退回须填写反馈，学生据此重新提交；评分可选。
+func (s *taskService) ReviewSubmission(userID uint, taskUUID string, recordID uint, req ReviewSubmissionRequest) (*model.TaskRecord, error) {
+	task, err := s.ownedTask(userID, taskUUID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.taskRepo.GetTaskRecordByID(recordID)
+	if err != nil || record.TaskID != task.ID {
+		return nil, taskError("提交记录不存在")
+	}
+	if req.Version != 0 && req.Version != record.Version {
+		return nil, taskError("学生已重新提交，请刷新后再审阅")
+	}
+
+	switch req.Action {
+	case "accept":
+		record.Status = "accepted"
+	case "return":
+		if strings.TrimSpace(req.Feedback) == "" {
+			return nil, taskError("退回时请填写反馈意见")
+		}
+		record.Status = "returned"
+	default:
+		return nil, taskError("未知的审阅操作：" + req.Action)
+	}
+	if req.Score != nil && (*req.Score < 0 || math.IsNaN(*req.Score) || math.IsInf(*req.Score, 0)) {
+		return nil, taskError("评分不能为负数")
+	}
+
+	now := time.Now()
+	record.Feedback = strings.TrimSpace(req.Feedback)
+	record.Score = req.Score
+	record.ReviewerID = &userID
+	record.ReviewedAt = &now
+
+	reviewed, err := s.taskRepo.ReviewTaskRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewed {
+		return nil, taskError("学生已重新提交，请刷新后再审阅")
+	}
+	return record, nil
+}
+
+// ListRecordVersions 提交的历史版本，发布者和提交的学生可见
+func (s *taskService) ListRecordVersions(userID uint, taskUUID string, recordID uint) ([]TaskRecordVersionView, error) {
+	task, err := s.taskRepo.GetTaskByUUID(taskUUID)
+	if err != nil {
+		return nil, taskError("任务不存在")
+	}
+	record, err := s.taskRepo.GetTaskRecordByID(recordID)
+	if err != nil || record.TaskID != task.ID {
+		return nil, taskError("提交记录不存在")
+	}
+	if task.CreatorID != userID && record.StudentID != userID {
+		return nil, taskError("无权查看该任务")
+	}
+
+	versions, err := s.taskRepo.ListTaskRecordVersions(record.ID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]TaskRecordVersionView, len(versions))
+	for i, v := range versions {
+		views[i] = TaskRecordVersionView{TaskRecordVersion: v, Data: rawJSON(v.Data)}
+	}
+	return views, nil
+}
+
+// resubmit 重新提交被退回的记录，旧版本及其审阅结果保留在历史版本中；是否逾期按本次提交重新判断
+func (s *taskService) resubmit(record *model.TaskRecord, data string, late bool) error {
+	prev := model.TaskRecordVersion{
+		RecordID:    record.ID,
+		Version:     record.Version,
+		Status:      record.Status,
+		Data:        record.Data,
+		Feedback:    record.Feedback,
+		Score:       record.Score,
+		ReviewerID:  record.ReviewerID,
+		ReviewedAt:  record.ReviewedAt,
+		SubmittedAt: record.CreatedAt,
+	}
+	record.Status = "submitted"
+	record.Data = data
+	record.Late = late
+	record.CreatedAt = time.Now()
+
+	resubmitted, err := s.taskRepo.ResubmitTaskRecord(record, &prev)
+	if err != nil {
+		return err
+	}
+	if !resubmitted {
+		return taskError("提交状态已变化，请刷新后重试")
+	}
+	return nil
+}
@@ -1,13 +1,30 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"time"
+	"unihub/internal/config"
 	"unihub/internal/model"
 	"unihub/internal/repo"
 )
 
+// TaskError 任务的业务错误 (校验失败、无权限、不存在等)，其余错误为数据库等内部错误
+type TaskError struct {
+	msg string
+}
+
+func (e *TaskError) Error() string { return e.msg }
+
+func taskError(msg string) error {
+	return &TaskError{msg: msg}
+}
+
+func taskErrorf(format string, args ...any) error {
+	return &TaskError{msg: fmt.Sprintf(format, args...)}
+}
+
 type CreateTaskRequest struct {
 	Title       string
 	Type        string
@@ -16,26 +33,85 @@ type CreateTaskRequest struct {
 	TargetID    uint
 	CreatorID   uint
 	Deadline    time.Time
-	Config      any
+	Config      json.RawMessage
+	LatePolicy  string // 为空时为 reject
+	LateCutoff  *time.Time
+}
+
+// UpdateTaskRequest 修改任务，类型和发布对象不可修改
+type UpdateTaskRequest struct {
+	Title       string
+	Description string
+	Deadline    time.Time
+	Config      json.RawMessage
+	LatePolicy  string
+	LateCutoff  *time.Time
+}
+
+// TaskView 任务及解析后的配置
+type TaskView struct {
+	model.Task
+	// Config 覆盖 model.Task 中的 JSON 字符串
+	Config json.RawMessage
+}
+
+// TaskRecordView 提交记录及解析后的数据
+type TaskRecordView struct {
+	model.TaskRecord
+	Data json.RawMessage
+}
+
+// TaskDetail 任务详情：学生附带分派状态和自己的提交，发布者附带提交人数
+type TaskDetail struct {
+	TaskView
+	Assignment     *model.TaskAssignment `json:"assignment,omitempty"`
+	Record         *TaskRecordView       `json:"record,omitempty"`
+	SubmittedCount *int64                `json:"submitted_count,omitempty"`
 }
 
 type TaskService interface {
 	CreateTask(req CreateTaskRequest) (*model.Task, error)
-	GetMyTasks(studentID uint) ([]model.Task, error)
-	SubmitTask(studentID uint, taskUUID string, data any) error
+	ListCreatedTasks(creatorID uint) ([]TaskView, error)
+	GetMyTasks(studentID uint) ([]MyTaskView, error)
+	GetTask(userID uint, taskUUID string) (*TaskDetail, error)
+	UpdateTask(userID uint, taskUUID string, req UpdateTaskRequest) (*model.Task, error)
+	DeleteTask(userID uint, taskUUID string) error
+	SubmitTask(studentID uint, taskUUID string, data json.RawMessage) error
+	GetSurveyResults(userID uint, taskUUID string) (*SurveyResults, error)
+	ExportSurveyResponses(userID uint, taskUUID string) (string, error)
+	ListSubmissions(userID uint, taskUUID string) ([]TaskSubmissionView, error)
+	ReviewSubmission(userID uint, taskUUID string, recordID uint, req ReviewSubmissionRequest) (*model.TaskRecord, error)
+	ListRecordVersions(userID uint, taskUUID string, recordID uint) ([]TaskRecordVersionView, error)
+	GetCompletion(userID uint, taskUUID string) (*TaskCompletion, error)
+	RemindMissing(userID uint, taskUUID string) (int, error)
+	ExportCompletion(userID uint, taskUUID string) (string, error)
+	AssignLateJoiner(studentID uint, targetType string, targetID uint) error
+	BackfillAssignments() error
 }
 
 type taskService struct {
-	taskRepo repo.TaskRepository
-	orgRepo  repo.OrgRepository
-	userRepo repo.UserRepository
+	taskRepo  repo.TaskRepository
+	orgRepo   repo.OrgRepository
+	userRepo  repo.UserRepository
+	notifRepo repo.NotificationRepository
+	templates TemplateService
+	// lateJoiner/lateJoinerGrace 任务发布后才加入发布对象的学生的处理方式，见 config.Task
+	lateJoiner      string
+	lateJoinerGrace time.Duration
+	// remindInterval 同一任务两次催交的最短间隔
+	remindInterval time.Duration
 }
 
-func NewTaskService(taskRepo repo.TaskRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository) TaskService {
+func NewTaskService(taskRepo repo.TaskRepository, orgRepo repo.OrgRepository, userRepo repo.UserRepository, notifRepo repo.NotificationRepository, templates TemplateService, cfg *config.Config) TaskService {
 	return &taskService{
-		taskRepo: taskRepo,
-		orgRepo:  orgRepo,
-		userRepo: userRepo,
+		taskRepo:        taskRepo,
+		orgRepo:         orgRepo,
+		userRepo:        userRepo,
+		notifRepo:       notifRepo,
+		templates:       templates,
+		lateJoiner:      lateJoinerPolicy(cfg),
+		lateJoinerGrace: cfg.Task.LateJoinerGrace,
+		remindInterval:  cfg.Task.RemindInterval,
 	}
 }
 
@@ -66,11 +142,22 @@ func (s *taskService) CreateTask(req CreateTaskRequest) (*model.Task, error) {
 	}
 
 	if !hasPerm {
-		return nil, errors.New("没有权限向该目标发布任务")
+		return nil, taskError("没有权限向该目标发布任务")
+	}
+	if !req.Deadline.After(time.Now()) {
+		return nil, taskError("截止时间必须晚于当前时间")
 	}
 
-	configBytes, _ := json.Marshal(req.Config)
+	config, err := normalizeTaskConfig(req.Type, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	policy, cutoff, err := normalizeLatePolicy(req.LatePolicy, req.LateCutoff, req.Deadline)
+	if err != nil {
+		return nil, err
+	}
 
+	// UUID 由 Task.BeforeCreate 生成
 	task := model.Task{
 		Title:       req.Title,
 		Type:        req.Type,
@@ -79,88 +166,263 @@ func (s *taskService) CreateTask(req CreateTaskRequest) (*model.Task, error) {
 		TargetType:  req.TargetType,
 		TargetID:    req.TargetID,
 		Deadline:    req.Deadline,
-		Config:      string(configBytes),
+		Config:      config,
+		LatePolicy:  policy,
+		LateCutoff:  cutoff,
+		Assigned:    true,
+	}
+	students, err := s.targetStudents(&task)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(students))
+	for i, u := range students {
+		ids[i] = u.ID
+	}
+	if err := s.taskRepo.CreateTask(&task, newAssignments(ids, task.Deadline, time.Now())); err != nil {
+		return nil, err
 	}
 
-	// UUID generation loop handling?
-	// Assuming BeforeCreate hook or just set it here using uuid package if needed.
-	// But `model` has `UUID uuid.UUID \`gorm:"type:char(36);uniqueIndex"\``
-	// GORM hooks are better. Or manual assignment.
-	// Let's rely on GORM hook which is commented out in models.go or add manual assignment if hook is not active.
-	// The provided models.go had BeforeCreate hook commented out. Ideally I should uncomment it or set it here.
-
-	// Since I cannot edit GORM hooks easily without restarting (and models.go edit was partial content in previous read),
-	// I'll check if uuid is handled in `models.go`.
+	return &task, nil
+}
 
-	// The user provided `internal/model/models.go` shows BeforeCreate commented out.
-	// I'll assume I should set it manually.
-	// "github.com/google/uuid" is imported in models.go
+// normalizeLatePolicy 校验逾期策略；只有 cutoff 策略保留最终截止时间
+func normalizeLatePolicy(policy string, cutoff *time.Time, deadline time.Time) (string, *time.Time, error) {
+	switch policy {
+	case "", "reject":
+		return "reject", nil, nil
+	case "accept_late":
+		return policy, nil, nil
+	case "cutoff":
+		if cutoff == nil || !cutoff.After(deadline) {
+			return "", nil, taskError("最终截止时间必须晚于截止时间")
+		}
+		return policy, cutoff, nil
+	}
+	return "", nil, taskError("未知的逾期策略：" + policy)
+}
 
-	// Wait, I cannot import "github.com/google/uuid" directly in service if go.mod doesn't support it or I need to add import.
-	// models.go has it.
+// normalizeTaskConfig 按任务类型校验配置，返回规范化后的 JSON
+func normalizeTaskConfig(taskType string, raw json.RawMessage) (string, error) {
+	kind, ok := taskKinds[taskType]
+	if !ok {
+		return "", taskError("未知的任务类型：" + taskType)
+	}
+	cfg, err := kind.parseConfig(raw)
+	if err != nil {
+		return "", err
+	}
+	data, _ := json.Marshal(cfg)
+	return string(data), nil
+}
 
-	// I'll skip UUID generation here and assume DB handles it or GORM hook is active (I'll enable it later if I can).
-	// Actually, let's just generate it using a helper in utils if available, or just ignore for now and assume logic exists somewhere or fail.
-	// In the original handler code: `task.UUID` was not set explicitly, probably relying on `gorm:"default:uuid()"` or hook.
-	// Wait, the original code had `t.UUID = uuid.New()` in `BeforeCreate`.
+// ValidateTaskSubmission 按任务类型解析配置并校验一次提交，返回规范化后的提交内容
+func ValidateTaskSubmission(taskType string, config, data json.RawMessage) (any, error) {
+	kind, ok := taskKinds[taskType]
+	if !ok {
+		return nil, taskError("未知的任务类型：" + taskType)
+	}
+	cfg, err := kind.parseConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return kind.validateSubmission(cfg, data)
+}
 
-	// I will check models.go again.
+func (s *taskService) ListCreatedTasks(creatorID uint) ([]TaskView, error) {
+	tasks, err := s.taskRepo.ListTasksByCreator(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	return toTaskViews(tasks), nil
+}
 
-	if err := s.taskRepo.CreateTask(&task); err != nil {
+// GetMyTasks 分派给学生的任务及各自的完成状态
+func (s *taskService) GetMyTasks(studentID uint) ([]MyTaskView, error) {
+	rows, err := s.taskRepo.ListAssignedTasks(studentID)
+	if err != nil {
 		return nil, err
 	}
+	views := make([]MyTaskView, len(rows))
+	for i, row := range rows {
+		views[i] = MyTaskView{
+			TaskView:   TaskView{Task: row.Task, Config: rawJSON(row.Config)},
+			Status:     row.AssignmentStatus,
+			Late:       row.Late,
+			AssignedAt: row.AssignedAt,
+			DueAt:      row.DueAt,
+		}
+	}
+	return views, nil
+}
 
-	return &task, nil
+func toTaskViews(tasks []model.Task) []TaskView {
+	views := make([]TaskView, len(tasks))
+	for i, t := range tasks {
+		views[i] = TaskView{Task: t, Config: rawJSON(t.Config)}
+	}
+	return views
 }
 
-func (s *taskService) GetMyTasks(studentID uint) ([]model.Task, error) {
-	targets := []model.Target{}
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s)
+}
 
-	// Student specific tasks
-	targets = append(targets, model.Target{Type: "student", ID: studentID})
+// GetTask 任务详情，仅发布者和任务对象可见
+func (s *taskService) GetTask(userID uint, taskUUID string) (*TaskDetail, error) {
+	task, err := s.taskRepo.GetTaskByUUID(taskUUID)
+	if err != nil {
+		return nil, taskError("任务不存在")
+	}
+	detail := &TaskDetail{TaskView: TaskView{Task: *task, Config: rawJSON(task.Config)}}
 
-	// Dept tasks
-	deptID, err := s.orgRepo.GetStudentDepartmentID(studentID)
-	if err == nil && deptID != 0 {
-		targets = append(targets, model.Target{Type: "dept", ID: deptID})
+	if task.CreatorID == userID {
+		count, err := s.taskRepo.CountTaskRecords(task.ID)
+		if err != nil {
+			return nil, err
+		}
+		detail.SubmittedCount = &count
+		return detail, nil
+	}
+	assignment, err := s.taskRepo.GetAssignment(task.ID, userID)
+	if err != nil {
+		return nil, taskError("任务不存在")
+	}
+	detail.Assignment = assignment
+	if record, err := s.taskRepo.GetTaskRecord(task.ID, userID); err == nil {
+		detail.Record = &TaskRecordView{TaskRecord: *record, Data: rawJSON(record.Data)}
+	}
+	return detail, nil
+}
+
+func (s *taskService) ownedTask(userID uint, taskUUID string) (*model.Task, error) {
+	task, err := s.taskRepo.GetTaskByUUID(taskUUID)
+	if err != nil {
+		return nil, taskError("任务不存在")
+	}
+	if task.CreatorID != userID {
+		return nil, taskError("无权修改该任务")
+	}
+	return task, nil
+}
+
+// UpdateTask 修改任务；已有学生提交后不能再修改配置
+func (s *taskService) UpdateTask(userID uint, taskUUID string, req UpdateTaskRequest) (*model.Task, error) {
+	task, err := s.ownedTask(userID, taskUUID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Class tasks
-	classIDs, err := s.orgRepo.GetStudentClassIDs(studentID)
-	if err == nil {
-		for _, cid := range classIDs {
-			targets = append(targets, model.Target{Type: "class", ID: cid})
+	if len(req.Config) > 0 {
+		config, err := normalizeTaskConfig(task.Type, req.Config)
+		if err != nil {
+			return nil, err
 		}
+		if !jsonEqual(config, task.Config) {
+			count, err := s.taskRepo.CountTaskRecords(task.ID)
+			if err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				return nil, taskError("已有学生提交，不能修改任务配置")
+			}
+			task.Config = config
+		}
+	}
+	if !req.Deadline.Equal(task.Deadline) && !req.Deadline.After(time.Now()) {
+		return nil, taskError("截止时间必须晚于当前时间")
+	}
+	policy, cutoff, err := normalizeLatePolicy(req.LatePolicy, req.LateCutoff, req.Deadline)
+	if err != nil {
+		return nil, err
 	}
+	prevDeadline := task.Deadline
+	task.Title = req.Title
+	task.Description = req.Description
+	task.Deadline = req.Deadline
+	task.LatePolicy = policy
+	task.LateCutoff = cutoff
 
-	return s.taskRepo.GetTasksForTargets(targets)
+	if err := s.taskRepo.UpdateTask(task, prevDeadline); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func jsonEqual(a, b string) bool {
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, []byte(a)) != nil || json.Compact(&bufB, []byte(b)) != nil {
+		return a == b
+	}
+	return bufA.String() == bufB.String()
 }
 
-func (s *taskService) SubmitTask(studentID uint, taskUUID string, data any) error {
+// DeleteTask 删除任务 (软删除，提交记录保留)
+func (s *taskService) DeleteTask(userID uint, taskUUID string) error {
+	task, err := s.ownedTask(userID, taskUUID)
+	if err != nil {
+		return err
+	}
+	return s.taskRepo.DeleteTask(task.ID)
+}
+
+// submissionWindow 当前能否提交，以及提交是否算逾期；dueAt 为学生的截止时间
+func submissionWindow(task *model.Task, dueAt, now time.Time) (open, late bool) {
+	if !now.After(dueAt) {
+		return true, false
+	}
+	switch task.LatePolicy {
+	case "accept_late":
+		return true, true
+	case "cutoff":
+		return task.LateCutoff != nil && !now.After(*task.LateCutoff), true
+	}
+	return false, true
+}
+
+// SubmitTask 提交任务；截止后按任务的逾期策略处理。
+// 被退回的提交可以重新提交 (不受截止时间限制)，其余情况不能重复提交。
+func (s *taskService) SubmitTask(studentID uint, taskUUID string, data json.RawMessage) error {
 	task, err := s.taskRepo.GetTaskByUUID(taskUUID)
 	if err != nil {
-		return errors.New("任务不存在")
+		return taskError("任务不存在")
+	}
+	assignment, err := s.taskRepo.GetAssignment(task.ID, studentID)
+	if err != nil {
+		return taskError("任务不存在")
 	}
 
-	// Check deadline
-	if time.Now().After(task.Deadline) {
-		return errors.New("任务已截止")
+	existing, err := s.taskRepo.GetTaskRecord(task.ID, studentID)
+	if err == nil && existing.Status != "returned" {
+		return taskError("任务已提交，请勿重复提交")
+	}
+	now := time.Now()
+	open, late := submissionWindow(task, assignment.DueAt, now)
+	if existing == nil && !open {
+		return taskError("任务已截止")
 	}
 
-	// Check duplicate submission
-	if _, err := s.taskRepo.GetTaskRecord(task.ID, studentID); err == nil {
-		// Already exists
-		return errors.New("任务已提交，请勿重复提交")
+	submission, err := ValidateTaskSubmission(task.Type, json.RawMessage(task.Config), data)
+	if err != nil {
+		return err
 	}
+	dataBytes, _ := json.Marshal(submission)
 
-	dataBytes, _ := json.Marshal(data)
+	if existing != nil {
+		return s.resubmit(existing, string(dataBytes), late)
+	}
 
 	record := model.TaskRecord{
 		TaskID:    task.ID,
 		StudentID: studentID,
-		Status:    "completed", // or "late" if deadline logic is different?
+		Status:    "submitted",
 		Data:      string(dataBytes),
-		CreatedAt: time.Now(),
+		Late:      late,
+		Version:   1,
+		CreatedAt: now,
 	}
 
 	return s.taskRepo.CreateTaskRecord(&record)
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"unihub/internal/model"
+	"unihub/internal/repo"
+	"unihub/internal/utils"
+)
+
+// defaultLocale 模板和用户的默认语言
+const defaultLocale = "zh-CN"
+
+// supportedLocales 系统模板提供的语言
+var supportedLocales = map[string]bool{"zh-CN": true, "en-US": true}
+
+// systemTemplates 内置的系统消息模板，启动时写入模板库 (已存在的不覆盖，管理员可修改)
+var systemTemplates = []model.NotificationTemplate{
+	{Key: "ding_created", Locale: "zh-CN", Name: "新打卡任务", Category: "ding",
+		Title: "新的打卡任务：{{ding_title}}", Content: "请在 {{deadline}} 前完成打卡任务。"},
+	{Key: "ding_created", Locale: "en-US", Name: "New check-in task", Category: "ding",
+		Title: "New check-in task: {{ding_title}}", Content: "Please check in before {{deadline}}."},
+	{Key: "task_reminder", Locale: "zh-CN", Name: "任务催交提醒", Category: "task",
+		Title: "请尽快完成任务：{{task_title}}", Content: "任务「{{task_title}}」截止时间为 {{deadline}}，你还没有提交，请尽快完成。"},
+	{Key: "task_reminder", Locale: "en-US", Name: "Task reminder", Category: "task",
+		Title: "Please complete: {{task_title}}", Content: "The task \"{{task_title}}\" is due {{deadline}} and you have not submitted it yet."},
+	{Key: "leave_approved", Locale: "zh-CN", Name: "请假已批准", Category: "leave",
+		Title: "请假已批准：{{leave_type}}", Content: "你 {{start_time}} 至 {{end_time}} 的请假已批准，请按时返校并完成返校签到。"},
+	{Key: "leave_approved", Locale: "en-US", Name: "Leave approved", Category: "leave",
+		Title: "Leave approved: {{leave_type}}", Content: "Your leave from {{start_time}} to {{end_time}} has been approved. Please return on time and check in."},
+	{Key: "leave_rejected", Locale: "zh-CN", Name: "请假未通过", Category: "leave",
+		Title: "请假未通过：{{leave_type}}", Content: "你 {{start_time}} 至 {{end_time}} 的请假未通过审批。"},
+	{Key: "leave_rejected", Locale: "en-US", Name: "Leave rejected", Category: "leave",
+		Title: "Leave rejected: {{leave_type}}", Content: "Your leave from {{start_time}} to {{end_time}} was not approved."},
+	{Key: "leave_overdue_student", Locale: "zh-CN", Name: "逾期未返校提醒 (学生)", Category: "leave",
+		Title: "请尽快返校签到", Content: "你的请假已于 {{end_time}} 结束，已超时 {{overdue}}，请尽快返校并完成返校签到。"},
+	{Key: "leave_overdue_student", Locale: "en-US", Name: "Overdue return reminder (student)", Category: "leave",
+		Title: "Please return and check in", Content: "Your leave ended at {{end_time}} and is {{overdue}} overdue. Please return and check in as soon as possible."},
+	{Key: "leave_overdue_staff", Locale: "zh-CN", Name: "逾期未返校提醒 (教职工)", Category: "leave",
+		Title: "学生逾期未返校：{{student_name}}", Content: "学生 {{student_name}} 的请假已于 {{end_time}} 结束，已超时 {{overdue}} 仍未返校签到。"},
+	{Key: "leave_overdue_staff", Locale: "en-US", Name: "Overdue return alert (staff)", Category: "leave",
+		Title: "Student overdue: {{student_name}}", Content: "{{student_name}}'s leave ended at {{end_time}} and is {{overdue}} overdue without a return check-in."},
+}
+
+type TemplateRequest struct {
+	Key      string
+	Locale   string
+	Name     string
+	Category string
+	Title    string
+	Content  string
+	System   bool // 系统模板，仅管理员可创建
+}
+
+// RenderedMessage 渲染后的消息
+type RenderedMessage struct {
+	Category string
+	Title    string
+	Content  string
+}
+
+// LocalizedMessage 某一语言的渲染结果及使用该语言的接收人
+type LocalizedMessage struct {
+	RenderedMessage
+	UserIDs []uint
+}
+
+type TemplateService interface {
+	ListTemplates(userID uint) ([]model.NotificationTemplate, error)
+	CreateTemplate(userID, roleID uint, req TemplateRequest) (*model.NotificationTemplate, error)
+	UpdateTemplate(userID, roleID, id uint, req TemplateRequest) (*model.NotificationTemplate, error)
+	DeleteTemplate(userID, roleID, id uint) error
+	Resolve(key, locale string, ownerID uint) (*model.NotificationTemplate, error)
+	RenderForUser(key string, userID uint, vars map[string]string) RenderedMessage
+	RenderForUsers(key string, userIDs []uint, vars map[string]string) []LocalizedMessage
+	EnsureSystemTemplates() error
+}
+
+type templateService struct {
+	templateRepo repo.TemplateRepository
+	userRepo     repo.UserRepository
+	prefRepo     repo.PreferenceRepository
+}
+
+func NewTemplateService(templateRepo repo.TemplateRepository, userRepo repo.UserRepository, prefRepo repo.PreferenceRepository) TemplateService {
+	return &templateService{templateRepo: templateRepo, userRepo: userRepo, prefRepo: prefRepo}
+}
+
+// EnsureSystemTemplates 写入缺失的内置系统模板
+func (s *templateService) EnsureSystemTemplates() error {
+	for _, t := range systemTemplates {
+		if _, err := s.templateRepo.FindTemplate(t.Key, t.Locale, 0); err == nil {
+			continue
+		}
+		tmpl := t
+		if err := s.templateRepo.CreateTemplate(&tmpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *templateService) ListTemplates(userID uint) ([]model.NotificationTemplate, error) {
+	return s.templateRepo.ListTemplates(userID)
+}
+
+// canManageSystem 是否可以管理系统模板
+func (s *templateService) canManageSystem(roleID uint) bool {
+	allowed, _ := s.userRepo.CheckPermission(roleID, "template:system")
+	return allowed
+}
+
+func (s *templateService) CreateTemplate(userID, roleID uint, req TemplateRequest) (*model.NotificationTemplate, error) {
+	user, err := s.userRepo.GetUserByIDWithRole(userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.Role.Key == "student" {
+		return nil, errors.New("无权管理通知模板")
+	}
+	ownerID := userID
+	if req.System {
+		if !s.canManageSystem(roleID) {
+			return nil, errors.New("无权管理系统模板")
+		}
+		ownerID = 0
+	}
+	if req.Locale == "" {
+		req.Locale = defaultLocale
+	}
+	if _, err := s.templateRepo.FindTemplate(req.Key, req.Locale, ownerID); err == nil {
+		return nil, errors.New("该模板的此语言版本已存在")
+	}
+
+	tmpl := model.NotificationTemplate{OwnerID: ownerID}
+	applyTemplateRequest(&tmpl, req)
+	if err := s.templateRepo.CreateTemplate(&tmpl); err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+func (s *templateService) editableTemplate(userID, roleID, id uint) (*model.NotificationTemplate, error) {
+	tmpl, err := s.templateRepo.GetTemplateByID(id)
+	if err != nil {
+		return nil, errors.New("模板不存在")
+	}
+	if tmpl.OwnerID == 0 && !s.canManageSystem(roleID) {
+		return nil, errors.New("无权管理系统模板")
+	}
+	if tmpl.OwnerID != 0 && tmpl.OwnerID != userID {
+		return nil, errors.New("模板不存在")
+	}
+	return tmpl, nil
+}
+
+// UpdateTemplate 修改模板内容，Key 和语言不可修改
+func (s *templateService) UpdateTemplate(userID, roleID, id uint, req TemplateRequest) (*model.NotificationTemplate, error) {
+	tmpl, err := s.editableTemplate(userID, roleID, id)
+	if err != nil {
+		return nil, err
+	}
+	req.Key, req.Locale = tmpl.Key, tmpl.Locale
+	applyTemplateRequest(tmpl, req)
+	if err := s.templateRepo.UpdateTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (s *templateService) DeleteTemplate(userID, roleID, id uint) error {
+	tmpl, err := s.editableTemplate(userID, roleID, id)
+	if err != nil {
+		return err
+	}
+	return s.templateRepo.DeleteTemplate(tmpl.ID)
+}
+
+func applyTemplateRequest(tmpl *model.NotificationTemplate, req TemplateRequest) {
+	tmpl.Key = req.Key
+	tmpl.Locale = req.Locale
+	tmpl.Name = req.Name
+	tmpl.Category = req.Category
+	if tmpl.Category == "" {
+		tmpl.Category = "announcement"
+	}
+	tmpl.Title = req.Title
+	tmpl.Content = req.Content
+}
+
+// Resolve 查找模板：优先用户自己的模板，其次系统模板；指定语言不存在时回退到默认语言
+func (s *templateService) Resolve(key, locale string, ownerID uint) (*model.NotificationTemplate, error) {
+	if locale == "" {
+		locale = defaultLocale
+	}
+	owners := []uint{ownerID}
+	if ownerID != 0 {
+		owners = append(owners, 0)
+	}
+	for _, owner := range owners {
+		for _, l := range []string{locale, defaultLocale} {
+			if tmpl, err := s.templateRepo.FindTemplate(key, l, owner); err == nil {
+				return tmpl, nil
+			}
+		}
+	}
+	return nil, errors.New("模板不存在")
+}
+
+// RenderForUser 按接收人的语言渲染系统消息，vars 之外自动提供接收人的姓名和学号
+func (s *templateService) RenderForUser(key string, userID uint, vars map[string]string) RenderedMessage {
+	locale := defaultLocale
+	if setting, err := s.prefRepo.GetSetting(userID); err == nil && setting.Locale != "" {
+		locale = setting.Locale
+	}
+
+	merged := map[string]string{}
+	if user, err := s.userRepo.GetUserByID(userID); err == nil {
+		merged = RecipientVars(user)
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return s.render(key, locale, merged)
+}
+
+// RenderForUsers 按接收人的语言分组渲染系统消息，每种语言只查一次模板。
+// 接收人姓名、学号等占位符保留在结果中，由投递和收件箱按接收人替换。
+func (s *templateService) RenderForUsers(key string, userIDs []uint, vars map[string]string) []LocalizedMessage {
+	locales := make(map[uint]string, len(userIDs))
+	settings, err := s.prefRepo.ListSettings(userIDs)
+	if err != nil {
+		log.Printf("template: list locales: %v", err)
+	}
+	for _, setting := range settings {
+		locales[setting.UserID] = setting.Locale
+	}
+
+	var groups []LocalizedMessage
+	index := make(map[string]int)
+	for _, id := range userIDs {
+		locale := locales[id]
+		if locale == "" {
+			locale = defaultLocale
+		}
+		i, ok := index[locale]
+		if !ok {
+			i = len(groups)
+			index[locale] = i
+			groups = append(groups, LocalizedMessage{RenderedMessage: s.render(key, locale, vars)})
+		}
+		groups[i].UserIDs = append(groups[i].UserIDs, id)
+	}
+	return groups
+}
+
+func (s *templateService) render(key, locale string, vars map[string]string) RenderedMessage {
+	tmpl, err := s.Resolve(key, locale, 0)
+	if err != nil {
+		// 模板被删除时使用内置版本，保证系统消息仍能发出
+		tmpl = builtinTemplate(key)
+		if tmpl == nil {
+			log.Printf("template: system template %q not found", key)
+			return RenderedMessage{Category: "system", Title: key}
+		}
+	}
+	return RenderedMessage{
+		Category: tmpl.Category,
+		Title:    utils.RenderPlaceholders(tmpl.Title, vars),
+		Content:  utils.RenderPlaceholders(tmpl.Content, vars),
+	}
+}
+
+func builtinTemplate(key string) *model.NotificationTemplate {
+	for i := range systemTemplates {
+		if systemTemplates[i].Key == key && systemTemplates[i].Locale == defaultLocale {
+			tmpl := systemTemplates[i]
+			return &tmpl
+		}
+	}
+	return nil
+}
+
+// RecipientVars 按接收人渲染的占位符取值
+func RecipientVars(user *model.User) map[string]string {
+	vars := map[string]string{"student_name": user.Nickname, "student_no": ""}
+	if user.StudentNo != nil {
+		vars["student_no"] = *user.StudentNo
+	}
+	return vars
+}
+
+// personalize 按接收人替换通知中的 {{student_name}} 等占位符
+func personalize(notif model.Notification, user *model.User) model.Notification {
+	if !utils.HasRecipientPlaceholders(notif.Title + notif.Content) {
+		return notif
+	}
+	vars := RecipientVars(user)
+	notif.Title = utils.RenderPlaceholders(notif.Title, vars)
+	notif.Content = utils.RenderPlaceholders(notif.Content, vars)
+	return notif
+}
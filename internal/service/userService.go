@@ -12,6 +12,7 @@ type UserService interface {
 	GetProfile(userID uint) (*model.User, error)
 	GetUserOrgInfo(userID uint) (map[string]interface{}, error) // 新增接口方法
 	ListStudents(userID, roleID uint) ([]model.User, error)
+	UpdateEmergencyContact(userID uint, name, phone string) error
 }
 
 type userService struct {
@@ -118,3 +119,14 @@ func (s *userService) ListStudents(userID, roleID uint) ([]model.User, error) {
 
 	return students, nil
 }
+
+// UpdateEmergencyContact 登记紧急联系人
+func (s *userService) UpdateEmergencyContact(userID uint, name, phone string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	user.EmergencyContactName = name
+	user.EmergencyContactPhone = phone
+	return s.userRepo.UpdateUser(user)
+}
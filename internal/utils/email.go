@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/notification.txt templates/notification.html
+var emailTemplates embed.FS
+
+var (
+	notificationText = texttemplate.Must(texttemplate.ParseFS(emailTemplates, "templates/notification.txt"))
+	notificationHTML = htmltemplate.Must(htmltemplate.ParseFS(emailTemplates, "templates/notification.html"))
+)
+
+// NotificationEmail 通知邮件模板数据
+type NotificationEmail struct {
+	Title        string
+	Content      string
+	CategoryName string
+	SentAt       string
+}
+
+// RenderNotificationEmail 渲染通知邮件的纯文本和 HTML 正文
+func RenderNotificationEmail(data NotificationEmail) (text string, html string, err error) {
+	var tb, hb bytes.Buffer
+	if err := notificationText.Execute(&tb, data); err != nil {
+		return "", "", err
+	}
+	if err := notificationHTML.Execute(&hb, data); err != nil {
+		return "", "", err
+	}
+	return tb.String(), hb.String(), nil
+}
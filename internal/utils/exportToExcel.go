@@ -145,3 +145,80 @@ func ExportToExcel(data interface{}, filePrefix string) (string, error) {
 
 	return filePath, nil // 返回相对路径
 }
+
+// Sheet 描述工作簿中的一个工作表
+type Sheet struct {
+	Name    string
+	Headers []string
+	Rows    [][]interface{}
+}
+
+// ExportSheetsToExcel 将多个工作表写入同一个 Excel 文件并保存到 resources/Export 目录，
+// 表头加粗并冻结首行。返回生成文件的相对路径。
+func ExportSheetsToExcel(sheets []Sheet, filePrefix string) (string, error) {
+	if len(sheets) == 0 {
+		return "", fmt.Errorf("no sheets to export")
+	}
+
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			// 复用默认的 Sheet1
+			if err := f.SetSheetName("Sheet1", sheet.Name); err != nil {
+				return "", err
+			}
+		} else if _, err := f.NewSheet(sheet.Name); err != nil {
+			return "", err
+		}
+
+		for col, header := range sheet.Headers {
+			cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+			_ = f.SetCellValue(sheet.Name, cell, header)
+			colName, _ := excelize.ColumnNumberToName(col + 1)
+			_ = f.SetColWidth(sheet.Name, colName, colName, 18)
+		}
+		if len(sheet.Headers) > 0 {
+			last, _ := excelize.CoordinatesToCellName(len(sheet.Headers), 1)
+			_ = f.SetCellStyle(sheet.Name, "A1", last, headerStyle)
+			_ = f.SetPanes(sheet.Name, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+		}
+
+		for r, row := range sheet.Rows {
+			for c, val := range row {
+				if t, ok := val.(time.Time); ok {
+					val = t.Format("2006-01-02 15:04:05")
+				}
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+2)
+				_ = f.SetCellValue(sheet.Name, cell, val)
+			}
+		}
+	}
+	f.SetActiveSheet(0)
+
+	exportDir := filepath.Join("resources", "Export")
+	if err := os.MkdirAll(exportDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	filename := fmt.Sprintf("%s_%d.xlsx", filePrefix, time.Now().UnixMilli())
+	filePath := filepath.Join(exportDir, filename)
+	if err := f.SaveAs(filePath); err != nil {
+		return "", fmt.Errorf("failed to save file: %v", err)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ICalEvent 日历事件，UID 需在同一实体的多次更新间保持不变，客户端据此合并更新与取消
+type ICalEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	Status       string // CONFIRMED, TENTATIVE, CANCELLED
+	Sequence     int64  // 事件每次变更需递增
+	LastModified time.Time
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// RenderICal 生成 RFC 5545 格式的 iCalendar 文本
+func RenderICal(calName string, events []ICalEvent) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(foldICalLine(line))
+		b.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//UniHub//Calendar Feed//CN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	writeLine("X-WR-CALNAME:" + escapeICalText(calName))
+
+	now := time.Now().UTC().Format(icalTimeFormat)
+	for _, e := range events {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + e.UID)
+		writeLine("DTSTAMP:" + now)
+		writeLine("DTSTART:" + e.Start.UTC().Format(icalTimeFormat))
+		writeLine("DTEND:" + e.End.UTC().Format(icalTimeFormat))
+		writeLine("SUMMARY:" + escapeICalText(e.Summary))
+		if e.Description != "" {
+			writeLine("DESCRIPTION:" + escapeICalText(e.Description))
+		}
+		if e.Location != "" {
+			writeLine("LOCATION:" + escapeICalText(e.Location))
+		}
+		status := e.Status
+		if status == "" {
+			status = "CONFIRMED"
+		}
+		writeLine("STATUS:" + status)
+		writeLine(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+		if !e.LastModified.IsZero() {
+			writeLine("LAST-MODIFIED:" + e.LastModified.UTC().Format(icalTimeFormat))
+		}
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+// escapeICalText 转义 TEXT 类型属性值中的特殊字符
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// foldICalLine 按 RFC 5545 将超过 75 字节的行折行，且不拆分 UTF-8 字符
+func foldICalLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdLinePfx  = regexp.MustCompile(`(?m)^[ \t]{0,3}(#{1,6}\s+|>\s?|[-*+]\s+)`)
+	mdEmphasis = regexp.MustCompile("(\\*\\*|__|\\*|`)")
+)
+
+// MarkdownToText 去掉常用的 Markdown 标记，用于推送和邮件纯文本等不渲染 Markdown 的场景
+func MarkdownToText(md string) string {
+	text := mdImage.ReplaceAllString(md, "[$1]")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdLinePfx.ReplaceAllString(text, "")
+	text = mdEmphasis.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
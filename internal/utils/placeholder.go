@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
+
+// RecipientPlaceholders 按接收人渲染的占位符，发送时保留在通知中，投递和展示时再替换
+var RecipientPlaceholders = []string{"student_name", "student_no"}
+
+// RenderPlaceholders 替换文本中的 {{name}} 占位符，vars 中没有的占位符原样保留
+func RenderPlaceholders(text string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(text, "{{") {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// HasRecipientPlaceholders 文本中是否包含需按接收人渲染的占位符
+func HasRecipientPlaceholders(text string) bool {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		for _, name := range RecipientPlaceholders {
+			if m[1] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -3,9 +3,6 @@ package utils
 import (
 	"crypto/rand"
 	"math/big"
-	"unihub/internal/model"
-
-	"gorm.io/gorm"
 )
 
 func EndsWith(username string, s string) bool {
@@ -25,34 +22,3 @@ func GenerateInviteCode() string {
 	}
 	return string(result)
 }
-
-func PushNotification(notification model.Notification, DB *gorm.DB) (string, error) {
-	// find target users
-	var studentIDs []uint
-	if notification.TargetType == "dept" {
-		// find users in department
-		if err := DB.Model(&model.StudentDepartment{}).Where("department_id = ?", notification.TargetID).Pluck("student_id", &studentIDs).Error; err != nil {
-			return "查询部门学生失败", err
-		}
-	} else if notification.TargetType == "class" {
-		// find users in class
-		if err := DB.Model(&model.StudentClass{}).Where("class_id = ?", notification.TargetID).Pluck("student_id", &studentIDs).Error; err != nil {
-			return "查询班级学生失败", err
-		}
-	} else if notification.TargetType == "student" {
-		if err := DB.Model(&model.User{}).Where("id = ?", notification.TargetID).Pluck("id", &studentIDs).Error; err != nil {
-			return "查询学生失败", err
-		}
-	}
-
-	if len(studentIDs) == 0 {
-		return "未找到目标学生", nil
-	}
-
-	// push to each student
-	// for _, sid := range studentIDs {
-	// 	// TODO: lookup user push token and send
-	// }
-
-	return "消息推送成功", nil
-}
@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every 每隔 interval 执行一次 fn，直到 ctx 结束。fn 返回的错误只记录日志，不会中断循环。
+func Every(ctx context.Context, interval time.Duration, name string, fn func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(); err != nil {
+			log.Printf("worker %s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
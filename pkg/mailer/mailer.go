@@ -0,0 +1,104 @@
+// Package mailer 通过 SMTP 中继发送 HTML + 纯文本邮件。
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type Mailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+}
+
+// New 创建 SMTP 发件器，username 为空时不进行认证 (适用于内网中继或本地测试服务)
+func New(host string, port int, username, password, from string) (*Mailer, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid from address %q: %v", from, err)
+	}
+	return &Mailer{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     addr,
+	}, nil
+}
+
+// Send 发送 multipart/alternative 邮件，html 为空时只发送纯文本
+func (m *Mailer) Send(to *mail.Address, subject, text, html string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	msg, err := m.build(to, subject, text, html)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, auth, m.from.Address, []string{to.Address}, msg)
+}
+
+func (m *Mailer) build(to *mail.Address, subject, text, html string) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	header("From", m.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if html == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, text)
+		return b.Bytes(), nil
+	}
+
+	boundaryBytes := make([]byte, 12)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := "unihub-" + hex.EncodeToString(boundaryBytes)
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	b.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType)
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, part.body)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// writeBase64 按 76 字符折行写入 base64 编码的正文
+func writeBase64(b *bytes.Buffer, s string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,76 @@
+package passutil
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Prefix 出门凭证二维码内容的版本前缀
+const Prefix = "UHP1"
+
+var (
+	ErrMalformed = errors.New("凭证格式错误")
+	ErrSignature = errors.New("凭证签名无效")
+	ErrExpired   = errors.New("凭证不在有效期内")
+)
+
+// Claims 出门凭证载荷
+type Claims struct {
+	PassID      uint   `json:"pid"`
+	LeaveID     uint   `json:"lid"`
+	StudentID   uint   `json:"sid"`
+	StudentName string `json:"name"`
+	StudentNo   string `json:"no,omitempty"`
+	NotBefore   int64  `json:"nbf"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+// KeyFromSeed 由任意长度的密钥材料派生 Ed25519 私钥
+func KeyFromSeed(secret string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("unihub-exit-pass:" + secret))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// Sign 生成二维码内容：UHP1.<base64url(载荷)>.<base64url(签名)>
+func Sign(key ed25519.PrivateKey, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	body := Prefix + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(key, []byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Verify 校验签名与有效期，校验通过时返回载荷。门禁设备可仅凭公钥离线完成该校验。
+func Verify(pub ed25519.PublicKey, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != Prefix {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if now.Unix() < claims.NotBefore || now.Unix() > claims.ExpiresAt {
+		return &claims, ErrExpired
+	}
+	return &claims, nil
+}
@@ -0,0 +1,106 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// APNsPusher APNs 风格的推送：每个设备令牌一个请求，按 batchSize 控制并发。
+type APNsPusher struct {
+	endpoint  string
+	topic     string
+	authToken string
+	batchSize int
+	client    *http.Client
+}
+
+func NewAPNsPusher(endpoint, topic, authToken string, batchSize int, client *http.Client) *APNsPusher {
+	if batchSize <= 0 {
+		batchSize = 20
+	}
+	return &APNsPusher{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		topic:     topic,
+		authToken: authToken,
+		batchSize: batchSize,
+		client:    defaultClient(client),
+	}
+}
+
+func (p *APNsPusher) Name() string { return "apns" }
+
+// apnsInvalidReasons 表示令牌已失效的错误原因 (HTTP 410 也视为失效)
+var apnsInvalidReasons = map[string]bool{
+	"BadDeviceToken":         true,
+	"Unregistered":           true,
+	"DeviceTokenNotForTopic": true,
+}
+
+func (p *APNsPusher) Send(ctx context.Context, tokens []string, msg Message) ([]Result, error) {
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(tokens))
+	for start, batch := range chunk(tokens, p.batchSize) {
+		var wg sync.WaitGroup
+		for i, token := range batch {
+			wg.Add(1)
+			go func(idx int, token string) {
+				defer wg.Done()
+				results[idx] = p.sendOne(ctx, token, body)
+			}(start*p.batchSize+i, token)
+		}
+		wg.Wait()
+	}
+	return results, nil
+}
+
+func (p *APNsPusher) sendOne(ctx context.Context, token string, body []byte) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return Result{Token: token, Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-push-type", "alert")
+	if p.topic != "" {
+		req.Header.Set("apns-topic", p.topic)
+	}
+	if p.authToken != "" {
+		req.Header.Set("Authorization", "bearer "+p.authToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Result{Token: token, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return Result{Token: token, Success: true}
+	}
+
+	var reason struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reason)
+	return Result{
+		Token:   token,
+		Invalid: resp.StatusCode == http.StatusGone || apnsInvalidReasons[reason.Reason],
+		Error:   fmt.Sprintf("apns: status %d %s", resp.StatusCode, reason.Reason),
+	}
+}
@@ -0,0 +1,115 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// FCMPusher FCM 风格的多播推送：一次请求携带多个 registration_ids，响应中按顺序返回每个令牌的结果。
+type FCMPusher struct {
+	endpoint  string
+	serverKey string
+	batchSize int
+	client    *http.Client
+}
+
+// fcmMaxBatch FCM 单次请求最多支持的令牌数
+const fcmMaxBatch = 500
+
+func NewFCMPusher(endpoint, serverKey string, batchSize int, client *http.Client) *FCMPusher {
+	if batchSize <= 0 || batchSize > fcmMaxBatch {
+		batchSize = fcmMaxBatch
+	}
+	return &FCMPusher{
+		endpoint:  endpoint,
+		serverKey: serverKey,
+		batchSize: batchSize,
+		client:    defaultClient(client),
+	}
+}
+
+func (p *FCMPusher) Name() string { return "fcm" }
+
+type fcmRequest struct {
+	RegistrationIDs []string          `json:"registration_ids"`
+	Notification    map[string]string `json:"notification"`
+	Data            map[string]string `json:"data,omitempty"`
+}
+
+type fcmResponse struct {
+	Results []struct {
+		MessageID string `json:"message_id"`
+		Error     string `json:"error"`
+	} `json:"results"`
+}
+
+// fcmInvalidErrors 表示令牌已失效的错误码
+var fcmInvalidErrors = map[string]bool{
+	"NotRegistered":       true,
+	"InvalidRegistration": true,
+	"MismatchSenderId":    true,
+}
+
+func (p *FCMPusher) Send(ctx context.Context, tokens []string, msg Message) ([]Result, error) {
+	results := make([]Result, 0, len(tokens))
+	for _, batch := range chunk(tokens, p.batchSize) {
+		batchResults, err := p.sendBatch(ctx, batch, msg)
+		if err != nil {
+			for _, t := range batch {
+				results = append(results, Result{Token: t, Error: err.Error()})
+			}
+			continue
+		}
+		results = append(results, batchResults...)
+	}
+	return results, nil
+}
+
+func (p *FCMPusher) sendBatch(ctx context.Context, tokens []string, msg Message) ([]Result, error) {
+	body, err := json.Marshal(fcmRequest{
+		RegistrationIDs: tokens,
+		Notification:    map[string]string{"title": msg.Title, "body": msg.Body},
+		Data:            msg.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+p.serverKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fcm: unexpected status %d", resp.StatusCode)
+	}
+
+	var parsed fcmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("fcm: decode response: %v", err)
+	}
+	if len(parsed.Results) != len(tokens) {
+		return nil, fmt.Errorf("fcm: expected %d results, got %d", len(tokens), len(parsed.Results))
+	}
+
+	results := make([]Result, len(tokens))
+	for i, r := range parsed.Results {
+		results[i] = Result{
+			Token:   tokens[i],
+			Success: r.Error == "",
+			Invalid: fcmInvalidErrors[r.Error],
+			Error:   r.Error,
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,55 @@
+// Package push 封装移动端推送服务商的 HTTP 接口。
+package push
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Message 推送内容
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Result 单个设备令牌的推送结果
+type Result struct {
+	Token   string
+	Success bool
+	Invalid bool // 令牌已失效，应从用户信息中清除
+	Error   string
+}
+
+// Pusher 推送服务商
+type Pusher interface {
+	// Name 服务商标识，对应 User.PushPlatform
+	Name() string
+	// Send 向一组设备令牌推送消息，返回与 tokens 一一对应的结果。
+	// 只有请求无法发出等整体失败时才返回 error。
+	Send(ctx context.Context, tokens []string, msg Message) ([]Result, error)
+}
+
+func defaultClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+func chunk(tokens []string, size int) [][]string {
+	if size <= 0 {
+		size = len(tokens)
+	}
+	var batches [][]string
+	for size > 0 && len(tokens) > 0 {
+		n := size
+		if len(tokens) < n {
+			n = len(tokens)
+		}
+		batches = append(batches, tokens[:n])
+		tokens = tokens[n:]
+	}
+	return batches
+}
@@ -17,6 +17,13 @@ INSERT INTO permissions (code, name, created_at, updated_at) VALUES
 ('dept:list','List Departments', NOW(), NOW()),
 ('ding:create','Create Ding', NOW(), NOW()),
 ('leave:approve','Approval leave', NOW(), NOW()),
+('holiday:create','Create Holiday Registration', NOW(), NOW()),
+('leave:report','View Leave Reports', NOW(), NOW()),
+('notification:urgent','Send Urgent Notifications', NOW(), NOW()),
+('notification:broadcast','Send Notifications to Roles or Whole School', NOW(), NOW()),
+('org:manage','Manage Organization Tree', NOW(), NOW()),
+('org:view','View Organization Tree', NOW(), NOW()),
+('template:system','Manage System Notification Templates', NOW(), NOW()),
 ('class:join', 'Join Class', NOW(), NOW());
 
 INSERT INTO role_permissions (role_id, permission_id) VALUES
@@ -24,12 +31,30 @@ INSERT INTO role_permissions (role_id, permission_id) VALUES
 ((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'class:create')),
 ((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'dept:create')),
 ((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'class:create')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'holiday:create')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'holiday:create')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'leave:report')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'leave:report')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'notification:urgent')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'notification:urgent')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'notification:broadcast')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'notification:broadcast')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'org:manage')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'org:manage')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'org:view')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'org:view')),
+((SELECT id FROM roles WHERE `key` = 'super_admin'), (SELECT id FROM permissions WHERE code = 'template:system')),
+((SELECT id FROM roles WHERE `key` = 'admin'), (SELECT id FROM permissions WHERE code = 'template:system')),
 
 ((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'dept:create')),
 ((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'dept:list')),
+((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'org:view')),
 ((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'class:create')),
 ((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'leave:approve')),
 ((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'ding:create')),
+((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'holiday:create')),
+((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'leave:report')),
+((SELECT id FROM roles WHERE `key` = 'counselor'), (SELECT id FROM permissions WHERE code = 'notification:urgent')),
 
 ((SELECT id FROM roles WHERE `key` = 'teacher'), (SELECT id FROM permissions WHERE code = 'class:create')),
 ((SELECT id FROM roles WHERE `key` = 'teacher'), (SELECT id FROM permissions WHERE code = 'ding:create')),
@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	"unihub/internal/utils"
+)
+
+func TestDistance(t *testing.T) {
+	// 经度相差 0.001 度，在北纬 30 度约 96 米
+	d := utils.Distance(30, 120, 30, 120.001)
+	if math.Abs(d-96.3) > 1 {
+		t.Fatalf("distance = %.1f, want about 96.3", d)
+	}
+	if d := utils.Distance(30, 120, 30, 120); d != 0 {
+		t.Fatalf("distance to self = %v", d)
+	}
+}
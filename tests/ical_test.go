@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"unihub/internal/utils"
+)
+
+func TestRenderICal(t *testing.T) {
+	start := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	out := utils.RenderICal("UniHub", []utils.ICalEvent{
+		{
+			UID:     "leave-1@unihub",
+			Summary: "请假：事假, 回家;探亲",
+			Start:   start,
+			End:     start.Add(48 * time.Hour),
+			Status:  "CANCELLED",
+		},
+		{
+			UID:     "ding-2@unihub",
+			Summary: strings.Repeat("打卡", 40),
+			Start:   start,
+			End:     start.Add(time.Hour),
+		},
+	})
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:leave-1@unihub\r\n",
+		"DTSTART:20260110T080000Z\r\n",
+		`SUMMARY:请假：事假\, 回家\;探亲` + "\r\n",
+		"STATUS:CANCELLED\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output", want)
+		}
+	}
+
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line exceeds 75 octets: %q", line)
+		}
+	}
+}
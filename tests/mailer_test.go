@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bufio"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"unihub/internal/utils"
+	"unihub/pkg/mailer"
+)
+
+// fakeSMTP 最简 SMTP 服务，接收一封邮件后把 DATA 内容写入 received
+func fakeSMTP(t *testing.T, received chan<- string) (host string, port int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port
+}
+
+func TestMailerSendsMultipartEmail(t *testing.T) {
+	received := make(chan string, 1)
+	host, port := fakeSMTP(t, received)
+
+	m, err := mailer.New(host, port, "", "", "UniHub <noreply@unihub.local>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, html, err := utils.RenderNotificationEmail(utils.NotificationEmail{
+		Title:        "安全提醒",
+		Content:      "周末外出请注意<安全>",
+		CategoryName: "通知公告",
+		SentAt:       "2026-01-09 18:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "周末外出请注意&lt;安全&gt;") {
+		t.Errorf("html body is not escaped: %s", html)
+	}
+	if !strings.Contains(text, "周末外出请注意<安全>") {
+		t.Errorf("text body missing content: %s", text)
+	}
+
+	to := &mail.Address{Name: "张老师", Address: "staff@unihub.local"}
+	if err := m.Send(to, "【通知公告】安全提醒", text, html); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	data := <-received
+	for _, want := range []string{
+		"To: =?utf-8?q?",
+		"<staff@unihub.local>",
+		"Subject: =?utf-8?q?",
+		"multipart/alternative",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("missing %q in message", want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package tests
+
+import (
+	"testing"
+
+	"unihub/internal/utils"
+)
+
+func TestMarkdownToText(t *testing.T) {
+	md := "## 安全提醒\n\n- 地点改为 **3 号楼 201**\n- 详见[通知原文](https://example.com/a)\n\n![平面图](https://example.com/map.png)"
+	want := "安全提醒\n\n地点改为 3 号楼 201\n详见通知原文\n\n[平面图]"
+	if got := utils.MarkdownToText(md); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
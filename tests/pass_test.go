@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+
+	"unihub/pkg/passutil"
+)
+
+func TestExitPassSignAndVerify(t *testing.T) {
+	key := passutil.KeyFromSeed("test-secret")
+	pub := key.Public().(ed25519.PublicKey)
+	now := time.Now()
+
+	token, err := passutil.Sign(key, passutil.Claims{
+		PassID:    1,
+		LeaveID:   42,
+		StudentID: 7,
+		NotBefore: now.Add(-time.Hour).Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	claims, err := passutil.Verify(pub, token, now)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.LeaveID != 42 || claims.StudentID != 7 {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := passutil.Verify(pub, token, now.Add(2*time.Hour)); err != passutil.ErrExpired {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+
+	// 篡改载荷中的学生ID
+	parts := strings.Split(token, ".")
+	forged, _ := passutil.Sign(passutil.KeyFromSeed("test-secret"), passutil.Claims{StudentID: 8, ExpiresAt: now.Add(time.Hour).Unix()})
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+	if _, err := passutil.Verify(pub, tampered, now); err != passutil.ErrSignature {
+		t.Errorf("expected ErrSignature, got %v", err)
+	}
+
+	other := passutil.KeyFromSeed("other-secret").Public().(ed25519.PublicKey)
+	if _, err := passutil.Verify(other, token, now); err != passutil.ErrSignature {
+		t.Errorf("expected ErrSignature with foreign key, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package tests
+
+import (
+	"testing"
+
+	"unihub/internal/utils"
+)
+
+func TestRenderPlaceholders(t *testing.T) {
+	text := "{{ student_name }}，请在 {{deadline}} 前提交{{unknown}}"
+	got := utils.RenderPlaceholders(text, map[string]string{"student_name": "张三", "deadline": "周五 18:00"})
+	if want := "张三，请在 周五 18:00 前提交{{unknown}}"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if !utils.HasRecipientPlaceholders("学号 {{student_no}}") {
+		t.Fatal("student_no should be a recipient placeholder")
+	}
+	if utils.HasRecipientPlaceholders("截止 {{deadline}}") {
+		t.Fatal("deadline is not a recipient placeholder")
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"unihub/pkg/push"
+)
+
+func TestFCMPusherBatchesAndDetectsInvalidTokens(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Authorization") != "key=test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			RegistrationIDs []string `json:"registration_ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		type result struct {
+			MessageID string `json:"message_id,omitempty"`
+			Error     string `json:"error,omitempty"`
+		}
+		var results []result
+		for _, token := range body.RegistrationIDs {
+			if strings.HasPrefix(token, "bad") {
+				results = append(results, result{Error: "NotRegistered"})
+			} else {
+				results = append(results, result{MessageID: "m-" + token})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer srv.Close()
+
+	p := push.NewFCMPusher(srv.URL, "test-key", 2, srv.Client())
+	results, err := p.Send(context.Background(), []string{"a", "bad1", "b", "c", "bad2"}, push.Message{Title: "t", Body: "b"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("expected 3 batched requests, got %d", got)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	for _, r := range results {
+		bad := strings.HasPrefix(r.Token, "bad")
+		if r.Success == bad || r.Invalid != bad {
+			t.Errorf("unexpected result for %s: %+v", r.Token, r)
+		}
+	}
+}
+
+func TestAPNsPusherDetectsInvalidTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		switch token {
+		case "gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		case "busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+		default:
+			if r.Header.Get("apns-topic") != "edu.unihub.app" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	p := push.NewAPNsPusher(srv.URL, "edu.unihub.app", "jwt", 2, srv.Client())
+	tokens := []string{"ok1", "gone", "bad", "busy", "ok2"}
+	results, err := p.Send(context.Background(), tokens, push.Message{Title: "t", Body: "b"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	want := map[string][2]bool{ // success, invalid
+		"ok1":  {true, false},
+		"gone": {false, true},
+		"bad":  {false, true},
+		"busy": {false, false},
+		"ok2":  {true, false},
+	}
+	for i, r := range results {
+		if r.Token != tokens[i] {
+			t.Fatalf("result %d out of order: %s", i, r.Token)
+		}
+		if w := want[r.Token]; r.Success != w[0] || r.Invalid != w[1] {
+			t.Errorf("unexpected result for %s: %+v", r.Token, r)
+		}
+	}
+}
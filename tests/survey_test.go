@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"unihub/internal/service"
+)
+
+const surveyConfig = `{"fields": [
+	{"id": "leave", "title": "是否离校", "type": "single_choice", "options": ["是", "否"], "required": true},
+	{"id": "dest", "title": "目的地", "type": "text", "required": true, "min": 2, "max": 10,
+	 "visible_if": {"field": "leave", "values": ["是"]}},
+	{"id": "days", "title": "天数", "type": "number", "min": 1, "max": 30},
+	{"id": "transport", "title": "交通方式", "type": "multiple_choice", "options": ["火车", "飞机", "汽车"], "max": 2},
+	{"id": "back", "title": "返校日期", "type": "date"}
+]}`
+
+func TestSurveyConfigValidation(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", surveyConfig, ""},
+		{"no fields", `{"fields": []}`, "问卷至少需要一个字段"},
+		{"duplicate id", `{"fields": [
+			{"id": "a", "title": "A", "type": "text"},
+			{"id": "a", "title": "B", "type": "text"}]}`, "字段 id 重复"},
+		{"unknown type", `{"fields": [{"id": "a", "title": "A", "type": "slider"}]}`, "未知的字段类型"},
+		{"single option", `{"fields": [{"id": "a", "title": "A", "type": "single_choice", "options": ["x"]}]}`, "选择题至少需要两个选项"},
+		{"min above max", `{"fields": [{"id": "a", "title": "A", "type": "number", "min": 5, "max": 1}]}`, "最小值不能大于最大值"},
+		{"condition on later field", `{"fields": [
+			{"id": "a", "title": "A", "type": "text", "visible_if": {"field": "b", "values": ["x"]}},
+			{"id": "b", "title": "B", "type": "single_choice", "options": ["x", "y"]}]}`, "显示条件只能引用前面的字段"},
+		{"condition on text field", `{"fields": [
+			{"id": "a", "title": "A", "type": "text"},
+			{"id": "b", "title": "B", "type": "text", "visible_if": {"field": "a", "values": ["x"]}}]}`, "显示条件只能引用选择题"},
+		{"condition with unknown option", `{"fields": [
+			{"id": "a", "title": "A", "type": "single_choice", "options": ["x", "y"]},
+			{"id": "b", "title": "B", "type": "text", "visible_if": {"field": "a", "values": ["z"]}}]}`, "显示条件的选项不存在"},
+		{"unknown key", `{"fields": [{"id": "a", "title": "A", "type": "text", "hint": "?"}]}`, "问卷配置格式错误"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.ValidateTaskSubmission("survey", json.RawMessage(tc.config), json.RawMessage(`{"answers": {}}`))
+			if tc.wantErr == "" {
+				// 合法配置下空提交只应因必填项失败
+				if err == nil || !strings.Contains(err.Error(), "请填写") {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSurveySubmissionValidation(t *testing.T) {
+	cases := []struct {
+		name     string
+		answers  string
+		wantErr  string
+		wantKeys []string
+	}{
+		{"required missing", `{}`, "请填写：是否离校", nil},
+		{"hidden field not required", `{"leave": "否"}`, "", []string{"leave"}},
+		{"hidden answer dropped", `{"leave": "否", "dest": "北京"}`, "", []string{"leave"}},
+		{"visible field required", `{"leave": "是"}`, "请填写：目的地", nil},
+		{"visible field kept", `{"leave": "是", "dest": "北京"}`, "", []string{"leave", "dest"}},
+		{"text too short", `{"leave": "是", "dest": "京"}`, "字数不能小于 2", nil},
+		{"text too long", `{"leave": "是", "dest": "一二三四五六七八九十十一"}`, "字数不能大于 10", nil},
+		{"number in range", `{"leave": "否", "days": 30}`, "", []string{"leave", "days"}},
+		{"number below min", `{"leave": "否", "days": 0}`, "数值不能小于 1", nil},
+		{"number above max", `{"leave": "否", "days": 31}`, "数值不能大于 30", nil},
+		{"number wrong type", `{"leave": "否", "days": "三"}`, "回答格式错误：天数", nil},
+		{"too many choices", `{"leave": "否", "transport": ["火车", "飞机", "汽车"]}`, "选择个数不能大于 2", nil},
+		{"unknown option", `{"leave": "也许"}`, "选项无效：是否离校", nil},
+		{"bad date", `{"leave": "否", "back": "2026/03/01"}`, "日期格式应为 YYYY-MM-DD", nil},
+		{"empty optional answer", `{"leave": "否", "days": null, "transport": []}`, "", []string{"leave"}},
+		{"unknown field", `{"leave": "否", "extra": 1}`, "未知的字段：extra", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := service.ValidateTaskSubmission("survey", json.RawMessage(surveyConfig),
+				json.RawMessage(`{"answers": `+tc.answers+`}`))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			answers := got.(service.SurveySubmission).Answers
+			if len(answers) != len(tc.wantKeys) {
+				t.Fatalf("got answers %v, want keys %v", answers, tc.wantKeys)
+			}
+			for _, k := range tc.wantKeys {
+				if _, ok := answers[k]; !ok {
+					t.Fatalf("missing answer %q in %v", k, answers)
+				}
+			}
+		})
+	}
+}